@@ -0,0 +1,19 @@
+
+-- +migrate Up
+CREATE TABLE email_outboxes (
+    id BIGSERIAL PRIMARY KEY,
+    template VARCHAR(255) NOT NULL,
+    recipient VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    prop TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent_ts TIMESTAMP NULL,
+    insert_ts TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX email_outboxes_due_idx ON email_outboxes (next_attempt_at) WHERE status IN ('pending', 'processing');
+
+-- +migrate Down
+DROP TABLE email_outboxes;
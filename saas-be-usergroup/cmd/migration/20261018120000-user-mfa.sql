@@ -0,0 +1,23 @@
+
+-- +migrate Up
+CREATE TABLE user_totps (
+    id BIGSERIAL PRIMARY KEY,
+    user_account_id BIGINT NOT NULL UNIQUE,
+    secret VARCHAR(64) NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    enabled_ts TIMESTAMP NULL,
+    insert_ts TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE user_recovery_codes (
+    id BIGSERIAL PRIMARY KEY,
+    user_account_id BIGINT NOT NULL,
+    code_hash VARCHAR(64) NOT NULL,
+    used_ts TIMESTAMP NULL,
+    insert_ts TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX user_recovery_codes_user_code_idx ON user_recovery_codes (user_account_id, code_hash);
+
+-- +migrate Down
+DROP TABLE user_recovery_codes;
+DROP TABLE user_totps;
@@ -0,0 +1,19 @@
+
+-- +migrate Up
+CREATE TABLE user_webauthn_credentials (
+    id BIGSERIAL PRIMARY KEY,
+    user_account_id BIGINT NOT NULL,
+    credential_id VARCHAR(1400) NOT NULL UNIQUE,
+    public_key BYTEA NOT NULL,
+    algorithm INTEGER NOT NULL,
+    sign_count BIGINT NOT NULL DEFAULT 0,
+    aa_guid VARCHAR(32) NOT NULL DEFAULT '',
+    name VARCHAR(64) NOT NULL DEFAULT '',
+    transports VARCHAR(255) NOT NULL DEFAULT '',
+    last_used_ts TIMESTAMP NULL,
+    insert_ts TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX user_webauthn_credentials_user_account_id_idx ON user_webauthn_credentials (user_account_id);
+
+-- +migrate Down
+DROP TABLE user_webauthn_credentials;
@@ -0,0 +1,16 @@
+
+-- +migrate Up
+CREATE TABLE user_identities (
+    id BIGSERIAL PRIMARY KEY,
+    user_account_id BIGINT NOT NULL,
+    provider VARCHAR(32) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    last_login_ts TIMESTAMP NULL,
+    insert_ts TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX user_identities_provider_subject_idx ON user_identities (provider, subject);
+CREATE INDEX user_identities_user_account_id_idx ON user_identities (user_account_id);
+
+-- +migrate Down
+DROP TABLE user_identities;
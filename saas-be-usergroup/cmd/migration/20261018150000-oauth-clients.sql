@@ -0,0 +1,16 @@
+-- +migrate Up
+CREATE TABLE oauth_clients (
+    id BIGSERIAL PRIMARY KEY,
+    client_id VARCHAR(64) NOT NULL,
+    client_secret_hash VARCHAR(128) NOT NULL DEFAULT '',
+    name VARCHAR(255) NOT NULL,
+    redirect_uris TEXT NOT NULL,
+    scopes TEXT NOT NULL DEFAULT '',
+    grant_types TEXT NOT NULL DEFAULT '',
+    confidential BOOLEAN NOT NULL DEFAULT FALSE,
+    insert_ts TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX oauth_clients_client_id_idx ON oauth_clients (client_id);
+
+-- +migrate Down
+DROP TABLE oauth_clients;
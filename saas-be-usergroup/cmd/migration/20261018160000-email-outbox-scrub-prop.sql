@@ -0,0 +1,5 @@
+-- +migrate Up
+UPDATE email_outboxes SET prop = '{}' WHERE status IN ('sent', 'dead');
+
+-- +migrate Down
+-- the scrubbed props can not be restored
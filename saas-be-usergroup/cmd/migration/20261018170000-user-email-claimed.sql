@@ -0,0 +1,12 @@
+-- +migrate Up
+-- accounts registered before the status was kept are confirmed with a password, they are verified
+UPDATE users SET status = 'verified' WHERE COALESCE(status, '') IN ('', 'new', 'confirmed') AND COALESCE(password, '') <> '';
+-- a sign-up can not be finished on the email of a registered account
+DELETE FROM users pending WHERE COALESCE(pending.status, '') IN ('', 'new', 'confirmed') AND EXISTS (
+    SELECT 1 FROM users claimed WHERE claimed.email = pending.email AND claimed.id <> pending.id AND claimed.status NOT IN ('', 'new', 'confirmed', 'deleted')
+);
+CREATE UNIQUE INDEX users_email_claimed_idx ON users (email) WHERE status NOT IN ('', 'new', 'confirmed', 'deleted');
+
+-- +migrate Down
+DROP INDEX IF EXISTS users_email_claimed_idx;
+-- the verified status of the older accounts and the removed sign-ups are kept
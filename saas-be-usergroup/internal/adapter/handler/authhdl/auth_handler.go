@@ -3,9 +3,13 @@ package authhdl
 import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/saas-be-usergroup/internal/core/domain/auth"
+	"github.com/saas-be-usergroup/internal/core/domain/oidc"
+	"github.com/saas-be-usergroup/internal/core/domain/webauthn"
+	"github.com/saas-be-usergroup/internal/core/middleware"
 	"github.com/saas-be-usergroup/internal/core/ports"
 	responseErr "github.com/saas-be-usergroup/internal/error"
 	"github.com/saas-be-usergroup/internal/response"
+	"strings"
 )
 
 type authHandler struct {
@@ -35,6 +39,21 @@ func (a authHandler) RegisterBeforeWithEmail(c *fiber.Ctx) error {
 	return response.Success(c, fiber.StatusOK, response.SuccessData(res))
 }
 
+func (a authHandler) ResendRegisterOTP(c *fiber.Ctx) error {
+	in := auth.NewResendRegisterOTPRequest()
+	if err := c.BodyParser(&in); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := in.Validate(); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	res, err := a.authService.ResendRegisterOTP(c.Context(), *in)
+	if err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(res))
+}
+
 func (a authHandler) ConfirmationRegister(c *fiber.Ctx) error {
 	in := auth.NewConfirmationRegister()
 	if err := c.BodyParser(&in); err != nil {
@@ -58,7 +77,7 @@ func (a authHandler) DoRegister(c *fiber.Ctx) error {
 	if err := in.Validate(); err != nil {
 		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
 	}
-	res, err := a.authService.DoRegister(c.Context(), *in)
+	res, err := a.authService.DoRegister(c.Context(), *in, sessionMeta(c))
 	if err != nil {
 		return responseErr.Response(c, err)
 	}
@@ -73,7 +92,52 @@ func (a authHandler) DoLogin(c *fiber.Ctx) error {
 	if err := in.Validate(); err != nil {
 		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
 	}
-	res, err := a.authService.DoLogin(c.Context(), *in)
+	res, err := a.authService.DoLogin(c.Context(), *in, sessionMeta(c))
+	if err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(res))
+}
+
+func (a authHandler) DoLoginMFA(c *fiber.Ctx) error {
+	in := auth.NewDoLoginMFARequest()
+	if err := c.BodyParser(&in); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := in.Validate(); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	res, err := a.authService.DoLoginMFA(c.Context(), *in, sessionMeta(c))
+	if err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(res))
+}
+
+func (a authHandler) RequestMagicLink(c *fiber.Ctx) error {
+	in := auth.NewMagicLinkRequest()
+	if err := c.BodyParser(&in); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := in.Validate(); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	res, err := a.authService.RequestMagicLink(c.Context(), *in)
+	if err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(res))
+}
+
+func (a authHandler) VerifyMagicLink(c *fiber.Ctx) error {
+	in := auth.NewVerifyMagicLinkRequest()
+	if err := c.BodyParser(&in); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := in.Validate(); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	res, err := a.authService.VerifyMagicLink(c.Context(), *in, sessionMeta(c))
 	if err != nil {
 		return responseErr.Response(c, err)
 	}
@@ -88,7 +152,7 @@ func (a authHandler) DoRefreshToken(c *fiber.Ctx) error {
 	if err := in.Validate(); err != nil {
 		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
 	}
-	res, err := a.authService.DoRefreshToken(c.Context(), *in)
+	res, err := a.authService.DoRefreshToken(c.Context(), *in, sessionMeta(c))
 	if err != nil {
 		return responseErr.Response(c, err)
 	}
@@ -103,10 +167,268 @@ func (a authHandler) DoLogout(c *fiber.Ctx) error {
 	if err := in.Validate(); err != nil {
 		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
 	}
-	if err := a.authService.DoLogout(c.Context(), *in); err != nil {
+	jwtData := middleware.ExportData(c.Context())
+	if err := a.authService.DoLogout(c.Context(), *in, jwtData.GetUUID(), jwtData.GetJTI(), jwtData.GetExpiresAt()); err != nil {
 		return responseErr.Response(c, err)
 	}
 	return response.Success(c, fiber.StatusOK, response.SuccessData(map[string]interface{}{
 		"message": "logout success",
 	}))
 }
+
+func (a authHandler) ForgotPassword(c *fiber.Ctx) error {
+	in := auth.NewForgotPasswordRequest()
+	if err := c.BodyParser(&in); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := in.Validate(); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := a.authService.ForgotPassword(c.Context(), *in); err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(map[string]interface{}{
+		"message": "if the email is registered, an email has been sent with the token to reset your password",
+	}))
+}
+
+func (a authHandler) ResetPassword(c *fiber.Ctx) error {
+	in := auth.NewResetPasswordRequest()
+	if err := c.BodyParser(&in); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := in.Validate(); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := a.authService.ResetPassword(c.Context(), *in); err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(map[string]interface{}{
+		"message": "password has been reset",
+	}))
+}
+
+// JWKS is served as a bare key set instead of the response envelope, jwt libraries expect the standard document
+func (a authHandler) JWKS(c *fiber.Ctx) error {
+	res, err := a.authService.GetJWKS(c.Context())
+	if err != nil {
+		return responseErr.Response(c, err)
+	}
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+func (a authHandler) GetSessions(c *fiber.Ctx) error {
+	jwtData := middleware.ExportData(c.Context())
+	res, err := a.authService.GetSessions(c.Context(), jwtData.GetUUID(), jwtData.GetSessionID())
+	if err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(res))
+}
+
+func (a authHandler) RevokeSession(c *fiber.Ctx) error {
+	in := auth.NewRevokeSessionRequest()
+	if err := c.BodyParser(&in); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := in.Validate(); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := a.authService.RevokeSession(c.Context(), *in, middleware.ExportData(c.Context()).GetUUID()); err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(map[string]interface{}{
+		"message": "session has been revoked",
+	}))
+}
+
+func (a authHandler) RevokeAllSessions(c *fiber.Ctx) error {
+	if err := a.authService.RevokeAllSessions(c.Context(), middleware.ExportData(c.Context()).GetUUID()); err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(map[string]interface{}{
+		"message": "every session has been logged out",
+	}))
+}
+
+func (a authHandler) UnlockAccount(c *fiber.Ctx) error {
+	in := auth.NewUnlockAccountRequest()
+	if err := c.BodyParser(&in); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := in.Validate(); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := a.authService.UnlockAccount(c.Context(), *in); err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(map[string]interface{}{
+		"message": "account has been unlocked",
+	}))
+}
+
+func (a authHandler) SuspendAccount(c *fiber.Ctx) error {
+	in := auth.NewAccountStatusRequest()
+	if err := c.BodyParser(&in); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := in.Validate(); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := a.authService.SuspendAccount(c.Context(), *in); err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(map[string]interface{}{
+		"message": "account has been suspended",
+	}))
+}
+
+func (a authHandler) ReactivateAccount(c *fiber.Ctx) error {
+	in := auth.NewAccountStatusRequest()
+	if err := c.BodyParser(&in); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := in.Validate(); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := a.authService.ReactivateAccount(c.Context(), *in); err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(map[string]interface{}{
+		"message": "account has been reactivated",
+	}))
+}
+
+func (a authHandler) DeleteAccount(c *fiber.Ctx) error {
+	in := auth.NewAccountStatusRequest()
+	if err := c.BodyParser(&in); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := in.Validate(); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := a.authService.DeleteAccount(c.Context(), *in); err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(map[string]interface{}{
+		"message": "account has been deleted",
+	}))
+}
+
+func (a authHandler) BeginWebAuthnRegistration(c *fiber.Ctx) error {
+	res, err := a.authService.BeginWebAuthnRegistration(c.Context(), middleware.ExportData(c.Context()).GetUUID())
+	if err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(res))
+}
+
+func (a authHandler) FinishWebAuthnRegistration(c *fiber.Ctx) error {
+	in := webauthn.NewFinishRegistrationRequest()
+	if err := c.BodyParser(&in); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := in.Validate(); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	res, err := a.authService.FinishWebAuthnRegistration(c.Context(), *in, middleware.ExportData(c.Context()).GetUUID())
+	if err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(res))
+}
+
+func (a authHandler) BeginWebAuthnLogin(c *fiber.Ctx) error {
+	in := webauthn.NewBeginLoginRequest()
+	if err := c.BodyParser(&in); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := in.Validate(); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	res, err := a.authService.BeginWebAuthnLogin(c.Context(), *in)
+	if err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(res))
+}
+
+func (a authHandler) FinishWebAuthnLogin(c *fiber.Ctx) error {
+	in := webauthn.NewFinishLoginRequest()
+	if err := c.BodyParser(&in); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := in.Validate(); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	res, err := a.authService.FinishWebAuthnLogin(c.Context(), *in, sessionMeta(c))
+	if err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(res))
+}
+
+func (a authHandler) BeginOIDCLogin(c *fiber.Ctx) error {
+	in := oidc.NewBeginRequest(strings.ToLower(c.Params("provider")))
+	if err := in.Validate(); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	res, err := a.authService.BeginOIDCLogin(c.Context(), *in)
+	if err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(res))
+}
+
+func (a authHandler) FinishOIDCLogin(c *fiber.Ctx) error {
+	in := oidc.NewCallbackRequest(strings.ToLower(c.Params("provider")))
+	if err := c.BodyParser(&in); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	// the provider of the path wins over one of the body
+	in.Provider = strings.ToLower(c.Params("provider"))
+	if err := in.Validate(); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	res, err := a.authService.FinishOIDCLogin(c.Context(), *in, sessionMeta(c))
+	if err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(res))
+}
+
+func (a authHandler) BeginOIDCLink(c *fiber.Ctx) error {
+	in := oidc.NewBeginRequest(strings.ToLower(c.Params("provider")))
+	if err := in.Validate(); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	res, err := a.authService.BeginOIDCLink(c.Context(), *in, middleware.ExportData(c.Context()).GetUUID())
+	if err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(res))
+}
+
+func (a authHandler) FinishOIDCLink(c *fiber.Ctx) error {
+	in := oidc.NewCallbackRequest(strings.ToLower(c.Params("provider")))
+	if err := c.BodyParser(&in); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	// the provider of the path wins over one of the body
+	in.Provider = strings.ToLower(c.Params("provider"))
+	if err := in.Validate(); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := a.authService.FinishOIDCLink(c.Context(), *in, middleware.ExportData(c.Context()).GetUUID()); err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(map[string]interface{}{
+		"message": "identity provider has been linked",
+	}))
+}
+
+// sessionMeta describes the calling client, apps may name the device with the X-Device-Name header
+func sessionMeta(c *fiber.Ctx) auth.SessionMeta {
+	return *auth.NewSessionMeta(c.Get("X-Device-Name"), c.IP(), c.Get(fiber.HeaderUserAgent))
+}
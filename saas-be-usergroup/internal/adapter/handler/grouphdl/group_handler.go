@@ -0,0 +1,70 @@
+package grouphdl
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/saas-be-usergroup/internal/core/domain/group"
+	"github.com/saas-be-usergroup/internal/core/middleware"
+	"github.com/saas-be-usergroup/internal/core/ports"
+	responseErr "github.com/saas-be-usergroup/internal/error"
+	"github.com/saas-be-usergroup/internal/response"
+)
+
+type groupHandler struct {
+	App          *fiber.App
+	groupService ports.GroupService
+}
+
+func NewGroupHandler(app *fiber.App, groupService ports.GroupService) *groupHandler {
+	return &groupHandler{
+		App:          app,
+		groupService: groupService,
+	}
+}
+
+func (g groupHandler) CreateGroup(c *fiber.Ctx) error {
+	in := group.NewUserGroupCreateRequest()
+	if err := c.BodyParser(&in); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := in.Validate(); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := g.groupService.CreateGroup(c.Context(), *in, middleware.ExportData(c.Context()).GetUUID()); err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(map[string]interface{}{
+		"message": "group created",
+	}))
+}
+
+func (g groupHandler) AddGroupMember(c *fiber.Ctx) error {
+	in := group.NewAddGroupMemberRequest()
+	if err := c.BodyParser(&in); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := in.Validate(); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := g.groupService.AddGroupMember(c.Context(), *in, middleware.ExportData(c.Context()).GetUUID()); err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(map[string]interface{}{
+		"message": "member added",
+	}))
+}
+
+func (g groupHandler) RemoveGroupMember(c *fiber.Ctx) error {
+	in := group.NewRemoveGroupMemberRequest()
+	if err := c.BodyParser(&in); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := in.Validate(); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := g.groupService.RemoveGroupMember(c.Context(), *in, middleware.ExportData(c.Context()).GetUUID()); err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(map[string]interface{}{
+		"message": "member removed",
+	}))
+}
@@ -0,0 +1,61 @@
+package mfahdl
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/saas-be-usergroup/internal/core/domain/mfa"
+	"github.com/saas-be-usergroup/internal/core/middleware"
+	"github.com/saas-be-usergroup/internal/core/ports"
+	responseErr "github.com/saas-be-usergroup/internal/error"
+	"github.com/saas-be-usergroup/internal/response"
+)
+
+type mfaHandler struct {
+	App        *fiber.App
+	mfaService ports.MFAService
+}
+
+func NewMFAHandler(app *fiber.App, mfaService ports.MFAService) *mfaHandler {
+	return &mfaHandler{
+		App:        app,
+		mfaService: mfaService,
+	}
+}
+
+func (m mfaHandler) EnrollTOTP(c *fiber.Ctx) error {
+	res, err := m.mfaService.EnrollTOTP(c.Context(), middleware.ExportData(c.Context()).GetUUID())
+	if err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(res))
+}
+
+func (m mfaHandler) VerifyTOTP(c *fiber.Ctx) error {
+	in := mfa.NewVerifyTOTPRequest()
+	if err := c.BodyParser(&in); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := in.Validate(); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	res, err := m.mfaService.VerifyTOTP(c.Context(), *in, middleware.ExportData(c.Context()).GetUUID())
+	if err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(res))
+}
+
+func (m mfaHandler) DisableTOTP(c *fiber.Ctx) error {
+	in := mfa.NewDisableTOTPRequest()
+	if err := c.BodyParser(&in); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := in.Validate(); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := m.mfaService.DisableTOTP(c.Context(), *in, middleware.ExportData(c.Context()).GetUUID()); err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(map[string]interface{}{
+		"message": "two-factor authentication has been disabled",
+	}))
+}
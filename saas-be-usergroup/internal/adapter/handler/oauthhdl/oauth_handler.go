@@ -0,0 +1,180 @@
+package oauthhdl
+
+import (
+	"encoding/base64"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/saas-be-usergroup/internal/core/domain/oauth"
+	"github.com/saas-be-usergroup/internal/core/middleware"
+	"github.com/saas-be-usergroup/internal/core/ports"
+	responseErr "github.com/saas-be-usergroup/internal/error"
+	"github.com/saas-be-usergroup/internal/response"
+	"net/url"
+	"strings"
+)
+
+type oauthHandler struct {
+	App          *fiber.App
+	oauthService ports.OAuthService
+}
+
+func NewOAuthHandler(app *fiber.App, oauthService ports.OAuthService) *oauthHandler {
+	return &oauthHandler{
+		App:          app,
+		oauthService: oauthService,
+	}
+}
+
+func (o oauthHandler) RegisterClient(c *fiber.Ctx) error {
+	in := oauth.NewRegisterClientRequest()
+	if err := c.BodyParser(&in); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := in.Validate(); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	res, err := o.oauthService.RegisterClient(c.Context(), *in)
+	if err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusCreated, response.SuccessData(res))
+}
+
+func (o oauthHandler) Authorize(c *fiber.Ctx) error {
+	in := oauth.NewAuthorizationRequest()
+	if err := c.QueryParser(in); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	res, err := o.oauthService.Authorize(c.Context(), *in, middleware.ExportData(c.Context()).GetUUID())
+	if err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(res))
+}
+
+func (o oauthHandler) Decide(c *fiber.Ctx) error {
+	in := oauth.NewDecisionRequest()
+	if err := c.BodyParser(&in); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := in.Validate(); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	res, err := o.oauthService.Decide(c.Context(), *in, middleware.ExportData(c.Context()).GetUUID())
+	if err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(res))
+}
+
+// The token, introspection and revocation endpoints are called by the client itself, they take a form and
+// answer with the plain json bodies of the rfcs instead of our envelope
+
+func (o oauthHandler) Token(c *fiber.Ctx) error {
+	in := oauth.NewTokenRequest()
+	if err := c.BodyParser(in); err != nil {
+		return oauthError(c, oauth.NewError(fiber.StatusBadRequest, oauth.ErrorInvalidRequest, err.Error()))
+	}
+	if err := setClientCredentials(c, &in.ClientCredentials); err != nil {
+		return oauthError(c, err)
+	}
+	res, err := o.oauthService.Token(c.Context(), *in)
+	if err != nil {
+		return oauthError(c, err)
+	}
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+func (o oauthHandler) Introspect(c *fiber.Ctx) error {
+	in := oauth.NewTokenIntrospectionRequest()
+	if err := c.BodyParser(in); err != nil {
+		return oauthError(c, oauth.NewError(fiber.StatusBadRequest, oauth.ErrorInvalidRequest, err.Error()))
+	}
+	if err := setClientCredentials(c, &in.ClientCredentials); err != nil {
+		return oauthError(c, err)
+	}
+	if in.GetToken() == "" {
+		return oauthError(c, oauth.NewError(fiber.StatusBadRequest, oauth.ErrorInvalidRequest, "token is required"))
+	}
+	res, err := o.oauthService.Introspect(c.Context(), *in)
+	if err != nil {
+		return oauthError(c, err)
+	}
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+func (o oauthHandler) Revoke(c *fiber.Ctx) error {
+	in := oauth.NewTokenIntrospectionRequest()
+	if err := c.BodyParser(in); err != nil {
+		return oauthError(c, oauth.NewError(fiber.StatusBadRequest, oauth.ErrorInvalidRequest, err.Error()))
+	}
+	if err := setClientCredentials(c, &in.ClientCredentials); err != nil {
+		return oauthError(c, err)
+	}
+	if in.GetToken() == "" {
+		return oauthError(c, oauth.NewError(fiber.StatusBadRequest, oauth.ErrorInvalidRequest, "token is required"))
+	}
+	if err := o.oauthService.Revoke(c.Context(), *in); err != nil {
+		return oauthError(c, err)
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+func (o oauthHandler) UserInfo(c *fiber.Ctx) error {
+	res, err := o.oauthService.UserInfo(c.Context(), middleware.ExportData(c.Context()).GetUUID(), middleware.ExportScope(c.Context()))
+	if err != nil {
+		return responseErr.Response(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+// setClientCredentials reads http basic authentication, the client id and secret are form encoded inside
+// it, rfc 6749 section 2.3.1. Sending the credentials both ways is rejected
+func setClientCredentials(c *fiber.Ctx, credentials *oauth.ClientCredentials) error {
+	header := c.Get(fiber.HeaderAuthorization)
+	if header == "" {
+		return nil
+	}
+
+	if credentials.GetClientSecret() != "" || !strings.HasPrefix(header, "Basic ") {
+		return oauth.NewError(fiber.StatusBadRequest, oauth.ErrorInvalidRequest, "use one client authentication method")
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(header, "Basic "))
+	if err != nil {
+		return oauth.NewInvalidClientError()
+	}
+
+	parts := strings.SplitN(string(decoded), ":", 2)
+	if len(parts) != 2 {
+		return oauth.NewInvalidClientError()
+	}
+
+	clientID, err := url.QueryUnescape(parts[0])
+	if err != nil {
+		return oauth.NewInvalidClientError()
+	}
+	clientSecret, err := url.QueryUnescape(parts[1])
+	if err != nil {
+		return oauth.NewInvalidClientError()
+	}
+
+	credentials.ClientID = clientID
+	credentials.ClientSecret = clientSecret
+	return nil
+}
+
+func oauthError(c *fiber.Ctx, err error) error {
+	var e *oauth.Error
+	if !errors.As(err, &e) {
+		e = oauth.NewServerError()
+	}
+
+	if e.Status == fiber.StatusUnauthorized {
+		c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="oauth"`)
+	}
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(e.Status).JSON(e)
+}
@@ -3,6 +3,7 @@ package userhdl
 import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/saas-be-usergroup/internal/core/domain/user"
+	"github.com/saas-be-usergroup/internal/core/middleware"
 	"github.com/saas-be-usergroup/internal/core/ports"
 	responseErr "github.com/saas-be-usergroup/internal/error"
 	"github.com/saas-be-usergroup/internal/response"
@@ -49,3 +50,97 @@ func (u userHandler) IsUsernameAvailable(c *fiber.Ctx) error {
 	}
 	return response.Success(c, fiber.StatusOK, response.SuccessData(res))
 }
+
+func (u userHandler) ChangeEmailBefore(c *fiber.Ctx) error {
+	in := user.NewChangeEmailBeforeRequest()
+	if err := c.BodyParser(&in); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := in.Validate(); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	res, err := u.userService.ChangeEmailBefore(c.Context(), *in, middleware.ExportData(c.Context()).GetUUID())
+	if err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(res))
+}
+
+func (u userHandler) ChangeEmailConfirmation(c *fiber.Ctx) error {
+	in := user.NewChangeEmailConfirmationRequest()
+	if err := c.BodyParser(&in); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := in.Validate(); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := u.userService.ChangeEmailConfirmation(c.Context(), *in, middleware.ExportData(c.Context()).GetUUID()); err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(map[string]interface{}{
+		"message": "email changed",
+	}))
+}
+
+func (u userHandler) ChangePasswordRequest(c *fiber.Ctx) error {
+	if err := u.userService.ChangePasswordRequest(c.Context(), middleware.ExportData(c.Context()).GetUUID()); err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(map[string]interface{}{
+		"message": "otp has been sent to your email",
+	}))
+}
+
+func (u userHandler) ChangePasswordConfirmation(c *fiber.Ctx) error {
+	in := user.NewChangePasswordConfirmationRequest()
+	if err := c.BodyParser(&in); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := in.Validate(); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	res, err := u.userService.ChangePasswordConfirmation(c.Context(), *in, middleware.ExportData(c.Context()).GetUUID())
+	if err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(res))
+}
+
+func (u userHandler) DoChangePassword(c *fiber.Ctx) error {
+	in := user.NewDoChangePasswordRequest()
+	if err := c.BodyParser(&in); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := in.Validate(); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := u.userService.DoChangePassword(c.Context(), *in, middleware.ExportData(c.Context()).GetUUID()); err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(map[string]interface{}{
+		"message": "password changed",
+	}))
+}
+
+func (u userHandler) GetUser(c *fiber.Ctx) error {
+	res, err := u.userService.GetUser(c.Context(), middleware.ExportData(c.Context()).GetUUID())
+	if err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(res))
+}
+
+func (u userHandler) UpdateUser(c *fiber.Ctx) error {
+	in := user.NewUpdateRequest()
+	if err := c.BodyParser(&in); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	if err := in.Validate(); err != nil {
+		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
+	}
+	res, err := u.userService.UpdateUser(c.Context(), *in, middleware.ExportData(c.Context()).GetUUID())
+	if err != nil {
+		return responseErr.Response(c, err)
+	}
+	return response.Success(c, fiber.StatusOK, response.SuccessData(res))
+}
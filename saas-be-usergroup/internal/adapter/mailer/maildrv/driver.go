@@ -0,0 +1,32 @@
+package maildrv
+
+import (
+	"fmt"
+
+	"github.com/saas-be-usergroup/internal/core/ports"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+const (
+	DriverSMTP   = "smtp"
+	DriverFile   = "file"
+	DriverLog    = "log"
+	DriverMemory = "memory"
+)
+
+// New returns the mail transport selected by mailer.driver, smtp is used when it is not set
+func New(logger *zap.Logger) (ports.Mailer, error) {
+	switch driver := viper.GetString("mailer.driver"); driver {
+	case "", DriverSMTP:
+		return NewSMTPMailer(), nil
+	case DriverFile:
+		return NewFileMailer(viper.GetString("mailer.file_dir"))
+	case DriverLog:
+		return NewLogMailer(logger), nil
+	case DriverMemory:
+		return NewMemoryMailer(), nil
+	default:
+		return nil, fmt.Errorf("unknown mailer driver %q", driver)
+	}
+}
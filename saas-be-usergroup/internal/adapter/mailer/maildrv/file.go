@@ -0,0 +1,39 @@
+package maildrv
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/saas-be-usergroup/internal/core/domain/mailer"
+)
+
+// fileMailer writes every mail as an .eml file, it can be opened by any mail client
+type fileMailer struct {
+	dir    string
+	config *mailer.SMTPConfig
+}
+
+func NewFileMailer(dir string) (*fileMailer, error) {
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "saas-be-usergroup-mail")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &fileMailer{dir: dir, config: mailer.NewSMTPConfig()}, nil
+}
+
+func (f fileMailer) Send(ctx context.Context, m mailer.Mailer) error {
+	msg, err := m.BuildMessage(f.config.GetFrom())
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102T150405"), uuid.New().String())
+	return os.WriteFile(filepath.Join(f.dir, name), msg, 0o644)
+}
@@ -0,0 +1,32 @@
+package maildrv
+
+import (
+	"context"
+
+	"github.com/saas-be-usergroup/internal/core/domain/mailer"
+	"go.uber.org/zap"
+)
+
+// logMailer only logs the plain-text body, it should never be used in production
+type logMailer struct {
+	logger *zap.Logger
+}
+
+func NewLogMailer(logger *zap.Logger) *logMailer {
+	return &logMailer{logger: logger}
+}
+
+func (l logMailer) Send(ctx context.Context, m mailer.Mailer) error {
+	_, text, err := m.Render()
+	if err != nil {
+		return err
+	}
+
+	l.logger.Info("mail sent",
+		zap.String("recipient", m.Recipient),
+		zap.String("subject", m.Subject),
+		zap.String("template", m.Template),
+		zap.String("body", text),
+	)
+	return nil
+}
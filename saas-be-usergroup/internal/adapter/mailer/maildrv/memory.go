@@ -0,0 +1,56 @@
+package maildrv
+
+import (
+	"context"
+	"sync"
+
+	"github.com/saas-be-usergroup/internal/core/domain/mailer"
+)
+
+// MemoryMailer keeps every sent mail in an outbox so tests can assert against it
+type MemoryMailer struct {
+	mu     sync.Mutex
+	outbox []mailer.Mailer
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (m *MemoryMailer) Send(ctx context.Context, mail mailer.Mailer) error {
+	// render like the other drivers so a broken template still fails
+	if _, _, err := mail.Render(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.outbox = append(m.outbox, mail)
+	return nil
+}
+
+func (m *MemoryMailer) Outbox() []mailer.Mailer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]mailer.Mailer(nil), m.outbox...)
+}
+
+// SentTo returns the mails sent to the recipient in sending order
+func (m *MemoryMailer) SentTo(recipient string) []mailer.Mailer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var sent []mailer.Mailer
+	for _, mail := range m.outbox {
+		if mail.Recipient == recipient {
+			sent = append(sent, mail)
+		}
+	}
+	return sent
+}
+
+func (m *MemoryMailer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.outbox = nil
+}
@@ -0,0 +1,27 @@
+package maildrv
+
+import (
+	"context"
+
+	"github.com/saas-be-usergroup/internal/core/domain/mailer"
+	"gorm.io/gorm"
+)
+
+// outboxMailer only stores the mail, it is delivered later by the outbox worker
+type outboxMailer struct {
+	db *gorm.DB
+}
+
+func NewOutboxMailer(db *gorm.DB) *outboxMailer {
+	return &outboxMailer{db: db}
+}
+
+func (o outboxMailer) Send(ctx context.Context, m mailer.Mailer) error {
+	outbox, err := m.ToEmailOutbox()
+	if err != nil {
+		return err
+	}
+
+	_, err = outbox.Create(mailer.TxFromContext(ctx, o.db))
+	return err
+}
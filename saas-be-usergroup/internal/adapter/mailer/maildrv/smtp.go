@@ -0,0 +1,19 @@
+package maildrv
+
+import (
+	"context"
+
+	"github.com/saas-be-usergroup/internal/core/domain/mailer"
+)
+
+type smtpMailer struct {
+	config *mailer.SMTPConfig
+}
+
+func NewSMTPMailer() *smtpMailer {
+	return &smtpMailer{config: mailer.NewSMTPConfig()}
+}
+
+func (s smtpMailer) Send(ctx context.Context, m mailer.Mailer) error {
+	return s.config.Deliver(ctx, m)
+}
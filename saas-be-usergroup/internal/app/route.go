@@ -3,11 +3,21 @@ package app
 import (
 	"github.com/go-redis/redis/v8"
 	"github.com/saas-be-usergroup/internal/adapter/handler/authhdl"
+	"github.com/saas-be-usergroup/internal/adapter/handler/grouphdl"
+	"github.com/saas-be-usergroup/internal/adapter/handler/mfahdl"
+	"github.com/saas-be-usergroup/internal/adapter/handler/oauthhdl"
 	"github.com/saas-be-usergroup/internal/adapter/handler/userhdl"
+	"github.com/saas-be-usergroup/internal/core/domain/oauth"
+	"github.com/saas-be-usergroup/internal/core/domain/ratelimit"
 	"github.com/saas-be-usergroup/internal/core/middleware"
+	"github.com/saas-be-usergroup/internal/core/ports"
 	"github.com/saas-be-usergroup/internal/core/services/authsvc"
+	"github.com/saas-be-usergroup/internal/core/services/groupsvc"
+	"github.com/saas-be-usergroup/internal/core/services/mfasvc"
+	"github.com/saas-be-usergroup/internal/core/services/oauthsvc"
 	"github.com/saas-be-usergroup/internal/core/services/usersvc"
 	"gorm.io/gorm"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"go.uber.org/zap"
@@ -16,6 +26,7 @@ import (
 type Handlers struct {
 	Postgres *gorm.DB
 	Redis    *redis.Client
+	Mailer   ports.Mailer
 	R        *fiber.App
 	Logger   *zap.Logger
 }
@@ -23,32 +34,120 @@ type Handlers struct {
 const apiVerion string = "api/v1"
 
 func (h *Handlers) SetupRouter() {
+	// rate limit policies, ratelimit.policies.<name> in config overrides the limit and the period
+	limit := func(name string, limit int64, period time.Duration, keyFunc middleware.KeyFunc) fiber.Handler {
+		return middleware.RateLimit(h.Redis, h.Logger, ratelimit.NewPolicy(name, limit, period), keyFunc)
+	}
+
+	// the global policy is registered first so it covers every route
+	h.R.Use(limit("global", 300, time.Minute, middleware.KeyByIP))
+
 	h.R.Get("/health", func(c *fiber.Ctx) error {
 		return c.SendString("OK")
 	})
 
 	//initialize bussiness
-	authService := authsvc.NewAuthService(h.Postgres, h.Redis, h.Logger)
-	userService := usersvc.NewUserService(h.Postgres, h.Logger)
+	authService := authsvc.NewAuthService(h.Postgres, h.Redis, h.Mailer, h.Logger)
+	userService := usersvc.NewUserService(h.Postgres, h.Redis, h.Mailer, h.Logger)
+	groupService := groupsvc.NewGroupService(h.Postgres, h.Logger)
+	mfaService := mfasvc.NewMFAService(h.Postgres, h.Redis, h.Logger)
+	oauthService := oauthsvc.NewOAuthService(h.Postgres, h.Redis, h.Logger)
 
 	//handlers initialize
 	authHandler := authhdl.NewAuthHandler(h.R, authService)
 	userHandler := userhdl.NewUserHandler(h.R, userService)
+	groupHandler := grouphdl.NewGroupHandler(h.R, groupService)
+	mfaHandler := mfahdl.NewMFAHandler(h.R, mfaService)
+	oauthHandler := oauthhdl.NewOAuthHandler(h.R, oauthService)
+
+	// Public keys verifying the access tokens
+	authHandler.App.Get("/.well-known/jwks.json", authHandler.JWKS)
 
 	// Auth
 	authApi := authHandler.App.Group(apiVerion + "/auth")
 	// Register
-	authApi.Post("/register/before", authHandler.RegisterBeforeWithEmail)
+	authApi.Post("/register/before",
+		limit("register_before_ip", 10, time.Hour, middleware.KeyByIP),
+		limit("register_before_email", 3, time.Minute*10, middleware.KeyByEmail),
+		authHandler.RegisterBeforeWithEmail)
+	authApi.Post("/register/resend", limit("register_resend", 10, time.Hour, middleware.KeyByIP), authHandler.ResendRegisterOTP)
 	authApi.Post("/register/confirmation", authHandler.ConfirmationRegister)
 	authApi.Post("/register/do", authHandler.DoRegister)
 	// Login
-	authApi.Post("/login/do", authHandler.DoLogin)
+	authApi.Post("/login/do", limit("login", 20, time.Minute, middleware.KeyByIP), authHandler.DoLogin)
+	authApi.Post("/login/mfa", limit("login_mfa", 20, time.Minute, middleware.KeyByIP), authHandler.DoLoginMFA)
+	authApi.Post("/login/magic",
+		limit("login_magic_ip", 10, time.Hour, middleware.KeyByIP),
+		limit("login_magic_email", 3, time.Minute*10, middleware.KeyByEmail),
+		authHandler.RequestMagicLink)
+	authApi.Post("/login/magic/verify", limit("login_magic_verify", 20, time.Minute, middleware.KeyByIP), authHandler.VerifyMagicLink)
+	webauthnLoginLimit := limit("login_webauthn", 40, time.Minute, middleware.KeyByIP)
+	authApi.Post("/login/webauthn/begin", webauthnLoginLimit, authHandler.BeginWebAuthnLogin)
+	authApi.Post("/login/webauthn/finish", webauthnLoginLimit, authHandler.FinishWebAuthnLogin)
+	oidcLimit := limit("login_oidc", 20, time.Minute, middleware.KeyByIP)
+	authApi.Get("/oidc/:provider/begin", oidcLimit, authHandler.BeginOIDCLogin)
+	authApi.Post("/oidc/:provider/callback", oidcLimit, authHandler.FinishOIDCLogin)
 	authApi.Post("/refresh", authHandler.DoRefreshToken)
-	authApi.Post("/logout", middleware.Protected(), authHandler.DoLogout)
+	authApi.Post("/logout", middleware.Protected(h.Redis), authHandler.DoLogout)
+	// Password
+	authApi.Post("/password/forgot",
+		limit("password_forgot_ip", 10, time.Hour, middleware.KeyByIP),
+		limit("password_forgot_email", 3, time.Minute*10, middleware.KeyByEmail),
+		authHandler.ForgotPassword)
+	authApi.Post("/password/reset", authHandler.ResetPassword)
 
 	// User
+	// a group middleware would cover every route under the prefix, the availability limit is set per route
+	availabilityLimit := limit("availability", 10, time.Minute, middleware.KeyByIP)
 	userApiPublic := userHandler.App.Group(apiVerion)
-	userApiPublic.Post("/email/available", userHandler.IsEmailAvailable)
-	userApiPublic.Post("/username/available", userHandler.IsUsernameAvailable)
+	userApiPublic.Post("/email/available", availabilityLimit, userHandler.IsEmailAvailable)
+	userApiPublic.Post("/username/available", availabilityLimit, userHandler.IsUsernameAvailable)
+	userApi := userHandler.App.Group(apiVerion+"/me", middleware.Protected(h.Redis), limit("me", 120, time.Minute, middleware.KeyByUser))
+	userApi.Get("/", userHandler.GetUser)
+	userApi.Patch("/", userHandler.UpdateUser)
+	userApi.Post("/email/change", userHandler.ChangeEmailBefore)
+	userApi.Post("/email/confirmation", userHandler.ChangeEmailConfirmation)
+	userApi.Post("/password/change", userHandler.ChangePasswordRequest)
+	userApi.Post("/password/confirmation", userHandler.ChangePasswordConfirmation)
+	userApi.Post("/password/do", userHandler.DoChangePassword)
+	// Two-factor
+	userApi.Post("/2fa/enroll", mfaHandler.EnrollTOTP)
+	userApi.Post("/2fa/verify", mfaHandler.VerifyTOTP)
+	userApi.Post("/2fa/disable", mfaHandler.DisableTOTP)
+	// Passkey
+	userApi.Post("/webauthn/register/begin", authHandler.BeginWebAuthnRegistration)
+	userApi.Post("/webauthn/register/finish", authHandler.FinishWebAuthnRegistration)
+	// Social login, an account of the same email is only linked here
+	userApi.Get("/oidc/:provider/begin", authHandler.BeginOIDCLink)
+	userApi.Post("/oidc/:provider/callback", authHandler.FinishOIDCLink)
+	// Session
+	userApi.Get("/sessions", authHandler.GetSessions)
+	userApi.Post("/sessions/revoke", authHandler.RevokeSession)
+	userApi.Post("/sessions/revoke-all", authHandler.RevokeAllSessions)
+
+	// Admin
+	adminApi := authHandler.App.Group(apiVerion+"/admin", middleware.AdminProtected())
+	adminApi.Post("/accounts/unlock", authHandler.UnlockAccount)
+	adminApi.Post("/accounts/suspend", authHandler.SuspendAccount)
+	adminApi.Post("/accounts/reactivate", authHandler.ReactivateAccount)
+	adminApi.Post("/accounts/delete", authHandler.DeleteAccount)
+	adminApi.Post("/oauth/clients", oauthHandler.RegisterClient)
+
+	// OAuth2 provider
+	oauthApi := oauthHandler.App.Group(apiVerion + "/oauth")
+	// the consent screen of the frontend calls these for the logged in user
+	oauthApi.Get("/authorize", middleware.Protected(h.Redis), oauthHandler.Authorize)
+	oauthApi.Post("/authorize/decision", middleware.Protected(h.Redis), oauthHandler.Decide)
+	// the third-party clients call these
+	clientLimit := limit("oauth_client", 60, time.Minute, middleware.KeyByIP)
+	oauthApi.Post("/token", clientLimit, oauthHandler.Token)
+	oauthApi.Post("/introspect", clientLimit, oauthHandler.Introspect)
+	oauthApi.Post("/revoke", clientLimit, oauthHandler.Revoke)
+	oauthApi.Get("/userinfo", middleware.OAuthProtected(h.Redis, oauth.ScopeProfile), oauthHandler.UserInfo)
 
+	// Group
+	groupApi := groupHandler.App.Group(apiVerion+"/groups", middleware.Protected(h.Redis))
+	groupApi.Post("/", groupHandler.CreateGroup)
+	groupApi.Post("/members/add", groupHandler.AddGroupMember)
+	groupApi.Post("/members/remove", groupHandler.RemoveGroupMember)
 }
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func breachedHash(password string) (prefix string, suffix string) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	return hash[:breachedPrefixLength], hash[breachedPrefixLength:]
+}
+
+// writeBreachedFixture writes a range file holding the passwords and returns its directory
+func writeBreachedFixture(t *testing.T, passwords ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	files := make(map[string][]string)
+	for _, password := range passwords {
+		prefix, suffix := breachedHash(password)
+		files[prefix] = append(files[prefix], suffix+":42")
+	}
+	for prefix, lines := range files {
+		if err := os.WriteFile(filepath.Join(dir, prefix+".txt"), []byte(strings.Join(lines, "\r\n")+"\r\n"), 0o600); err != nil {
+			t.Fatalf("write fixture: %v", err)
+		}
+	}
+	return dir
+}
+
+func TestIsPasswordBreached(t *testing.T) {
+	prefix, suffix := breachedHash("password")
+	_, otherSuffix := breachedHash("correct horse battery staple")
+
+	tests := []struct {
+		name     string
+		lines    []string
+		password string
+		want     bool
+	}{
+		{name: "suffix with count", lines: []string{otherSuffix + ":3", suffix + ":9545824"}, password: "password", want: true},
+		{name: "suffix without count", lines: []string{suffix}, password: "password", want: true},
+		{name: "lowercase suffix", lines: []string{strings.ToLower(suffix) + ":1"}, password: "password", want: true},
+		{name: "padded line", lines: []string{"  " + suffix + ":1  "}, password: "password", want: true},
+		{name: "suffix not listed", lines: []string{otherSuffix + ":3"}, password: "password", want: false},
+		{name: "empty range file", lines: nil, password: "password", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, prefix+".txt"), []byte(strings.Join(tt.lines, "\n")), 0o600); err != nil {
+				t.Fatalf("write fixture: %v", err)
+			}
+
+			got, err := IsPasswordBreached(dir, tt.password)
+			if err != nil {
+				t.Fatalf("IsPasswordBreached() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("IsPasswordBreached() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsPasswordBreachedMissingRangeFile(t *testing.T) {
+	breached, err := IsPasswordBreached(t.TempDir(), "password")
+	if err != nil {
+		t.Fatalf("IsPasswordBreached() error = %v", err)
+	}
+	if breached {
+		t.Error("IsPasswordBreached() = true, want false when the range file is missing")
+	}
+}
+
+func TestIsPasswordBreachedUsesTheRangeFileOfThePrefix(t *testing.T) {
+	dir := writeBreachedFixture(t, "password")
+
+	breached, err := IsPasswordBreached(dir, "Tr0ub4dor&3")
+	if err != nil {
+		t.Fatalf("IsPasswordBreached() error = %v", err)
+	}
+	if breached {
+		t.Error("IsPasswordBreached() = true, want false for a password of another prefix")
+	}
+
+	breached, err = IsPasswordBreached(dir, "password")
+	if err != nil {
+		t.Fatalf("IsPasswordBreached() error = %v", err)
+	}
+	if !breached {
+		t.Error("IsPasswordBreached() = false, want true")
+	}
+}
+
+func TestIsPasswordBreachedUnreadableDir(t *testing.T) {
+	prefix, _ := breachedHash("password")
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, prefix+".txt"), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	if _, err := IsPasswordBreached(dir, "password"); err == nil {
+		t.Error("IsPasswordBreached() error = nil, want the read error of the range file")
+	}
+}
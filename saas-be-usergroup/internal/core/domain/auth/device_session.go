@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"strconv"
+	"time"
+)
+
+// SessionMeta describes the client a login or a refresh comes from
+type SessionMeta struct {
+	Device    string
+	IP        string
+	UserAgent string
+}
+
+func NewSessionMeta(device string, ip string, userAgent string) *SessionMeta {
+	return &SessionMeta{
+		Device:    device,
+		IP:        ip,
+		UserAgent: userAgent,
+	}
+}
+
+// DeviceSession is one login, it shares its id with the refresh token family
+type DeviceSession struct {
+	ID         string
+	UUID       string
+	Device     string
+	IP         string
+	UserAgent  string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+}
+
+func newDeviceSessionFromHash(id string, values map[string]string) *DeviceSession {
+	return &DeviceSession{
+		ID:         id,
+		UUID:       values["uuid"],
+		Device:     values["device"],
+		IP:         values["ip"],
+		UserAgent:  values["user_agent"],
+		CreatedAt:  parseUnix(values["created_at"]),
+		LastUsedAt: parseUnix(values["last_used_at"]),
+	}
+}
+
+func parseUnix(value string) time.Time {
+	unix, _ := strconv.ParseInt(value, 10, 64)
+	return time.Unix(unix, 0)
+}
+
+func (d *DeviceSession) IsEmpty() bool {
+	return d == nil
+}
+
+func (d DeviceSession) GetID() string {
+	return d.ID
+}
+
+func (d DeviceSession) GetUUID() string {
+	return d.UUID
+}
+
+func (d DeviceSession) IsOwnedBy(uuid string) bool {
+	return d.UUID == uuid
+}
+
+func (d DeviceSession) ToDeviceSessionResponse(currentSessionID string) DeviceSessionResponse {
+	return DeviceSessionResponse{
+		SessionID:  d.ID,
+		Device:     d.Device,
+		IP:         d.IP,
+		UserAgent:  d.UserAgent,
+		CreatedAt:  d.CreatedAt,
+		LastUsedAt: d.LastUsedAt,
+		Current:    d.ID == currentSessionID,
+	}
+}
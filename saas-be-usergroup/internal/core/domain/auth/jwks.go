@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+)
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// GetJWKS publishes every asymmetric verification key, a hmac secret is never exposed
+func GetJWKS() *JWKS {
+	keySet := getAccessKeySet()
+
+	jwks := &JWKS{Keys: []JWK{}}
+	for _, kid := range keySet.order {
+		key := keySet.keys[kid]
+		if !key.IsAsymmetric() {
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, key.ToJWK())
+	}
+
+	return jwks
+}
+
+func (s SigningKey) ToJWK() JWK {
+	jwk := JWK{
+		Kid: s.KID,
+		Use: "sig",
+		Alg: s.Method.Alg(),
+	}
+
+	switch publicKey := s.PublicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+	}
+
+	return jwk
+}
@@ -3,20 +3,76 @@ package auth
 import (
 	"context"
 	"errors"
-	"github.com/dgrijalva/jwt-go"
 	"github.com/go-redis/redis/v8"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 	"github.com/spf13/viper"
 	"time"
 )
 
+type TokenType string
+
+const (
+	TokenAccess  TokenType = "access"
+	TokenRefresh TokenType = "refresh"
+)
+
+var (
+	defaultIssuer     string            = "saas-be-usergroup"
+	defaultAudience   string            = "saas-be-usergroup"
+	defaultAccessTTL                    = time.Minute * 15
+	defaultRefreshTTL                   = time.Hour * 24 * 30 // 1 month
+	jwtSigningMethod  jwt.SigningMethod = jwt.SigningMethodHS256
+)
+
 var (
-	claimsIssuerAccess  string            = "SaaS-JWT-Access"
-	claimsIssuerRefresh string            = "SaaS-JWT-Refresh"
-	jwtExpiresAt        int64             = time.Now().Add(time.Duration(1) * time.Hour * 24 * 30).Unix() // 1 month
-	jwtSigningMethod    jwt.SigningMethod = jwt.SigningMethodHS256
+	ErrInvalidToken        = errors.New("invalid token")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
 )
 
+func getIssuer() string {
+	if issuer := viper.GetString("jwt.issuer"); issuer != "" {
+		return issuer
+	}
+	return defaultIssuer
+}
+
+func getAudience() string {
+	if audience := viper.GetString("jwt.audience"); audience != "" {
+		return audience
+	}
+	return defaultAudience
+}
+
+func getAccessTTL() time.Duration {
+	if ttl := viper.GetDuration("jwt.access_ttl"); ttl > 0 {
+		return ttl
+	}
+	return defaultAccessTTL
+}
+
+func getRefreshTTL() time.Duration {
+	if ttl := viper.GetDuration("jwt.refresh_ttl"); ttl > 0 {
+		return ttl
+	}
+	return defaultRefreshTTL
+}
+
+func (t TokenType) getSecret() []byte {
+	if t == TokenRefresh {
+		return []byte(viper.GetString("jwt.refresh_secret"))
+	}
+	return []byte(viper.GetString("jwt.access_secret"))
+}
+
+func (t TokenType) getTTL() time.Duration {
+	if t == TokenRefresh {
+		return getRefreshTTL()
+	}
+	return getAccessTTL()
+}
+
 type JWT struct {
 	RefreshToken string `json:"refresh_token"`
 	AccessToken  string `json:"access_token"`
@@ -28,7 +84,7 @@ func NewJWT() *JWT {
 }
 
 func (j *JWT) setAccessToken(accessToken string) {
-	j.RefreshToken = accessToken
+	j.AccessToken = accessToken
 }
 
 func (j *JWT) setRefreshToken(refreshToken string) {
@@ -65,73 +121,167 @@ func (g JWT) ToDoRefreshTokenResponse() *DoRefreshTokenResponse {
 
 type JWTClaims struct {
 	jwt.StandardClaims
-	UUID string `json:"uuid"`
+	UUID      string    `json:"uuid"`
+	TokenType TokenType `json:"token_type"`
+	Version   int64     `json:"ver,omitempty"`
+	SessionID string    `json:"sid,omitempty"`
+	ClientID  string    `json:"client_id,omitempty"`
+	Scope     string    `json:"scope,omitempty"`
 }
 
-func newJWTClaimsAccess(uuid string) *JWTClaims {
+func newJWTClaims(tokenType TokenType, uuid string) *JWTClaims {
 	claims := &JWTClaims{}
-	claims.setStandardClaimsAccess()
+	claims.setStandardClaims(tokenType, uuid)
 	claims.setUUID(uuid)
+	claims.setTokenType(tokenType)
 	return claims
 }
 
-func newJWTClaimsRefresh(uuid string) *JWTClaims {
-	claims := &JWTClaims{}
-	claims.setStandardClaimsAccess()
-	claims.setUUID(uuid)
-	return claims
-}
-
-func (j *JWTClaims) setStandardClaimsAccess() {
+func (j *JWTClaims) setStandardClaims(tokenType TokenType, subject string) {
+	now := time.Now()
 	j.StandardClaims = jwt.StandardClaims{
-		Issuer:    claimsIssuerAccess,
-		ExpiresAt: jwtExpiresAt,
+		Issuer:    getIssuer(),
+		Audience:  getAudience(),
+		Subject:   subject,
+		IssuedAt:  now.Unix(),
+		NotBefore: now.Unix(),
+		ExpiresAt: now.Add(tokenType.getTTL()).Unix(),
 		Id:        uuid.New().String()}
 }
 
-func (j *JWTClaims) setStandardClaimsRefresh() {
-	j.StandardClaims = jwt.StandardClaims{
-		Issuer: claimsIssuerRefresh,
-		Id:     uuid.New().String()}
-}
-
 func (j *JWTClaims) setUUID(uuid string) {
 	j.UUID = uuid
 }
 
-func (j *JWT) Generate(ctx context.Context, client *redis.Client, uuid string) (*JWT, error) {
-	accessClaims := *newJWTClaimsAccess(uuid)
-	accessToken, err := jwt.NewWithClaims(jwtSigningMethod, accessClaims).SignedString([]byte(viper.GetString("jwt.access_secret")))
+func (j *JWTClaims) setTokenType(tokenType TokenType) {
+	j.TokenType = tokenType
+}
+
+func (j JWTClaims) GetUUID() string {
+	return j.UUID
+}
+
+func (j *JWTClaims) setVersion(version int64) {
+	j.Version = version
+}
+
+func (j *JWTClaims) setSessionID(sessionID string) {
+	j.SessionID = sessionID
+}
+
+func (j JWTClaims) GetJTI() string {
+	return j.Id
+}
+
+func (j JWTClaims) GetVersion() int64 {
+	return j.Version
+}
+
+func (j JWTClaims) GetSessionID() string {
+	return j.SessionID
+}
+
+func (j JWTClaims) GetExpiresAt() time.Time {
+	return time.Unix(j.ExpiresAt, 0)
+}
+
+func (j JWTClaims) GetIssuedAt() time.Time {
+	return time.Unix(j.IssuedAt, 0)
+}
+
+func (j JWTClaims) GetClientID() string {
+	return j.ClientID
+}
+
+func (j JWTClaims) GetScope() string {
+	return j.Scope
+}
+
+// IsClientToken tells a token issued to a third-party client from one of our own logins
+func (j JWTClaims) IsClientToken() bool {
+	return j.ClientID != ""
+}
+
+// Valid checks the standard time based claims, the issuer and the audience
+func (j JWTClaims) Valid() error {
+	if err := j.StandardClaims.Valid(); err != nil {
+		return err
+	}
+	if !j.VerifyIssuer(getIssuer(), true) || !j.VerifyAudience(getAudience(), true) {
+		return ErrInvalidToken
+	}
+	// a client_credentials token acts for the client alone and carries no user
+	if (j.UUID == "" && j.ClientID == "") || j.Id == "" {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// sign uses the access key set for access tokens, refresh tokens are only read by this service
+// so they stay on hmac with jwt.refresh_secret
+func (j *JWTClaims) sign() (string, error) {
+	if j.TokenType == TokenRefresh {
+		return jwt.NewWithClaims(jwtSigningMethod, j).SignedString(j.TokenType.getSecret())
+	}
+	return getAccessKeySet().getSigningKey().sign(j)
+}
+
+// Generate issues a token pair starting a new refresh token family, the family is the login session
+func (j *JWT) Generate(ctx context.Context, client *redis.Client, uuid string, meta SessionMeta) (*JWT, error) {
+	return j.GenerateInFamily(ctx, client, uuid, newFamily(), meta)
+}
+
+// GenerateInFamily issues a token pair whose refresh token is rotated from the family
+func (j *JWT) GenerateInFamily(ctx context.Context, client *redis.Client, uuid string, family string, meta SessionMeta) (*JWT, error) {
+	version, err := GetTokenVersion(ctx, client, uuid)
 	if err != nil {
 		return nil, err
 	}
 
-	refreshClaims := *newJWTClaimsRefresh(uuid)
-	refreshToken, err := jwt.NewWithClaims(jwtSigningMethod, refreshClaims).SignedString([]byte(viper.GetString("jwt.refresh_secret")))
+	accessClaims := newJWTClaims(TokenAccess, uuid)
+	accessClaims.setVersion(version)
+	accessClaims.setSessionID(family)
+	accessToken, err := accessClaims.sign()
 	if err != nil {
 		return nil, err
 	}
 
-	if err = client.Set(ctx, refreshClaims.StandardClaims.Id, refreshClaims.UUID, 0).Err(); err != nil {
+	refreshClaims := newJWTClaims(TokenRefresh, uuid)
+	refreshToken, err := refreshClaims.sign()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = storeRefreshToken(ctx, client, uuid, family, refreshClaims.GetJTI()); err != nil {
+		return nil, err
+	}
+
+	if err = storeDeviceSession(ctx, client, uuid, family, meta); err != nil {
 		return nil, err
 	}
 
 	j.setAccessToken(accessToken)
 	j.setRefreshToken(refreshToken)
-	j.setExpiresIn(accessClaims.ExpiresAt)
+	j.setExpiresIn(int64(getAccessTTL().Seconds()))
 
 	return j, nil
 }
 
+func newFamily() string {
+	return uuid.New().String()
+}
+
 type RefreshTokenDataClaims struct {
-	UUID string
-	JTI  string
+	UUID   string
+	JTI    string
+	Family string
 }
 
-func NewRefreshTokenDataClaims(uuid string, jti string) *RefreshTokenDataClaims {
+func NewRefreshTokenDataClaims(uuid string, jti string, family string) *RefreshTokenDataClaims {
 	return &RefreshTokenDataClaims{
-		UUID: uuid,
-		JTI:  jti,
+		UUID:   uuid,
+		JTI:    jti,
+		Family: family,
 	}
 }
 
@@ -143,13 +293,40 @@ func (r RefreshTokenDataClaims) GetJTI() string {
 	return r.JTI
 }
 
-func jwtParse(token string, secret string) (*jwt.Token, error) {
-	return jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
-		if method, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("invalid token")
-		} else if method != jwtSigningMethod {
-			return nil, errors.New("invalid token")
+func (r RefreshTokenDataClaims) GetFamily() string {
+	return r.Family
+}
+
+// ParseAccessToken verifies the signature and claims of an access token
+func ParseAccessToken(token string) (*JWTClaims, error) {
+	return jwtParse(token, TokenAccess)
+}
+
+func jwtParse(token string, tokenType TokenType) (*JWTClaims, error) {
+	claims := &JWTClaims{}
+	if _, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if tokenType == TokenRefresh {
+			if t.Method != jwtSigningMethod {
+				return nil, ErrInvalidToken
+			}
+			return tokenType.getSecret(), nil
+		}
+
+		// the kid picks the verification key, its algorithm must match the header to avoid algorithm confusion
+		kid, _ := t.Header["kid"].(string)
+		key, ok := getAccessKeySet().getVerificationKey(kid)
+		if !ok || t.Method.Alg() != key.Method.Alg() {
+			return nil, ErrInvalidToken
 		}
-		return []byte(secret), nil
-	})
+		return key.PublicKey, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	// an access token must never be accepted as a refresh token and the other way around
+	if claims.TokenType != tokenType {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
 }
@@ -0,0 +1,207 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/spf13/viper"
+)
+
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+var (
+	ErrUnknownSigningKey       = errors.New("signing kid is not one of the configured keys")
+	ErrSigningKeyNoPrivate     = errors.New("signing key has no private key")
+	ErrUnsupportedKeyAlgorithm = errors.New("unsupported key algorithm")
+)
+
+// KeyConfig is one entry of jwt.keys, a key kept only for verification during rotation
+// may leave private_key_file empty
+type KeyConfig struct {
+	KID            string `mapstructure:"kid"`
+	Algorithm      string `mapstructure:"algorithm"`
+	PrivateKeyFile string `mapstructure:"private_key_file"`
+	PublicKeyFile  string `mapstructure:"public_key_file"`
+}
+
+type SigningKey struct {
+	KID        string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.PrivateKey
+	PublicKey  crypto.PublicKey
+}
+
+func (s SigningKey) IsAsymmetric() bool {
+	return s.KID != ""
+}
+
+type KeySet struct {
+	signing *SigningKey
+	keys    map[string]*SigningKey
+	order   []string
+}
+
+var (
+	accessKeySet   *KeySet
+	accessKeySetMu sync.RWMutex
+)
+
+// LoadKeySet reads jwt.keys and jwt.signing_kid, without any configured key the access tokens
+// keep being signed with HS256 and jwt.access_secret
+func LoadKeySet() error {
+	keySet, err := newKeySetFromConfig()
+	if err != nil {
+		return err
+	}
+
+	accessKeySetMu.Lock()
+	accessKeySet = keySet
+	accessKeySetMu.Unlock()
+	return nil
+}
+
+func getAccessKeySet() *KeySet {
+	accessKeySetMu.RLock()
+	keySet := accessKeySet
+	accessKeySetMu.RUnlock()
+
+	if keySet == nil {
+		return newHMACKeySet()
+	}
+	return keySet
+}
+
+func newHMACKeySet() *KeySet {
+	secret := TokenAccess.getSecret()
+	key := &SigningKey{Method: jwtSigningMethod, PrivateKey: secret, PublicKey: secret}
+	return &KeySet{signing: key, keys: map[string]*SigningKey{"": key}}
+}
+
+func newKeySetFromConfig() (*KeySet, error) {
+	var configs []KeyConfig
+	if err := viper.UnmarshalKey("jwt.keys", &configs); err != nil {
+		return nil, err
+	}
+	if len(configs) == 0 {
+		return newHMACKeySet(), nil
+	}
+
+	keySet := &KeySet{keys: make(map[string]*SigningKey, len(configs))}
+	for _, config := range configs {
+		key, err := config.load()
+		if err != nil {
+			return nil, fmt.Errorf("jwt key %q: %w", config.KID, err)
+		}
+		if _, ok := keySet.keys[key.KID]; ok {
+			return nil, fmt.Errorf("jwt key %q: duplicate kid", key.KID)
+		}
+		keySet.keys[key.KID] = key
+		keySet.order = append(keySet.order, key.KID)
+	}
+
+	signing, ok := keySet.keys[viper.GetString("jwt.signing_kid")]
+	if !ok {
+		return nil, ErrUnknownSigningKey
+	}
+	if signing.PrivateKey == nil {
+		return nil, ErrSigningKeyNoPrivate
+	}
+	keySet.signing = signing
+
+	return keySet, nil
+}
+
+func (k KeyConfig) load() (*SigningKey, error) {
+	if k.KID == "" {
+		return nil, errors.New("kid is required")
+	}
+	if k.PrivateKeyFile == "" && k.PublicKeyFile == "" {
+		return nil, errors.New("private_key_file or public_key_file is required")
+	}
+
+	key := &SigningKey{KID: k.KID}
+	switch k.Algorithm {
+	case AlgorithmRS256:
+		key.Method = jwt.SigningMethodRS256
+	case AlgorithmEdDSA:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, ErrUnsupportedKeyAlgorithm
+	}
+
+	if k.PrivateKeyFile != "" {
+		pem, err := ioutil.ReadFile(k.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if key.PrivateKey, key.PublicKey, err = parsePrivateKey(k.Algorithm, pem); err != nil {
+			return nil, err
+		}
+	}
+
+	// an explicit public key takes over the one derived from the private key
+	if k.PublicKeyFile != "" {
+		pem, err := ioutil.ReadFile(k.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if key.PublicKey, err = parsePublicKey(k.Algorithm, pem); err != nil {
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+func parsePrivateKey(algorithm string, pem []byte) (crypto.PrivateKey, crypto.PublicKey, error) {
+	if algorithm == AlgorithmRS256 {
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+		if err != nil {
+			return nil, nil, err
+		}
+		return privateKey, &privateKey.PublicKey, nil
+	}
+
+	privateKey, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+	if err != nil {
+		return nil, nil, err
+	}
+	edPrivateKey, ok := privateKey.(ed25519.PrivateKey)
+	if !ok {
+		return nil, nil, jwt.ErrNotEdPrivateKey
+	}
+	return edPrivateKey, edPrivateKey.Public(), nil
+}
+
+func parsePublicKey(algorithm string, pem []byte) (crypto.PublicKey, error) {
+	if algorithm == AlgorithmRS256 {
+		return jwt.ParseRSAPublicKeyFromPEM(pem)
+	}
+	return jwt.ParseEdPublicKeyFromPEM(pem)
+}
+
+func (k KeySet) getSigningKey() *SigningKey {
+	return k.signing
+}
+
+func (k KeySet) getVerificationKey(kid string) (*SigningKey, bool) {
+	key, ok := k.keys[kid]
+	return key, ok
+}
+
+// sign adds the kid header so verifiers pick the matching key out of the jwks
+func (s SigningKey) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(s.Method, claims)
+	if s.IsAsymmetric() {
+		token.Header["kid"] = s.KID
+	}
+	return token.SignedString(s.PrivateKey)
+}
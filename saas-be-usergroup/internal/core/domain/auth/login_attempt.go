@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"strings"
+	"time"
+
+	"github.com/saas-be-usergroup/internal/core/domain/mailer"
+	"github.com/spf13/viper"
+)
+
+var (
+	defaultLoginWindow        = time.Minute * 15
+	defaultLoginDelayAfter    = int64(3)
+	defaultLoginDelayBase     = time.Second
+	defaultLoginMaxDelay      = time.Second * 30
+	defaultLoginLockAfter     = int64(10)
+	defaultLoginLockDuration  = time.Minute * 15
+	defaultLoginIPMaxFailures = int64(50)
+)
+
+func GetLoginWindow() time.Duration {
+	if window := viper.GetDuration("login.window"); window > 0 {
+		return window
+	}
+	return defaultLoginWindow
+}
+
+func GetLoginDelayAfter() int64 {
+	if delayAfter := viper.GetInt64("login.delay_after"); delayAfter > 0 {
+		return delayAfter
+	}
+	return defaultLoginDelayAfter
+}
+
+func GetLoginDelayBase() time.Duration {
+	if delayBase := viper.GetDuration("login.delay_base"); delayBase > 0 {
+		return delayBase
+	}
+	return defaultLoginDelayBase
+}
+
+func GetLoginMaxDelay() time.Duration {
+	if maxDelay := viper.GetDuration("login.max_delay"); maxDelay > 0 {
+		return maxDelay
+	}
+	return defaultLoginMaxDelay
+}
+
+func GetLoginLockAfter() int64 {
+	if lockAfter := viper.GetInt64("login.lock_after"); lockAfter > 0 {
+		return lockAfter
+	}
+	return defaultLoginLockAfter
+}
+
+func GetLoginLockDuration() time.Duration {
+	if lockDuration := viper.GetDuration("login.lock_duration"); lockDuration > 0 {
+		return lockDuration
+	}
+	return defaultLoginLockDuration
+}
+
+func GetLoginIPMaxFailures() int64 {
+	if maxFailures := viper.GetInt64("login.ip_max_failures"); maxFailures > 0 {
+		return maxFailures
+	}
+	return defaultLoginIPMaxFailures
+}
+
+// LoginFailures is the sliding window of failed logins for an email or an ip
+type LoginFailures struct {
+	Count       int64
+	LastFailure time.Time
+}
+
+func NewLoginFailures(count int64, lastFailure time.Time) *LoginFailures {
+	return &LoginFailures{
+		Count:       count,
+		LastFailure: lastFailure,
+	}
+}
+
+func (l LoginFailures) GetCount() int64 {
+	return l.Count
+}
+
+// GetDelay doubles the wait between attempts for every failure over login.delay_after
+func (l LoginFailures) GetDelay() time.Duration {
+	over := l.Count - GetLoginDelayAfter()
+	if over < 0 {
+		return 0
+	}
+
+	delay := GetLoginDelayBase() << uint(over)
+	if delay <= 0 || delay > GetLoginMaxDelay() {
+		delay = GetLoginMaxDelay()
+	}
+	return delay
+}
+
+// GetRetryAfter is how long the next attempt has to wait, zero when it is allowed right away
+func (l LoginFailures) GetRetryAfter() time.Duration {
+	retryAfter := time.Until(l.LastFailure.Add(l.GetDelay()))
+	if retryAfter < 0 {
+		return 0
+	}
+	return retryAfter
+}
+
+func (l LoginFailures) ShouldLock() bool {
+	return l.Count >= GetLoginLockAfter()
+}
+
+func (l LoginFailures) IsIPBlocked() bool {
+	return l.Count >= GetLoginIPMaxFailures()
+}
+
+func normalizeLoginEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func ToAccountLockedMail(lockDuration time.Duration) *mailer.AccountLockedMail {
+	return &mailer.AccountLockedMail{
+		Duration: uint64(lockDuration.Minutes()),
+	}
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"github.com/saas-be-usergroup/internal/core/domain/mailer"
+	"github.com/spf13/viper"
+	"net/url"
+	"time"
+)
+
+const defaultMagicLinkTTL = time.Minute * 10
+
+var ErrMagicLinkURL = errors.New("magic_link.url must be the absolute http or https url of the frontend page")
+
+// MagicLink is a single-use login link. The token travels by email while the nonce stays with the browser that
+// asked for it, a forwarded link can not be used without the nonce
+type MagicLink struct {
+	Token string
+	Nonce string
+	UUID  string
+}
+
+func NewMagicLink(token string, nonce string, uuid string) *MagicLink {
+	return &MagicLink{
+		Token: token,
+		Nonce: nonce,
+		UUID:  uuid,
+	}
+}
+
+// GenerateMagicLink is called for unknown emails as well, so the response does not tell whether a link was sent
+func GenerateMagicLink() (*MagicLink, error) {
+	token, err := GenerateRandomSession()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := GenerateRandomSession()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMagicLink(token, nonce, ""), nil
+}
+
+func GetMagicLinkTTL() time.Duration {
+	if ttl := viper.GetDuration("magic_link.ttl"); ttl > 0 {
+		return ttl
+	}
+	return defaultMagicLinkTTL
+}
+
+func (m *MagicLink) IsEmpty() bool {
+	return m == nil
+}
+
+func (m *MagicLink) SetUUID(uuid string) {
+	m.UUID = uuid
+}
+
+func (m MagicLink) GetUUID() string {
+	return m.UUID
+}
+
+// IsBoundTo compares the nonce the browser sent with the hash kept in redis
+func (m MagicLink) IsBoundTo(nonce string) bool {
+	return subtle.ConstantTimeCompare([]byte(m.Nonce), []byte(hashMagicLinkValue(nonce))) == 1
+}
+
+// CheckMagicLinkURL is called on startup, without magic_link.url every mailed link would be a bare query string
+func CheckMagicLinkURL() error {
+	_, err := getMagicLinkURL()
+	return err
+}
+
+func getMagicLinkURL() (*url.URL, error) {
+	u, err := url.Parse(viper.GetString("magic_link.url"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrMagicLinkURL
+	}
+	return u, nil
+}
+
+// getURL appends the token to magic_link.url, the frontend page posts it back with the nonce
+func (m MagicLink) getURL() string {
+	u, err := getMagicLinkURL()
+	if err != nil {
+		return ""
+	}
+
+	query := u.Query()
+	query.Set("token", m.Token)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func (m MagicLink) ToMagicLinkMail() *mailer.MagicLinkMail {
+	return &mailer.MagicLinkMail{
+		URL:      m.getURL(),
+		Duration: uint64(GetMagicLinkTTL().Seconds()),
+	}
+}
+
+func (m MagicLink) ToMagicLinkResponse() *MagicLinkResponse {
+	return &MagicLinkResponse{
+		Nonce:     m.Nonce,
+		ExpiresIn: int64(GetMagicLinkTTL().Seconds()),
+	}
+}
+
+// hashMagicLinkValue keeps the token and the nonce out of redis in clear
+func hashMagicLinkValue(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,23 +1,49 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
 	"github.com/saas-be-usergroup/internal/core/domain/mailer"
-	"github.com/xlzd/gotp"
+	"github.com/spf13/viper"
+	"math/big"
 	"strconv"
 	"strings"
 	"time"
 )
 
+const (
+	defaultOTPLength          = 6
+	defaultOTPExpiredInSecond = 180
+	defaultOTPMaxAttempts     = 5
+	defaultOTPResendCooldown  = time.Minute
+	defaultOTPResendDailyCap  = 5
+)
+
+type OTPPurpose string
+
+const (
+	OTPRegister       OTPPurpose = "register"
+	OTPChangePassword OTPPurpose = "change-password"
+	OTPResetPassword  OTPPurpose = "reset-password"
+	OTPChangeEmail    OTPPurpose = "change-email"
+)
+
 type OTP struct {
 	UUID     string
 	OTP      string
 	Duration uint64
+	Purpose  OTPPurpose
 }
 
 func NewOTP(uuid string, otp string) *OTP {
+	return NewOTPWithPurpose(OTPRegister, uuid, otp)
+}
+
+func NewOTPWithPurpose(purpose OTPPurpose, uuid string, otp string) *OTP {
 	return &OTP{
-		UUID: uuid,
-		OTP:  otp,
+		UUID:    uuid,
+		OTP:     otp,
+		Purpose: purpose,
 	}
 }
 
@@ -25,6 +51,26 @@ func (o OTP) GetUUID() string {
 	return o.UUID
 }
 
+// getKey keeps the register otp on its original "otp-<uuid>" key
+func (o OTP) getKey() string {
+	if o.Purpose == OTPRegister {
+		return "otp-" + o.UUID
+	}
+	return string(o.Purpose) + "-otp-" + o.UUID
+}
+
+// getResendKey scopes a resend counter to the purpose, register keeps the unprefixed keys
+func getResendKey(purpose OTPPurpose, key string) string {
+	if purpose == OTPRegister {
+		return key
+	}
+	return string(purpose) + "-" + key
+}
+
+func (o OTP) getAttemptsKey() string {
+	return o.getKey() + "-attempts"
+}
+
 func (o OTP) GetOTP() string {
 	return o.OTP
 }
@@ -37,8 +83,51 @@ func (o *OTP) IsNotFound() bool {
 	return o == nil || o.OTP == ""
 }
 
-func GenerateNewOTP() string {
-	return gotp.NewDefaultTOTP("4S62BZNFXXSZLCRO").Now()
+// GenerateNewOTP returns a random numeric code of otp.length digits
+func GenerateNewOTP() (string, error) {
+	length := viper.GetInt("otp.length")
+	if length <= 0 {
+		length = defaultOTPLength
+	}
+
+	var otp strings.Builder
+	for i := 0; i < length; i++ {
+		digit, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		otp.WriteString(digit.String())
+	}
+
+	return otp.String(), nil
+}
+
+func GetOTPExpiredInSecond() uint64 {
+	if ttl := viper.GetUint64("otp.ttl"); ttl > 0 {
+		return ttl
+	}
+	return defaultOTPExpiredInSecond
+}
+
+func GetOTPResendCooldown() time.Duration {
+	if cooldown := viper.GetDuration("otp.resend_cooldown"); cooldown > 0 {
+		return cooldown
+	}
+	return defaultOTPResendCooldown
+}
+
+func GetOTPResendDailyCap() int64 {
+	if dailyCap := viper.GetInt64("otp.resend_daily_cap"); dailyCap > 0 {
+		return dailyCap
+	}
+	return defaultOTPResendDailyCap
+}
+
+func GetOTPMaxAttempts() int64 {
+	if maxAttempts := viper.GetInt64("otp.max_attempts"); maxAttempts > 0 {
+		return maxAttempts
+	}
+	return defaultOTPMaxAttempts
 }
 
 func SetExpiredInSecond(expiredInSecond uint64) (*time.Duration, error) {
@@ -57,10 +146,31 @@ func (o OTP) ToRegisterMail() *mailer.RegisterMail {
 	}
 }
 
+func (o OTP) ToChangePasswordMail() *mailer.ChangePasswordMail {
+	return &mailer.ChangePasswordMail{
+		OTP:      o.OTP,
+		Duration: o.Duration,
+	}
+}
+
+func (o OTP) ToChangeEmailMail() *mailer.ChangeEmailMail {
+	return &mailer.ChangeEmailMail{
+		OTP:      o.OTP,
+		Duration: o.Duration,
+	}
+}
+
+func (o OTP) ToResetPasswordMail() *mailer.ResetPasswordMail {
+	return &mailer.ResetPasswordMail{
+		OTP:      o.OTP,
+		Duration: o.Duration,
+	}
+}
+
 func (o OTP) ToRegisterResponse() *RegisterBeforeResponse {
 	return &RegisterBeforeResponse{UUID: o.UUID}
 }
 
 func (o OTP) IsValid(otpRequest string) bool {
-	return strings.EqualFold(o.OTP, otpRequest)
+	return subtle.ConstantTimeCompare([]byte(o.OTP), []byte(otpRequest)) == 1
 }
@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/viper"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// bcrypt only reads the first 72 bytes of a password
+const (
+	defaultPasswordMinLength = 8
+	defaultPasswordMaxLength = 72
+	minPersonalValueLength   = 3
+)
+
+// the defaults of the rules are the values config.yaml ships with
+const (
+	defaultRequireUpper   = true
+	defaultRequireLower   = true
+	defaultRequireDigit   = true
+	defaultRequireSymbol  = false
+	defaultRejectPersonal = true
+)
+
+// rules of the password policy, they are sent to the frontend with each violation
+const (
+	PasswordRuleMinLength = "min_length"
+	PasswordRuleMaxLength = "max_length"
+	PasswordRuleUppercase = "uppercase"
+	PasswordRuleLowercase = "lowercase"
+	PasswordRuleDigit     = "digit"
+	PasswordRuleSymbol    = "symbol"
+	PasswordRulePersonal  = "personal_info"
+	PasswordRuleBreached  = "breached"
+)
+
+const CodePasswordPolicy = "PASSWORD_POLICY"
+
+var ErrPasswordPolicy = errors.New("password does not meet the password policy")
+
+type PasswordViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicy is read from the password block of config, a rule left out keeps the default of the shipped config
+type PasswordPolicy struct {
+	MinLength      int
+	MaxLength      int
+	RequireUpper   bool
+	RequireLower   bool
+	RequireDigit   bool
+	RequireSymbol  bool
+	RejectPersonal bool
+	BreachedPath   string
+}
+
+func GetPasswordPolicy() *PasswordPolicy {
+	return &PasswordPolicy{
+		MinLength:      intOrDefault("password.min_length", defaultPasswordMinLength),
+		MaxLength:      intOrDefault("password.max_length", defaultPasswordMaxLength),
+		RequireUpper:   boolOrDefault("password.require_upper", defaultRequireUpper),
+		RequireLower:   boolOrDefault("password.require_lower", defaultRequireLower),
+		RequireDigit:   boolOrDefault("password.require_digit", defaultRequireDigit),
+		RequireSymbol:  boolOrDefault("password.require_symbol", defaultRequireSymbol),
+		RejectPersonal: boolOrDefault("password.reject_personal", defaultRejectPersonal),
+		BreachedPath:   viper.GetString("password.breached.path"),
+	}
+}
+
+// Check returns every rule the password breaks, personal holds the email and the username of the account.
+// The error is only set when the breached list can not be read
+func (p PasswordPolicy) Check(password string, personal ...string) ([]PasswordViolation, error) {
+	violations := make([]PasswordViolation, 0)
+	add := func(rule string, message string) {
+		violations = append(violations, PasswordViolation{Rule: rule, Message: message})
+	}
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		add(PasswordRuleMinLength, fmt.Sprintf("must be at least %d characters", p.MinLength))
+	}
+	if len(password) > p.MaxLength {
+		add(PasswordRuleMaxLength, fmt.Sprintf("must be at most %d bytes", p.MaxLength))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSymbol = true
+		}
+	}
+	if p.RequireUpper && !hasUpper {
+		add(PasswordRuleUppercase, "must contain an uppercase letter")
+	}
+	if p.RequireLower && !hasLower {
+		add(PasswordRuleLowercase, "must contain a lowercase letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		add(PasswordRuleDigit, "must contain a digit")
+	}
+	if p.RequireSymbol && !hasSymbol {
+		add(PasswordRuleSymbol, "must contain a symbol")
+	}
+
+	if p.RejectPersonal && containsPersonal(password, personal) {
+		add(PasswordRulePersonal, "must not contain your email or username")
+	}
+
+	if p.BreachedPath != "" {
+		breached, err := IsPasswordBreached(p.BreachedPath, password)
+		if err != nil {
+			return nil, err
+		}
+		if breached {
+			add(PasswordRuleBreached, "has appeared in a data breach, please choose another one")
+		}
+	}
+
+	return violations, nil
+}
+
+// containsPersonal compares case-insensitively, an email is checked whole and by its local part
+func containsPersonal(password string, personal []string) bool {
+	lowered := strings.ToLower(password)
+	for _, value := range personal {
+		value = strings.ToLower(value)
+		candidates := []string{value}
+		if at := strings.LastIndex(value, "@"); at > 0 {
+			candidates = append(candidates, value[:at])
+		}
+
+		for _, candidate := range candidates {
+			if len(candidate) >= minPersonalValueLength && strings.Contains(lowered, candidate) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func intOrDefault(key string, def int) int {
+	if i := viper.GetInt(key); i > 0 {
+		return i
+	}
+	return def
+}
+
+func boolOrDefault(key string, def bool) bool {
+	if !viper.IsSet(key) {
+		return def
+	}
+	return viper.GetBool(key)
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func testPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:      8,
+		MaxLength:      72,
+		RequireUpper:   true,
+		RequireLower:   true,
+		RequireDigit:   true,
+		RequireSymbol:  true,
+		RejectPersonal: true,
+	}
+}
+
+func violatedRules(violations []PasswordViolation) []string {
+	rules := make([]string, 0, len(violations))
+	for _, v := range violations {
+		rules = append(rules, v.Rule)
+	}
+	return rules
+}
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	personal := []string{"Alice.Smith@example.com", "wonderland"}
+
+	tests := []struct {
+		name     string
+		password string
+		personal []string
+		want     []string
+	}{
+		{name: "every rule met", password: "Tr0ub4dor&3", want: []string{}},
+		{name: "too short", password: "Ab1!", want: []string{PasswordRuleMinLength}},
+		{name: "length counts runes", password: "Äb1!äöüß", want: []string{}},
+		{name: "too long", password: "Ab1!" + strings.Repeat("a", 69), want: []string{PasswordRuleMaxLength}},
+		{name: "max length counts bytes", password: "Ab1!" + strings.Repeat("ä", 35), want: []string{PasswordRuleMaxLength}},
+		{name: "no uppercase", password: "tr0ub4dor&3", want: []string{PasswordRuleUppercase}},
+		{name: "no lowercase", password: "TR0UB4DOR&3", want: []string{PasswordRuleLowercase}},
+		{name: "no digit", password: "Troubador&x", want: []string{PasswordRuleDigit}},
+		{name: "no symbol", password: "Tr0ub4dor33", want: []string{PasswordRuleSymbol}},
+		{name: "space is not a symbol", password: "Tr0ub4dor 3", want: []string{PasswordRuleSymbol}},
+		{name: "math symbol", password: "Tr0ub4dor+3", want: []string{}},
+		{name: "currency symbol", password: "Tr0ub4dor€3", want: []string{}},
+		{name: "every character rule broken", password: "        ", want: []string{PasswordRuleUppercase, PasswordRuleLowercase, PasswordRuleDigit, PasswordRuleSymbol}},
+		{name: "whole email", password: "alice.smith@example.com1A", personal: personal, want: []string{PasswordRulePersonal}},
+		{name: "local part of the email", password: "X!9alice.SMITH", personal: personal, want: []string{PasswordRulePersonal}},
+		{name: "username case-insensitive", password: "WonderLand#1", personal: personal, want: []string{PasswordRulePersonal}},
+		{name: "short personal value is ignored", password: "Tr0ub4dor&3", personal: []string{"tr", "ab@x.io"}, want: []string{}},
+		{name: "unrelated personal value", password: "Tr0ub4dor&3", personal: personal, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations, err := testPasswordPolicy().Check(tt.password, tt.personal...)
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			if got := violatedRules(violations); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Check() rules = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyCheckWithRulesOff(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 8, MaxLength: 72}
+
+	violations, err := policy.Check("wonderland", "wonderland")
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if len(violations) != 0 {
+		t.Errorf("Check() rules = %v, want none", violatedRules(violations))
+	}
+}
+
+func TestGetPasswordPolicyDefaults(t *testing.T) {
+	policy := GetPasswordPolicy()
+
+	want := &PasswordPolicy{
+		MinLength:      defaultPasswordMinLength,
+		MaxLength:      defaultPasswordMaxLength,
+		RequireUpper:   true,
+		RequireLower:   true,
+		RequireDigit:   true,
+		RequireSymbol:  false,
+		RejectPersonal: true,
+	}
+	if !reflect.DeepEqual(policy, want) {
+		t.Errorf("GetPasswordPolicy() = %+v, want %+v", policy, want)
+	}
+}
+
+func TestGetPasswordPolicyReadsConfig(t *testing.T) {
+	viper.Set("password.require_upper", false)
+	viper.Set("password.require_symbol", true)
+	viper.Set("password.min_length", 12)
+	t.Cleanup(func() {
+		viper.Set("password.require_upper", nil)
+		viper.Set("password.require_symbol", nil)
+		viper.Set("password.min_length", nil)
+	})
+
+	policy := GetPasswordPolicy()
+	if policy.RequireUpper || !policy.RequireSymbol || policy.MinLength != 12 {
+		t.Errorf("GetPasswordPolicy() = %+v, want require_upper off, require_symbol on and min_length 12", policy)
+	}
+	if !policy.RequireLower || !policy.RequireDigit || !policy.RejectPersonal {
+		t.Errorf("GetPasswordPolicy() = %+v, want the rules left out to keep their default", policy)
+	}
+}
+
+func TestPasswordPolicyCheckBreached(t *testing.T) {
+	policy := testPasswordPolicy()
+	policy.BreachedPath = writeBreachedFixture(t, "Tr0ub4dor&3")
+
+	violations, err := policy.Check("Tr0ub4dor&3")
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if got := violatedRules(violations); !reflect.DeepEqual(got, []string{PasswordRuleBreached}) {
+		t.Errorf("Check() rules = %v, want [%s]", got, PasswordRuleBreached)
+	}
+}
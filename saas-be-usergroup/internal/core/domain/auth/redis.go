@@ -3,9 +3,10 @@ package auth
 import (
 	"context"
 	"errors"
-	"github.com/dgrijalva/jwt-go"
 	"github.com/go-redis/redis/v8"
-	"github.com/spf13/viper"
+	"github.com/google/uuid"
+	"strconv"
+	"time"
 )
 
 func (o OTP) Create(ctx context.Context, client *redis.Client, expiredInSecond uint64) (*OTP, error) {
@@ -16,41 +17,201 @@ func (o OTP) Create(ctx context.Context, client *redis.Client, expiredInSecond u
 
 	o.SetDuration(expiredInSecond)
 
-	if err = client.Set(ctx, "otp-"+o.GetUUID(), o.GetOTP(), *expired).Err(); err != nil {
+	// a new otp starts with a fresh attempts counter
+	if _, err = client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, o.getKey(), o.GetOTP(), *expired)
+		pipe.Del(ctx, o.getAttemptsKey())
+		return nil
+	}); err != nil {
 		return nil, err
 	}
 
 	return &o, nil
 }
 
+// Verify consumes the otp when it matches so it can only be used once,
+// every wrong guess is counted and the otp is burned after otp.max_attempts failures
+func (o OTP) Verify(ctx context.Context, client *redis.Client, otpRequest string) (bool, error) {
+	if o.IsValid(otpRequest) {
+		deleted, err := client.Del(ctx, o.getKey()).Result()
+		if err != nil {
+			return false, err
+		}
+		if err = client.Del(ctx, o.getAttemptsKey()).Err(); err != nil {
+			return false, err
+		}
+
+		// zero means another request has consumed the same otp
+		return deleted > 0, nil
+	}
+
+	attempts, err := client.Incr(ctx, o.getAttemptsKey()).Result()
+	if err != nil {
+		return false, err
+	}
+
+	expired, err := SetExpiredInSecond(GetOTPExpiredInSecond())
+	if err != nil {
+		return false, err
+	}
+	if err = client.Expire(ctx, o.getAttemptsKey(), *expired).Err(); err != nil {
+		return false, err
+	}
+
+	if attempts >= GetOTPMaxAttempts() {
+		if err = client.Del(ctx, o.getKey(), o.getAttemptsKey()).Err(); err != nil {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
 func GetOTPByUUID(ctx context.Context, client *redis.Client, uuid string) (*OTP, error) {
-	otp, err := client.Get(ctx, "otp-"+uuid).Result()
+	return GetOTPByPurposeAndUUID(ctx, client, OTPRegister, uuid)
+}
+
+func GetOTPByPurposeAndUUID(ctx context.Context, client *redis.Client, purpose OTPPurpose, uuid string) (*OTP, error) {
+	otp := NewOTPWithPurpose(purpose, uuid, "")
+	value, err := client.Get(ctx, otp.getKey()).Result()
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
-			return NewOTP(uuid, ""), nil
+			return otp, nil
 		}
 		return nil, err
 	}
 
-	return NewOTP(uuid, otp), nil
+	return NewOTPWithPurpose(purpose, uuid, value), nil
+}
+
+// AcquireOTPResendCooldown starts the resend cooldown of the purpose for the uuid, it returns false
+// with the remaining cooldown when the previous otp was sent too recently
+func AcquireOTPResendCooldown(ctx context.Context, client *redis.Client, purpose OTPPurpose, uuid string) (bool, time.Duration, error) {
+	key := getResendKey(purpose, "otp-resend-cooldown-"+uuid)
+	acquired, err := client.SetNX(ctx, key, 1, GetOTPResendCooldown()).Result()
+	if err != nil {
+		return false, 0, err
+	}
+
+	if acquired {
+		return true, 0, nil
+	}
+
+	remaining, err := client.TTL(ctx, key).Result()
+	if err != nil {
+		return false, 0, err
+	}
+
+	return false, remaining, nil
+}
+
+// IncrementOTPResendDaily counts the otp of the purpose sent to the uuid and to the email within 24 hours,
+// the email is counted as well because every new register creates a new uuid
+func IncrementOTPResendDaily(ctx context.Context, client *redis.Client, purpose OTPPurpose, uuid string, email string) (int64, error) {
+	var total int64
+	for _, key := range []string{getResendKey(purpose, "otp-resend-daily-uuid-"+uuid), getResendKey(purpose, "otp-resend-daily-email-"+email)} {
+		count, err := client.Incr(ctx, key).Result()
+		if err != nil {
+			return 0, err
+		}
+
+		if count == 1 {
+			if err = client.Expire(ctx, key, 24*time.Hour).Err(); err != nil {
+				return 0, err
+			}
+		}
+
+		if count > total {
+			total = count
+		}
+	}
+
+	return total, nil
 }
 
+func getRegisterSessionKey(sessionToken string) string {
+	return "register-session-" + sessionToken
+}
+
+func getChangePasswordSessionKey(sessionToken string) string {
+	return "change-password-session-" + sessionToken
+}
+
+// GenerateSessionToken issues the registration session of the uuid once its otp is confirmed, the token is random
+// and only redis knows which uuid it belongs to
 func GenerateSessionToken(ctx context.Context, client *redis.Client, uuid string, expiredInSecond uint64) (*SessionToken, error) {
-	sessionToken := GenerateNewSession(uuid)
+	sessionToken, err := GenerateRandomSession()
+	if err != nil {
+		return nil, err
+	}
+
+	expired, err := SetExpiredInSecond(expiredInSecond)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = client.SetEX(ctx, getRegisterSessionKey(sessionToken), uuid, *expired).Err(); err != nil {
+		return nil, err
+	}
+
+	return NewSessionToken(sessionToken), nil
+}
+
+func ValidateRegisterSession(ctx context.Context, client *redis.Client, sessionToken string) (*Session, error) {
+	return getSession(ctx, client, getRegisterSessionKey(sessionToken))
+}
+
+// ConsumeRegisterSession deletes the session, false means another request has already used it
+func ConsumeRegisterSession(ctx context.Context, client *redis.Client, sessionToken string) (bool, error) {
+	deleted, err := client.Del(ctx, getRegisterSessionKey(sessionToken)).Result()
+	if err != nil {
+		return false, err
+	}
+	return deleted > 0, nil
+}
+
+func GenerateChangePasswordSessionToken(ctx context.Context, client *redis.Client, uuid string, expiredInSecond uint64) (*SessionToken, error) {
+	sessionToken, err := GenerateRandomSession()
+	if err != nil {
+		return nil, err
+	}
+
 	expired, err := SetExpiredInSecond(expiredInSecond)
 	if err != nil {
 		return nil, err
 	}
 
-	if err = client.SetEX(ctx, sessionToken, uuid, *expired).Err(); err != nil {
+	if err = client.SetEX(ctx, getChangePasswordSessionKey(sessionToken), uuid, *expired).Err(); err != nil {
 		return nil, err
 	}
 
 	return NewSessionToken(sessionToken), nil
 }
 
-func ValidateSession(ctx context.Context, client *redis.Client, sessionToken string) (*Session, error) {
-	uuid, err := client.Get(ctx, sessionToken).Result()
+// ConsumeChangePasswordSession returns the session and deletes it in one transaction, a nil session means
+// it has expired or another request has already used it
+func ConsumeChangePasswordSession(ctx context.Context, client *redis.Client, sessionToken string) (*Session, error) {
+	var (
+		uuid    *redis.StringCmd
+		deleted *redis.IntCmd
+	)
+	if _, err := client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		uuid = pipe.Get(ctx, getChangePasswordSessionKey(sessionToken))
+		deleted = pipe.Del(ctx, getChangePasswordSessionKey(sessionToken))
+		return nil
+	}); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	if deleted.Val() == 0 {
+		return nil, nil
+	}
+	return NewSession(uuid.Val()), nil
+}
+
+// getSession reads the uuid of a session key, the callers namespace the key so a token can not point at other keys
+func getSession(ctx context.Context, client *redis.Client, key string) (*Session, error) {
+	uuid, err := client.Get(ctx, key).Result()
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
 			return nil, nil
@@ -60,25 +221,370 @@ func ValidateSession(ctx context.Context, client *redis.Client, sessionToken str
 	return NewSession(uuid), nil
 }
 
+func storeRefreshToken(ctx context.Context, client *redis.Client, uuid string, family string, jti string) error {
+	_, err := client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, "refresh-token-"+jti, map[string]interface{}{
+			"uuid":   uuid,
+			"family": family,
+		})
+		pipe.Expire(ctx, "refresh-token-"+jti, getRefreshTTL())
+
+		// index refresh token by user and by family so they can be revoked at once
+		pipe.SAdd(ctx, "refresh-tokens-"+uuid, jti)
+		pipe.Expire(ctx, "refresh-tokens-"+uuid, getRefreshTTL())
+		pipe.SAdd(ctx, "refresh-family-"+family, jti)
+		pipe.Expire(ctx, "refresh-family-"+family, getRefreshTTL())
+		return nil
+	})
+	return err
+}
+
+func getRefreshTokenJTI(refreshToken string) (string, error) {
+	claims, err := jwtParse(refreshToken, TokenRefresh)
+	if err != nil {
+		return "", ErrInvalidRefreshToken
+	}
+
+	return claims.GetJTI(), nil
+}
+
+// ValidateRefreshToken checks the refresh token is still active without consuming it
 func ValidateRefreshToken(ctx context.Context, client *redis.Client, refreshToken string) (*RefreshTokenDataClaims, error) {
-	token, err := jwtParse(refreshToken, viper.GetString("jwt.refresh_secret"))
+	jti, err := getRefreshTokenJTI(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := client.HGetAll(ctx, "refresh-token-"+jti).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(values) == 0 {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	return NewRefreshTokenDataClaims(values["uuid"], jti, values["family"]), nil
+}
+
+// RotateRefreshToken consumes the refresh token so it can only be exchanged once.
+// Presenting a consumed refresh token again means it has leaked, the whole family is
+// revoked and ErrRefreshTokenReused is returned together with the family data claims
+func RotateRefreshToken(ctx context.Context, client *redis.Client, refreshToken string) (*RefreshTokenDataClaims, error) {
+	jti, err := getRefreshTokenJTI(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := client.HGetAll(ctx, "refresh-token-"+jti).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(values) == 0 {
+		used, err := client.HGetAll(ctx, "refresh-token-used-"+jti).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(used) == 0 {
+			return nil, ErrInvalidRefreshToken
+		}
+
+		dataClaims := NewRefreshTokenDataClaims(used["uuid"], jti, used["family"])
+		if err = RevokeRefreshTokenFamily(ctx, client, dataClaims.GetFamily()); err != nil {
+			return nil, err
+		}
+		return dataClaims, ErrRefreshTokenReused
+	}
+
+	dataClaims := NewRefreshTokenDataClaims(values["uuid"], jti, values["family"])
+
+	// remember the consumed jti for reuse detection as long as it could have been valid
+	ttl, err := client.TTL(ctx, "refresh-token-"+jti).Result()
+	if err != nil {
+		return nil, err
+	}
+	if ttl <= 0 {
+		ttl = getRefreshTTL()
+	}
+
+	var deleted *redis.IntCmd
+	if _, err = client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		deleted = pipe.Del(ctx, "refresh-token-"+jti)
+		pipe.HSet(ctx, "refresh-token-used-"+jti, values)
+		pipe.Expire(ctx, "refresh-token-used-"+jti, ttl)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	// zero means a concurrent request has rotated the same refresh token
+	if deleted.Val() == 0 {
+		if err = RevokeRefreshTokenFamily(ctx, client, dataClaims.GetFamily()); err != nil {
+			return nil, err
+		}
+		return dataClaims, ErrRefreshTokenReused
+	}
+
+	return dataClaims, nil
+}
+
+// RevokeRefreshTokenFamily revokes every active refresh token rotated from the same login
+func RevokeRefreshTokenFamily(ctx context.Context, client *redis.Client, family string) error {
+	jtis, err := client.SMembers(ctx, "refresh-family-"+family).Result()
+	if err != nil {
+		return err
+	}
+
+	// the login session goes together with its refresh tokens
+	keys := []string{getDeviceSessionKey(family)}
+	for _, jti := range jtis {
+		keys = append(keys, "refresh-token-"+jti)
+	}
+
+	return client.Del(ctx, keys...).Err()
+}
+
+// DeleteAllRefreshTokens revokes every refresh token issued to the user
+func DeleteAllRefreshTokens(ctx context.Context, client *redis.Client, uuid string) error {
+	jtis, err := client.SMembers(ctx, "refresh-tokens-"+uuid).Result()
+	if err != nil {
+		return err
+	}
+
+	sessionIDs, err := client.SMembers(ctx, getDeviceSessionsKey(uuid)).Result()
+	if err != nil {
+		return err
+	}
+
+	keys := []string{"refresh-tokens-" + uuid, getDeviceSessionsKey(uuid)}
+	for _, jti := range jtis {
+		keys = append(keys, "refresh-token-"+jti)
+	}
+	for _, sessionID := range sessionIDs {
+		keys = append(keys, getDeviceSessionKey(sessionID))
+	}
+
+	return client.Del(ctx, keys...).Err()
+}
+
+func getDeviceSessionKey(sessionID string) string {
+	return "device-session-" + sessionID
+}
+
+func getDeviceSessionsKey(uuid string) string {
+	return "device-sessions-" + uuid
+}
+
+// storeDeviceSession records the client of the login, an empty device keeps the one sent on login
+func storeDeviceSession(ctx context.Context, client *redis.Client, uuid string, sessionID string, meta SessionMeta) error {
+	now := time.Now().Unix()
+	values := map[string]interface{}{
+		"uuid":         uuid,
+		"ip":           meta.IP,
+		"user_agent":   meta.UserAgent,
+		"last_used_at": now,
+	}
+	if meta.Device != "" {
+		values["device"] = meta.Device
+	}
+
+	_, err := client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSetNX(ctx, getDeviceSessionKey(sessionID), "created_at", now)
+		pipe.HSet(ctx, getDeviceSessionKey(sessionID), values)
+		pipe.Expire(ctx, getDeviceSessionKey(sessionID), getRefreshTTL())
+		pipe.SAdd(ctx, getDeviceSessionsKey(uuid), sessionID)
+		pipe.Expire(ctx, getDeviceSessionsKey(uuid), getRefreshTTL())
+		return nil
+	})
+	return err
+}
+
+// GetDeviceSessions lists the active logins of the user, expired sessions are dropped from the index
+func GetDeviceSessions(ctx context.Context, client *redis.Client, uuid string) ([]DeviceSession, error) {
+	sessionIDs, err := client.SMembers(ctx, getDeviceSessionsKey(uuid)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	cmds := make([]*redis.StringStringMapCmd, 0, len(sessionIDs))
+	if _, err = client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, sessionID := range sessionIDs {
+			cmds = append(cmds, pipe.HGetAll(ctx, getDeviceSessionKey(sessionID)))
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sessions := make([]DeviceSession, 0, len(sessionIDs))
+	expired := make([]interface{}, 0)
+	for i, cmd := range cmds {
+		if len(cmd.Val()) == 0 {
+			expired = append(expired, sessionIDs[i])
+			continue
+		}
+		sessions = append(sessions, *newDeviceSessionFromHash(sessionIDs[i], cmd.Val()))
+	}
+
+	if len(expired) > 0 {
+		if err = client.SRem(ctx, getDeviceSessionsKey(uuid), expired...).Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return sessions, nil
+}
+
+func GetDeviceSession(ctx context.Context, client *redis.Client, sessionID string) (*DeviceSession, error) {
+	values, err := client.HGetAll(ctx, getDeviceSessionKey(sessionID)).Result()
 	if err != nil {
 		return nil, err
 	}
 
-	jti := token.Claims.(jwt.MapClaims)["jti"].(string)
-	if jti == "" {
-		return nil, errors.New("invalid token")
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	return newDeviceSessionFromHash(sessionID, values), nil
+}
+
+// RevokeDeviceSession logs the session out, its refresh tokens and every access token issued to it stop working
+func RevokeDeviceSession(ctx context.Context, client *redis.Client, session DeviceSession) error {
+	if err := RevokeRefreshTokenFamily(ctx, client, session.GetID()); err != nil {
+		return err
+	}
+
+	if err := RevokeSessionAccessTokens(ctx, client, session.GetID()); err != nil {
+		return err
+	}
+
+	return client.SRem(ctx, getDeviceSessionsKey(session.GetUUID()), session.GetID()).Err()
+}
+
+func getLoginFailuresEmailKey(email string) string {
+	return "login-failures-email-" + normalizeLoginEmail(email)
+}
+
+func getLoginFailuresIPKey(ip string) string {
+	return "login-failures-ip-" + ip
+}
+
+func getAccountLockKey(uuid string) string {
+	return "account-lock-" + uuid
+}
+
+// getLoginFailures drops the failures older than login.window before counting the rest
+func getLoginFailures(ctx context.Context, client *redis.Client, key string) (*LoginFailures, error) {
+	now := time.Now()
+	var count *redis.IntCmd
+	var last *redis.ZSliceCmd
+	if _, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(now.Add(-GetLoginWindow()).UnixNano(), 10))
+		count = pipe.ZCard(ctx, key)
+		last = pipe.ZRevRangeWithScores(ctx, key, 0, 0)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	var lastFailure time.Time
+	if len(last.Val()) > 0 {
+		lastFailure = time.Unix(0, int64(last.Val()[0].Score))
+	}
+
+	return NewLoginFailures(count.Val(), lastFailure), nil
+}
+
+func GetLoginFailuresByEmail(ctx context.Context, client *redis.Client, email string) (*LoginFailures, error) {
+	return getLoginFailures(ctx, client, getLoginFailuresEmailKey(email))
+}
+
+func GetLoginFailuresByIP(ctx context.Context, client *redis.Client, ip string) (*LoginFailures, error) {
+	return getLoginFailures(ctx, client, getLoginFailuresIPKey(ip))
+}
+
+// RecordLoginFailure adds the failure to the email and the ip windows and returns the email window
+func RecordLoginFailure(ctx context.Context, client *redis.Client, email string, ip string) (*LoginFailures, error) {
+	now := time.Now()
+	member := &redis.Z{Score: float64(now.UnixNano()), Member: uuid.New().String()}
+
+	if _, err := client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range []string{getLoginFailuresEmailKey(email), getLoginFailuresIPKey(ip)} {
+			pipe.ZAdd(ctx, key, member)
+			pipe.Expire(ctx, key, GetLoginWindow())
+		}
+		return nil
+	}); err != nil {
+		return nil, err
 	}
 
-	uuid, err := client.Get(ctx, jti).Result()
+	return GetLoginFailuresByEmail(ctx, client, email)
+}
+
+func ResetLoginFailures(ctx context.Context, client *redis.Client, email string) error {
+	return client.Del(ctx, getLoginFailuresEmailKey(email)).Err()
+}
+
+// LockAccount returns false when the account was already locked, so the notification is only sent once
+func LockAccount(ctx context.Context, client *redis.Client, uuid string, duration time.Duration) (bool, error) {
+	return client.SetNX(ctx, getAccountLockKey(uuid), time.Now().Unix(), duration).Result()
+}
+
+// GetAccountLock returns the remaining lock duration, zero when the account is not locked
+func GetAccountLock(ctx context.Context, client *redis.Client, uuid string) (time.Duration, error) {
+	remaining, err := client.TTL(ctx, getAccountLockKey(uuid)).Result()
 	if err != nil {
+		return 0, err
+	}
+
+	if remaining < 0 {
+		return 0, nil
+	}
+	return remaining, nil
+}
+
+func UnlockAccount(ctx context.Context, client *redis.Client, uuid string, email string) error {
+	return client.Del(ctx, getAccountLockKey(uuid), getLoginFailuresEmailKey(email)).Err()
+}
+
+func getMagicLinkKey(token string) string {
+	return "magic-link-" + hashMagicLinkValue(token)
+}
+
+// Create keeps the uuid with the nonce hash under the token hash, like the otp it expires on its own
+func (m MagicLink) Create(ctx context.Context, client *redis.Client) (*MagicLink, error) {
+	key := getMagicLinkKey(m.Token)
+	if _, err := client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, "uuid", m.UUID, "nonce", hashMagicLinkValue(m.Nonce))
+		pipe.Expire(ctx, key, GetMagicLinkTTL())
+		return nil
+	}); err != nil {
 		return nil, err
 	}
 
-	return NewRefreshTokenDataClaims(uuid, jti), nil
+	return &m, nil
 }
 
-func DeleteRefreshToken(ctx context.Context, client *redis.Client, jti string) error {
-	return client.Del(ctx, jti).Err()
+// ConsumeMagicLink reads and deletes the link in one transaction, only one request gets it back.
+// The link is burned even when the nonce turns out wrong
+func ConsumeMagicLink(ctx context.Context, client *redis.Client, token string) (*MagicLink, error) {
+	key := getMagicLinkKey(token)
+
+	var values *redis.StringStringMapCmd
+	var deleted *redis.IntCmd
+	if _, err := client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		values = pipe.HGetAll(ctx, key)
+		deleted = pipe.Del(ctx, key)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if deleted.Val() == 0 || values.Val()["uuid"] == "" {
+		return nil, nil
+	}
+
+	return NewMagicLink(token, values.Val()["nonce"], values.Val()["uuid"]), nil
 }
@@ -3,7 +3,7 @@ package auth
 import (
 	"github.com/google/uuid"
 	"github.com/saas-be-usergroup/internal/core/domain/user"
-	"strings"
+	"time"
 )
 
 type RegisterBeforeWithEmail struct {
@@ -24,11 +24,25 @@ type RegisterBeforeResponse struct {
 
 func (r RegisterBeforeWithEmail) ToUser() *user.User {
 	return &user.User{
-		UUID:  uuid.New().String(),
-		Email: r.Email,
+		UUID:     uuid.New().String(),
+		Email:    r.Email,
+		Status:   user.UserNew,
+		InsertTs: time.Now(),
 	}
 }
 
+type ResendRegisterOTPRequest struct {
+	UUID string `json:"uuid"`
+}
+
+func NewResendRegisterOTPRequest() *ResendRegisterOTPRequest {
+	return &ResendRegisterOTPRequest{}
+}
+
+func (r ResendRegisterOTPRequest) GetUUID() string {
+	return r.UUID
+}
+
 type ConfirmationRegister struct {
 	UUID string `json:"uuid"`
 	OTP  string `json:"otp"`
@@ -96,7 +110,7 @@ func (d DoRegisterRequest) ToUpdateUser(u *user.User, hashedPassword string) *us
 }
 
 func IsPasswordMatched(password string, confirmPassword string) bool {
-	return strings.EqualFold(password, confirmPassword)
+	return password == confirmPassword
 }
 
 type DoRegisterResponse struct {
@@ -122,10 +136,30 @@ func (d DoLoginRequest) GetPassword() string {
 	return d.Password
 }
 
+// DoLoginResponse carries either the token pair or, for an account with 2fa, the mfa challenge
 type DoLoginResponse struct {
-	RefreshToken string `json:"refresh_token"`
-	AccessToken  string `json:"access_token"`
-	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	AccessToken  string `json:"access_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+	MFARequired  bool   `json:"mfa_required,omitempty"`
+	MFAToken     string `json:"mfa_token,omitempty"`
+}
+
+type DoLoginMFARequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
+
+func NewDoLoginMFARequest() *DoLoginMFARequest {
+	return &DoLoginMFARequest{}
+}
+
+func (d DoLoginMFARequest) GetMFAToken() string {
+	return d.MFAToken
+}
+
+func (d DoLoginMFARequest) GetCode() string {
+	return d.Code
 }
 
 type DoRefreshTokenRequest struct {
@@ -157,3 +191,121 @@ func NewDoLogoutRequest() *DoLogoutRequest {
 func (d DoLogoutRequest) GetRefreshToken() string {
 	return d.RefreshToken
 }
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+func NewForgotPasswordRequest() *ForgotPasswordRequest {
+	return &ForgotPasswordRequest{}
+}
+
+func (f ForgotPasswordRequest) GetEmail() string {
+	return f.Email
+}
+
+type ResetPasswordRequest struct {
+	Email           string `json:"email"`
+	OTP             string `json:"otp"`
+	Password        string `json:"password"`
+	ConfirmPassword string `json:"confirm_password"`
+}
+
+func NewResetPasswordRequest() *ResetPasswordRequest {
+	return &ResetPasswordRequest{}
+}
+
+func (r ResetPasswordRequest) GetEmail() string {
+	return r.Email
+}
+
+func (r ResetPasswordRequest) GetOTP() string {
+	return r.OTP
+}
+
+func (r ResetPasswordRequest) GetPassword() string {
+	return r.Password
+}
+
+type MagicLinkRequest struct {
+	Email string `json:"email"`
+}
+
+func NewMagicLinkRequest() *MagicLinkRequest {
+	return &MagicLinkRequest{}
+}
+
+func (m MagicLinkRequest) GetEmail() string {
+	return m.Email
+}
+
+// MagicLinkResponse is returned whether the email is registered or not, the frontend keeps the nonce
+// until the link is opened
+type MagicLinkResponse struct {
+	Nonce     string `json:"nonce"`
+	ExpiresIn int64  `json:"expires_in"`
+}
+
+type VerifyMagicLinkRequest struct {
+	Token string `json:"token"`
+	Nonce string `json:"nonce"`
+}
+
+func NewVerifyMagicLinkRequest() *VerifyMagicLinkRequest {
+	return &VerifyMagicLinkRequest{}
+}
+
+func (v VerifyMagicLinkRequest) GetToken() string {
+	return v.Token
+}
+
+func (v VerifyMagicLinkRequest) GetNonce() string {
+	return v.Nonce
+}
+
+type DeviceSessionResponse struct {
+	SessionID  string    `json:"session_id"`
+	Device     string    `json:"device"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	Current    bool      `json:"current"`
+}
+
+type RevokeSessionRequest struct {
+	SessionID string `json:"session_id"`
+}
+
+func NewRevokeSessionRequest() *RevokeSessionRequest {
+	return &RevokeSessionRequest{}
+}
+
+func (r RevokeSessionRequest) GetSessionID() string {
+	return r.SessionID
+}
+
+type UnlockAccountRequest struct {
+	Email string `json:"email"`
+}
+
+func NewUnlockAccountRequest() *UnlockAccountRequest {
+	return &UnlockAccountRequest{}
+}
+
+func (u UnlockAccountRequest) GetEmail() string {
+	return u.Email
+}
+
+// AccountStatusRequest names the account an admin suspends, reactivates or deletes
+type AccountStatusRequest struct {
+	Email string `json:"email"`
+}
+
+func NewAccountStatusRequest() *AccountStatusRequest {
+	return &AccountStatusRequest{}
+}
+
+func (a AccountStatusRequest) GetEmail() string {
+	return a.Email
+}
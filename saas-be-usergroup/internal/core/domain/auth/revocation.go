@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/spf13/viper"
+)
+
+var (
+	defaultRevocationCacheTTL = time.Second * 5
+	revocationCacheMaxSize    = 10000
+)
+
+func getRevocationCacheTTL() time.Duration {
+	if ttl := viper.GetDuration("jwt.revocation_cache_ttl"); ttl > 0 {
+		return ttl
+	}
+	return defaultRevocationCacheTTL
+}
+
+func getTokenVersionKey(uuid string) string {
+	return "token-version-" + uuid
+}
+
+func getRevokedAccessTokenKey(jti string) string {
+	return "access-token-revoked-" + jti
+}
+
+func getRevokedSessionKey(sessionID string) string {
+	return "session-revoked-" + sessionID
+}
+
+// GetTokenVersion returns the version every new access token of the user is stamped with
+func GetTokenVersion(ctx context.Context, client *redis.Client, uuid string) (int64, error) {
+	version, err := client.Get(ctx, getTokenVersionKey(uuid)).Int64()
+	if err != nil && err != redis.Nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// RevokeAllAccessTokens bumps the token version of the user, every access token issued before is rejected.
+// The version key has no ttl, going back to zero would make old tokens valid again
+func RevokeAllAccessTokens(ctx context.Context, client *redis.Client, uuid string) error {
+	if err := client.Incr(ctx, getTokenVersionKey(uuid)).Err(); err != nil {
+		return err
+	}
+	revocations.forget(getTokenVersionKey(uuid))
+	return nil
+}
+
+// RevokeAccessToken denies a single access token until it expires on its own
+func RevokeAccessToken(ctx context.Context, client *redis.Client, jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	if err := client.Set(ctx, getRevokedAccessTokenKey(jti), 1, ttl).Err(); err != nil {
+		return err
+	}
+	revocations.forget(getRevokedAccessTokenKey(jti))
+	return nil
+}
+
+// RevokeSessionAccessTokens denies every access token carrying the session id, the entry lives
+// as long as an access token so the ones issued right before the revocation are covered too
+func RevokeSessionAccessTokens(ctx context.Context, client *redis.Client, sessionID string) error {
+	return revokeSession(ctx, client, sessionID, getAccessTTL())
+}
+
+// RevokeGrantAccessTokens denies every access token a client was issued from one authorization code,
+// the grant id is their session id and ttl the lifetime of the access tokens of the clients
+func RevokeGrantAccessTokens(ctx context.Context, client *redis.Client, grantID string, ttl time.Duration) error {
+	return revokeSession(ctx, client, grantID, ttl)
+}
+
+func revokeSession(ctx context.Context, client *redis.Client, sessionID string, ttl time.Duration) error {
+	if err := client.Set(ctx, getRevokedSessionKey(sessionID), 1, ttl).Err(); err != nil {
+		return err
+	}
+	revocations.forget(getRevokedSessionKey(sessionID))
+	return nil
+}
+
+// IsAccessTokenRevoked checks the denylists and the token version with a single round trip,
+// the answers are cached locally for jwt.revocation_cache_ttl so a revocation made on another
+// instance is enforced at most that late
+func IsAccessTokenRevoked(ctx context.Context, client *redis.Client, claims *JWTClaims) (bool, error) {
+	// a client acting for itself has no user and so no token version
+	if claims.GetUUID() == "" {
+		values, err := revocations.get(ctx, client, getRevokedAccessTokenKey(claims.GetJTI()))
+		if err != nil {
+			return false, err
+		}
+		return values[0] > 0, nil
+	}
+
+	keys := []string{getTokenVersionKey(claims.GetUUID()), getRevokedAccessTokenKey(claims.GetJTI())}
+	if claims.GetSessionID() != "" {
+		keys = append(keys, getRevokedSessionKey(claims.GetSessionID()))
+	}
+
+	values, err := revocations.get(ctx, client, keys...)
+	if err != nil {
+		return false, err
+	}
+
+	version, denied := values[0], values[1]
+	for _, sessionDenied := range values[2:] {
+		denied += sessionDenied
+	}
+	return denied > 0 || claims.GetVersion() < version, nil
+}
+
+type revocationCacheEntry struct {
+	value     int64
+	expiresAt time.Time
+}
+
+type revocationCache struct {
+	mu      sync.Mutex
+	entries map[string]revocationCacheEntry
+}
+
+var revocations = &revocationCache{entries: make(map[string]revocationCacheEntry)}
+
+func (r *revocationCache) get(ctx context.Context, client *redis.Client, keys ...string) ([]int64, error) {
+	values := make([]int64, len(keys))
+	missing := make([]string, 0, len(keys))
+	missingIndex := make([]int, 0, len(keys))
+
+	now := time.Now()
+	r.mu.Lock()
+	for i, key := range keys {
+		if entry, ok := r.entries[key]; ok && now.Before(entry.expiresAt) {
+			values[i] = entry.value
+			continue
+		}
+		missing = append(missing, key)
+		missingIndex = append(missingIndex, i)
+	}
+	r.mu.Unlock()
+
+	if len(missing) == 0 {
+		return values, nil
+	}
+
+	results, err := client.MGet(ctx, missing...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.evictExpired(now)
+	expiresAt := now.Add(getRevocationCacheTTL())
+	for i, result := range results {
+		var value int64
+		if s, ok := result.(string); ok {
+			if value, err = strconv.ParseInt(s, 10, 64); err != nil {
+				return nil, err
+			}
+		}
+		values[missingIndex[i]] = value
+		r.entries[missing[i]] = revocationCacheEntry{value: value, expiresAt: expiresAt}
+	}
+
+	return values, nil
+}
+
+func (r *revocationCache) forget(key string) {
+	r.mu.Lock()
+	delete(r.entries, key)
+	r.mu.Unlock()
+}
+
+// evictExpired keeps the cache bounded, it is only walked once the size limit is reached
+func (r *revocationCache) evictExpired(now time.Time) {
+	if len(r.entries) < revocationCacheMaxSize {
+		return
+	}
+
+	for key, entry := range r.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(r.entries, key)
+		}
+	}
+
+	// every entry is still fresh, start over rather than growing without limit
+	if len(r.entries) >= revocationCacheMaxSize {
+		r.entries = make(map[string]revocationCacheEntry)
+	}
+}
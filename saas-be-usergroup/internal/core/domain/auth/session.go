@@ -1,6 +1,10 @@
 package auth
 
-import "encoding/base64"
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"github.com/saas-be-usergroup/internal/core/domain/user"
+)
 
 type Session struct {
 	UUID string
@@ -34,6 +38,14 @@ func (s SessionToken) ToConfirmationResponse() *ConfirmationResponse {
 	return &ConfirmationResponse{SessionToken: s.SessionToken}
 }
 
-func GenerateNewSession(uuid string) string {
-	return base64.StdEncoding.EncodeToString([]byte(uuid))
+func (s SessionToken) ToChangePasswordConfirmationResponse() *user.ChangePasswordConfirmationResponse {
+	return &user.ChangePasswordConfirmationResponse{SessionToken: s.SessionToken}
+}
+
+func GenerateRandomSession() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
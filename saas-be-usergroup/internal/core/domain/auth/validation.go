@@ -12,6 +12,12 @@ func (c RegisterBeforeWithEmail) Validate() error {
 	)
 }
 
+func (c ResendRegisterOTPRequest) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.UUID, validation.Required),
+	)
+}
+
 func (c ConfirmationRegister) Validate() error {
 	return validation.ValidateStruct(&c,
 		validation.Field(&c.UUID, validation.Required),
@@ -29,16 +35,16 @@ func (c DoLoginRequest) Validate() error {
 func (c DoRegisterRequest) Validate() error {
 	if err := validation.ValidateStruct(&c,
 		validation.Field(&c.SessionToken, validation.Required),
-		validation.Field(&c.FirstName, validation.Required, validation.Min(1)),
-		validation.Field(&c.LastName, validation.Required, validation.Min(1)),
-		validation.Field(&c.UserName, validation.Required, validation.Min(1)),
-		validation.Field(&c.Password, validation.Required, validation.Min(8)),
-		validation.Field(&c.ConfirmPassword, validation.Required, validation.Min(8)),
+		validation.Field(&c.FirstName, validation.Required),
+		validation.Field(&c.LastName, validation.Required),
+		validation.Field(&c.UserName, validation.Required),
+		validation.Field(&c.Password, validation.Required),
+		validation.Field(&c.ConfirmPassword, validation.Required),
 	); err != nil {
 		return err
 	}
 
-	if IsPasswordMatched(c.Password, c.ConfirmPassword) {
+	if !IsPasswordMatched(c.Password, c.ConfirmPassword) {
 		return errors.New("Password does not match")
 	}
 
@@ -56,3 +62,64 @@ func (c DoLogoutRequest) Validate() error {
 		validation.Field(&c.RefreshToken, validation.Required),
 	)
 }
+
+func (c ForgotPasswordRequest) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.Email, validation.Required, is.Email),
+	)
+}
+
+func (c ResetPasswordRequest) Validate() error {
+	if err := validation.ValidateStruct(&c,
+		validation.Field(&c.Email, validation.Required, is.Email),
+		validation.Field(&c.OTP, validation.Required),
+		validation.Field(&c.Password, validation.Required),
+		validation.Field(&c.ConfirmPassword, validation.Required),
+	); err != nil {
+		return err
+	}
+
+	if !IsPasswordMatched(c.Password, c.ConfirmPassword) {
+		return errors.New("Password does not match")
+	}
+
+	return nil
+}
+
+func (c MagicLinkRequest) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.Email, validation.Required, is.Email),
+	)
+}
+
+func (c VerifyMagicLinkRequest) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.Token, validation.Required),
+		validation.Field(&c.Nonce, validation.Required),
+	)
+}
+
+func (c RevokeSessionRequest) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.SessionID, validation.Required),
+	)
+}
+
+func (c UnlockAccountRequest) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.Email, validation.Required, is.Email),
+	)
+}
+
+func (c AccountStatusRequest) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.Email, validation.Required, is.Email),
+	)
+}
+
+func (c DoLoginMFARequest) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.MFAToken, validation.Required),
+		validation.Field(&c.Code, validation.Required),
+	)
+}
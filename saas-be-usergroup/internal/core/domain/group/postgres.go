@@ -113,13 +113,13 @@ func (u *UserGroupType) GetOneByID(db *gorm.DB, id uint64) (*UserGroupType, erro
 }
 
 func (u *InGroup) GetTotalMember(db *gorm.DB, userGroupID uint64) (int, error) {
-	var totalMember *int64
+	var totalMember int64
 
-	if err := db.Where("user_group_id = ?", userGroupID).Where("time_removed IS NULL").Find(&u).Count(totalMember).Error; err != nil {
+	if err := db.Model(&InGroup{}).Where("user_group_id = ?", userGroupID).Where("time_removed IS NULL").Count(&totalMember).Error; err != nil {
 		return 0, err
 	}
 
-	return int(*totalMember), nil
+	return int(totalMember), nil
 }
 
 // params bool on first parameter means isErrorInternal
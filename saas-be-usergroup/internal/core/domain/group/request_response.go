@@ -5,6 +5,10 @@ type UserGroupCreateRequest struct {
 	CustomerInvoiceData string `json:"customer_invoice_data"`
 }
 
+func NewUserGroupCreateRequest() *UserGroupCreateRequest {
+	return &UserGroupCreateRequest{}
+}
+
 func (u UserGroupCreateRequest) ToUserGroup() *UserGroup {
 	return &UserGroup{UserGroupTypeID: u.UserGroupTypeID, CustomerInvoiceData: u.CustomerInvoiceData}
 }
@@ -14,6 +18,14 @@ type AddGroupMemberRequest struct {
 	Username    string `json:"username"`
 }
 
+func NewAddGroupMemberRequest() *AddGroupMemberRequest {
+	return &AddGroupMemberRequest{}
+}
+
+func (a AddGroupMemberRequest) GetUsername() string {
+	return a.Username
+}
+
 func (a AddGroupMemberRequest) ToInGroup(userAccountID uint64) *InGroup {
 	return &InGroup{
 		UserGroupID:   a.UserGroupID,
@@ -22,6 +34,14 @@ func (a AddGroupMemberRequest) ToInGroup(userAccountID uint64) *InGroup {
 }
 
 type RemoveGroupMemberRequest struct {
-	UserGroupID   uint64 `json:"user_group_id"`
-	UserAccountID string `json:"username"`
+	UserGroupID uint64 `json:"user_group_id"`
+	Username    string `json:"username"`
+}
+
+func NewRemoveGroupMemberRequest() *RemoveGroupMemberRequest {
+	return &RemoveGroupMemberRequest{}
+}
+
+func (r RemoveGroupMemberRequest) GetUsername() string {
+	return r.Username
 }
@@ -18,6 +18,6 @@ func (u AddGroupMemberRequest) Validate() error {
 func (u RemoveGroupMemberRequest) Validate() error {
 	return validation.ValidateStruct(&u,
 		validation.Field(&u.UserGroupID, validation.Required),
-		validation.Field(&u.UserAccountID, validation.Required),
+		validation.Field(&u.Username, validation.Required),
 	)
 }
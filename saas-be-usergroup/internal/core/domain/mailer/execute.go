@@ -1,7 +0,0 @@
-package mailer
-
-import "context"
-
-func Send(ctx context.Context, mailer Mailer) error {
-	return nil
-}
@@ -20,3 +20,72 @@ func NewRegisterOTPMailer(recipient string, prop interface{}) *Mailer {
 		Prop:      prop,
 	}
 }
+
+type ChangeEmailMail struct {
+	OTP      string
+	Duration uint64
+}
+
+func NewChangeEmailOTPMailer(recipient string, prop interface{}) *Mailer {
+	return &Mailer{
+		Template:  "change-email-otp.html",
+		Recipient: recipient,
+		Subject:   "Change Email OTP",
+		Prop:      prop,
+	}
+}
+
+type ChangePasswordMail struct {
+	OTP      string
+	Duration uint64
+}
+
+func NewChangePasswordOTPMailer(recipient string, prop interface{}) *Mailer {
+	return &Mailer{
+		Template:  "change-password-otp.html",
+		Recipient: recipient,
+		Subject:   "Change Password OTP",
+		Prop:      prop,
+	}
+}
+
+type ResetPasswordMail struct {
+	OTP      string
+	Duration uint64
+}
+
+func NewResetPasswordOTPMailer(recipient string, prop interface{}) *Mailer {
+	return &Mailer{
+		Template:  "reset-password-otp.html",
+		Recipient: recipient,
+		Subject:   "Reset Password OTP",
+		Prop:      prop,
+	}
+}
+
+type MagicLinkMail struct {
+	URL      string
+	Duration uint64
+}
+
+func NewMagicLinkMailer(recipient string, prop interface{}) *Mailer {
+	return &Mailer{
+		Template:  "magic-link.html",
+		Recipient: recipient,
+		Subject:   "Your Login Link",
+		Prop:      prop,
+	}
+}
+
+type AccountLockedMail struct {
+	Duration uint64
+}
+
+func NewAccountLockedMailer(recipient string, prop interface{}) *Mailer {
+	return &Mailer{
+		Template:  "account-locked.html",
+		Recipient: recipient,
+		Subject:   "Account Temporarily Locked",
+		Prop:      prop,
+	}
+}
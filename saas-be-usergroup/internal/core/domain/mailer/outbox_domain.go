@@ -0,0 +1,115 @@
+package mailer
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type OutboxStatus string
+
+const (
+	OutboxPending    OutboxStatus = "pending"
+	OutboxProcessing OutboxStatus = "processing"
+	OutboxSent       OutboxStatus = "sent"
+	OutboxDead       OutboxStatus = "dead"
+)
+
+type EmailOutbox struct {
+	ID            uint64
+	Template      string
+	Recipient     string
+	Subject       string
+	Prop          string
+	Status        OutboxStatus
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	SentTs        *time.Time
+	InsertTs      time.Time
+}
+
+func NewEmailOutbox() *EmailOutbox {
+	return &EmailOutbox{}
+}
+
+func (m Mailer) ToEmailOutbox() (*EmailOutbox, error) {
+	prop, err := json.Marshal(m.Prop)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &EmailOutbox{
+		Template:      m.Template,
+		Recipient:     m.Recipient,
+		Subject:       m.Subject,
+		Prop:          string(prop),
+		Status:        OutboxPending,
+		NextAttemptAt: now,
+		InsertTs:      now,
+	}, nil
+}
+
+// ToMailer decodes the stored prop into a map, templates access it the same way as the original struct
+func (e EmailOutbox) ToMailer() (*Mailer, error) {
+	var prop map[string]interface{}
+	if err := json.Unmarshal([]byte(e.Prop), &prop); err != nil {
+		return nil, err
+	}
+
+	return &Mailer{
+		Template:  e.Template,
+		Recipient: e.Recipient,
+		Subject:   e.Subject,
+		Prop:      prop,
+	}, nil
+}
+
+func (e EmailOutbox) GetID() uint64 {
+	return e.ID
+}
+
+func (e EmailOutbox) GetAttempts() int {
+	return e.Attempts
+}
+
+func (e *EmailOutbox) IsEmpty() bool {
+	return e == nil
+}
+
+func (e EmailOutbox) IsDead() bool {
+	return e.Status == OutboxDead
+}
+
+// scrubbedProp replaces the prop of a finished mail, it carries otps and login links
+// that must not outlive the delivery
+const scrubbedProp = "{}"
+
+func (e *EmailOutbox) SetSent() {
+	now := time.Now()
+	e.Status = OutboxSent
+	e.SentTs = &now
+	e.LastError = ""
+	e.Prop = scrubbedProp
+}
+
+// SetFailed schedules the next attempt with exponential backoff,
+// the mail is moved to dead letter once maxAttempts is reached
+func (e *EmailOutbox) SetFailed(err error, maxAttempts int, baseBackoff time.Duration, maxBackoff time.Duration) {
+	e.Attempts++
+	e.LastError = err.Error()
+
+	if e.Attempts >= maxAttempts {
+		e.Status = OutboxDead
+		e.Prop = scrubbedProp
+		return
+	}
+
+	backoff := baseBackoff << uint(e.Attempts-1)
+	if backoff <= 0 || backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+
+	e.Status = OutboxPending
+	e.NextAttemptAt = time.Now().Add(backoff)
+}
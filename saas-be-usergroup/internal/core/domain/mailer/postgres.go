@@ -0,0 +1,76 @@
+package mailer
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type txKey struct{}
+
+// WithTx makes the outbox row of the mail part of the caller's transaction,
+// the mail is only queued when the state change it belongs to is committed
+func WithTx(ctx context.Context, tx *gorm.DB) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// TxFromContext returns the transaction set by WithTx or db when there is none
+func TxFromContext(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx
+	}
+	return db.WithContext(ctx)
+}
+
+func (e *EmailOutbox) Create(db *gorm.DB) (*EmailOutbox, error) {
+	if err := db.Create(&e).Error; err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (e *EmailOutbox) Update(db *gorm.DB) (*EmailOutbox, error) {
+	if err := db.Save(&e).Error; err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// ClaimDue locks due mails for the lease duration so other workers skip them,
+// a mail whose worker died while processing becomes due again once the lease is over
+func (e *EmailOutbox) ClaimDue(db *gorm.DB, limit int, lease time.Duration) ([]EmailOutbox, error) {
+	var outboxes []EmailOutbox
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status IN ?", []OutboxStatus{OutboxPending, OutboxProcessing}).
+			Where("next_attempt_at <= ?", time.Now()).
+			Order("id").
+			Limit(limit).
+			Find(&outboxes).Error; err != nil {
+			return err
+		}
+
+		if len(outboxes) == 0 {
+			return nil
+		}
+
+		ids := make([]uint64, 0, len(outboxes))
+		for _, outbox := range outboxes {
+			ids = append(ids, outbox.ID)
+		}
+
+		return tx.Model(&EmailOutbox{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"status":          OutboxProcessing,
+			"next_attempt_at": time.Now().Add(lease),
+		}).Error
+	}); err != nil {
+		return nil, err
+	}
+
+	return outboxes, nil
+}
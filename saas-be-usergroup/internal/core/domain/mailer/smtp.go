@@ -0,0 +1,121 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	EncryptionNone     = "none"
+	EncryptionSSL      = "ssl"
+	EncryptionStartTLS = "starttls"
+)
+
+type SMTPConfig struct {
+	Host       string
+	Port       int
+	Encryption string
+	From       string
+	FromName   string
+	Username   string
+	Password   string
+}
+
+func NewSMTPConfig() *SMTPConfig {
+	return &SMTPConfig{
+		Host:       viper.GetString("mailer.host"),
+		Port:       viper.GetInt("mailer.port"),
+		Encryption: strings.ToLower(viper.GetString("mailer.encryption")),
+		From:       viper.GetString("mailer.from"),
+		FromName:   viper.GetString("mailer.from_name"),
+		Username:   viper.GetString("mailer.username"),
+		Password:   viper.GetString("mailer.password"),
+	}
+}
+
+func (s SMTPConfig) GetAddr() string {
+	return net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+}
+
+func (s SMTPConfig) GetFrom() mail.Address {
+	return mail.Address{Name: s.FromName, Address: s.From}
+}
+
+// Deliver sends the mailer through the smtp server, "ssl" dials an implicit tls connection,
+// "starttls" upgrades a plain connection and "none" keeps it plain, e.g. for a local smtp server
+func (s SMTPConfig) Deliver(ctx context.Context, m Mailer) error {
+	msg, err := m.BuildMessage(s.GetFrom())
+	if err != nil {
+		return err
+	}
+
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return err
+	}
+
+	// abort the smtp conversation as soon as the context is done
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+
+	client, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if s.Encryption == EncryptionStartTLS {
+		if err = client.StartTLS(&tls.Config{ServerName: s.Host}); err != nil {
+			return err
+		}
+	}
+
+	if s.Username != "" {
+		if err = client.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err = client.Mail(s.From); err != nil {
+		return err
+	}
+	if err = client.Rcpt(m.Recipient); err != nil {
+		return err
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(msg); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+func (s SMTPConfig) dial(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{}
+	if s.Encryption == EncryptionSSL {
+		return (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: s.Host}}).DialContext(ctx, "tcp", s.GetAddr())
+	}
+	return dialer.DialContext(ctx, "tcp", s.GetAddr())
+}
@@ -0,0 +1,201 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpStandIn is a minimal in-process smtp server, it accepts a single message and keeps the envelope and the data
+type smtpStandIn struct {
+	listener net.Listener
+	from     string
+	rcpt     []string
+	data     chan string
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	s := &smtpStandIn{listener: listener, data: make(chan string, 1)}
+	t.Cleanup(func() { listener.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *smtpStandIn) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpStandIn) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost ESMTP stand-in")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch {
+		case verb == "EHLO" || verb == "HELO":
+			reply("250 localhost")
+		case strings.HasPrefix(strings.ToUpper(line), "MAIL FROM:"):
+			s.from = strings.Trim(line[len("MAIL FROM:"):], "<> ")
+			reply("250 OK")
+		case strings.HasPrefix(strings.ToUpper(line), "RCPT TO:"):
+			s.rcpt = append(s.rcpt, strings.Trim(line[len("RCPT TO:"):], "<> "))
+			reply("250 OK")
+		case verb == "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				dataLine, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(dataLine, "."))
+			}
+			s.data <- data.String()
+			reply("250 OK queued")
+		case verb == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSMTPConfigDeliver(t *testing.T) {
+	server := newSMTPStandIn(t)
+	config := SMTPConfig{
+		Host:       "127.0.0.1",
+		Port:       server.port(),
+		Encryption: EncryptionNone,
+		From:       "no-reply@example.com",
+		FromName:   "SaaS",
+	}
+	m := NewRegisterOTPMailer("alice@example.com", &RegisterMail{OTP: "482913", Duration: 180})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := config.Deliver(ctx, *m); err != nil {
+		t.Fatalf("Deliver() error = %v", err)
+	}
+
+	var raw string
+	select {
+	case raw = <-server.data:
+	case <-ctx.Done():
+		t.Fatal("stand-in did not receive the message")
+	}
+
+	if server.from != "no-reply@example.com" {
+		t.Errorf("MAIL FROM = %q, want no-reply@example.com", server.from)
+	}
+	if len(server.rcpt) != 1 || server.rcpt[0] != "alice@example.com" {
+		t.Errorf("RCPT TO = %v, want [alice@example.com]", server.rcpt)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("failed to parse message: %v", err)
+	}
+
+	wantHeaders := map[string]string{
+		"From":         `"SaaS" <no-reply@example.com>`,
+		"To":           "<alice@example.com>",
+		"Subject":      "Register OTP",
+		"Mime-Version": "1.0",
+	}
+	for key, want := range wantHeaders {
+		if got := msg.Header.Get(key); got != want {
+			t.Errorf("header %s = %q, want %q", key, got, want)
+		}
+	}
+	if msg.Header.Get("Date") == "" || !strings.HasSuffix(msg.Header.Get("Message-Id"), "@example.com>") {
+		t.Errorf("missing Date or Message-ID, got %q and %q", msg.Header.Get("Date"), msg.Header.Get("Message-Id"))
+	}
+
+	// NextPart decodes quoted-printable and drops the header, so the encoding is checked on the raw message
+	if strings.Count(raw, "Content-Transfer-Encoding: quoted-printable") != 2 {
+		t.Errorf("want both parts quoted-printable encoded")
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, want multipart/alternative", msg.Header.Get("Content-Type"))
+	}
+
+	parts := map[string]string{}
+	var order []string
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			break
+		}
+		body, err := ioutil.ReadAll(part)
+		if err != nil {
+			t.Fatalf("failed to read part: %v", err)
+		}
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		parts[contentType] = string(body)
+		order = append(order, contentType)
+	}
+
+	if len(order) != 2 || order[0] != "text/plain" || order[1] != "text/html" {
+		t.Fatalf("parts = %v, want [text/plain text/html]", order)
+	}
+	if !strings.Contains(parts["text/plain"], "482913") || strings.Contains(parts["text/plain"], "<p>") {
+		t.Errorf("text/plain part = %q, want the otp without markup", parts["text/plain"])
+	}
+	if !strings.Contains(parts["text/html"], "482913") || !strings.Contains(parts["text/html"], "<html>") {
+		t.Errorf("text/html part = %q, want the otp inside html", parts["text/html"])
+	}
+	if !strings.Contains(parts["text/plain"], strconv.Itoa(180)) {
+		t.Errorf("text/plain part = %q, want the duration", parts["text/plain"])
+	}
+}
+
+func TestSMTPConfigDeliverUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	config := SMTPConfig{Host: "127.0.0.1", Port: port, Encryption: EncryptionNone, From: "no-reply@example.com"}
+	m := NewRegisterOTPMailer("alice@example.com", &RegisterMail{OTP: "482913", Duration: 180})
+
+	if err := config.Deliver(context.Background(), *m); err == nil {
+		t.Fatal("Deliver() error = nil, want a dial error")
+	}
+}
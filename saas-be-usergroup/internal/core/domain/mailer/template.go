@@ -0,0 +1,38 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	htmlTemplate "html/template"
+	"strings"
+	textTemplate "text/template"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+// Render executes the html template of the mailer and its plain-text alternative,
+// the plain-text template shares the name of the html one with a .txt extension
+func (m Mailer) Render() (html string, text string, err error) {
+	htmlTpl, err := htmlTemplate.ParseFS(templateFS, "templates/"+m.Template)
+	if err != nil {
+		return "", "", err
+	}
+
+	var htmlBuf bytes.Buffer
+	if err = htmlTpl.Execute(&htmlBuf, m.Prop); err != nil {
+		return "", "", err
+	}
+
+	textTpl, err := textTemplate.ParseFS(templateFS, "templates/"+strings.TrimSuffix(m.Template, ".html")+".txt")
+	if err != nil {
+		return "", "", err
+	}
+
+	var textBuf bytes.Buffer
+	if err = textTpl.Execute(&textBuf, m.Prop); err != nil {
+		return "", "", err
+	}
+
+	return htmlBuf.String(), textBuf.String(), nil
+}
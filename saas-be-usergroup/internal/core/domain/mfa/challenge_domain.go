@@ -0,0 +1,59 @@
+package mfa
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/saas-be-usergroup/internal/core/domain/auth"
+	"gorm.io/gorm"
+)
+
+type Challenge struct {
+	Token string
+	UUID  string
+}
+
+func NewChallenge(token string, uuid string) *Challenge {
+	return &Challenge{
+		Token: token,
+		UUID:  uuid,
+	}
+}
+
+func GenerateChallenge(uuid string) (*Challenge, error) {
+	token, err := auth.GenerateRandomSession()
+	if err != nil {
+		return nil, err
+	}
+	return NewChallenge(token, uuid), nil
+}
+
+func (c *Challenge) IsEmpty() bool {
+	return c == nil
+}
+
+func (c Challenge) GetUUID() string {
+	return c.UUID
+}
+
+func (c Challenge) ToDoLoginResponse() *auth.DoLoginResponse {
+	return &auth.DoLoginResponse{
+		MFARequired: true,
+		MFAToken:    c.Token,
+	}
+}
+
+// VerifyCode accepts a current totp code once per time step or an unused recovery code
+func (u UserTOTP) VerifyCode(ctx context.Context, db *gorm.DB, client *redis.Client, uuid string, code string) (bool, error) {
+	if IsRecoveryCode(code) {
+		return UseRecoveryCode(db, u.UserAccountID, code)
+	}
+
+	step, ok := u.VerifyAt(code, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	return MarkTOTPStepUsed(ctx, client, uuid, step)
+}
@@ -0,0 +1,79 @@
+package mfa
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func (u *UserTOTP) GetOneByUserAccountID(db *gorm.DB, userAccountID uint64) (*UserTOTP, error) {
+	if err := db.Where("user_account_id = ?", userAccountID).First(&u).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return u, nil
+}
+
+func (u *UserTOTP) Save(db *gorm.DB) (*UserTOTP, error) {
+	if err := db.Save(&u).Error; err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// Enable stores the verified totp together with a fresh set of recovery codes
+func (u *UserTOTP) Enable(db *gorm.DB, recoveryCodes []UserRecoveryCode) (*UserTOTP, error) {
+	u.SetEnabled()
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&u).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_account_id = ?", u.UserAccountID).Delete(&UserRecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&recoveryCodes).Error
+	}); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// Delete removes the totp and every recovery code of the user
+func (u *UserTOTP) Delete(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_account_id = ?", u.UserAccountID).Delete(&UserRecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&u).Error
+	})
+}
+
+// UseRecoveryCode marks the code as used, false means it does not exist or has been used already
+func UseRecoveryCode(db *gorm.DB, userAccountID uint64, code string) (bool, error) {
+	result := db.Model(&UserRecoveryCode{}).
+		Where("user_account_id = ? AND code_hash = ? AND used_ts IS NULL", userAccountID, HashRecoveryCode(code)).
+		Update("used_ts", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+func CountUnusedRecoveryCodes(db *gorm.DB, userAccountID uint64) (int64, error) {
+	var count int64
+	if err := db.Model(&UserRecoveryCode{}).
+		Where("user_account_id = ? AND used_ts IS NULL", userAccountID).
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
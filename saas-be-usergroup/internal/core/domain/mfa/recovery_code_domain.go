@@ -0,0 +1,62 @@
+package mfa
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+var (
+	recoveryCodeCount = 10
+	recoveryCodeBytes = 10
+)
+
+type UserRecoveryCode struct {
+	ID            uint64
+	UserAccountID uint64
+	CodeHash      string
+	UsedTs        *time.Time
+	InsertTs      time.Time
+}
+
+// GenerateRecoveryCodes returns the plain codes to show once and their hashed rows to store
+func GenerateRecoveryCodes(userAccountID uint64) ([]string, []UserRecoveryCode, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	rows := make([]UserRecoveryCode, 0, recoveryCodeCount)
+	encoder := base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	now := time.Now()
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+
+		encoded := strings.ToLower(encoder.EncodeToString(b))
+		code := encoded[:8] + "-" + encoded[8:]
+		codes = append(codes, code)
+		rows = append(rows, UserRecoveryCode{
+			UserAccountID: userAccountID,
+			CodeHash:      HashRecoveryCode(code),
+			InsertTs:      now,
+		})
+	}
+
+	return codes, rows, nil
+}
+
+// HashRecoveryCode uses sha256 instead of bcrypt, the codes carry 80 random bits
+// so they can not be brute forced and the hash can be looked up directly
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsRecoveryCode tells a recovery code from a totp code, totp codes are digits only
+func IsRecoveryCode(code string) bool {
+	return len(strings.TrimSpace(code)) != totpDigits
+}
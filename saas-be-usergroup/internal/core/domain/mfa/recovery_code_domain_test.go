@@ -0,0 +1,92 @@
+package mfa
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var recoveryCodeFormat = regexp.MustCompile(`^[a-z2-7]{8}-[a-z2-7]{8}$`)
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, rows, err := GenerateRecoveryCodes(42)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes() error = %v", err)
+	}
+	if len(codes) != recoveryCodeCount || len(rows) != recoveryCodeCount {
+		t.Fatalf("got %d codes and %d rows, want %d", len(codes), len(rows), recoveryCodeCount)
+	}
+
+	seen := map[string]bool{}
+	for i, code := range codes {
+		if !recoveryCodeFormat.MatchString(code) {
+			t.Errorf("code %q, want two groups of 8 base32 characters", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q generated twice", code)
+		}
+		seen[code] = true
+
+		row := rows[i]
+		if row.UserAccountID != 42 || row.UsedTs != nil {
+			t.Errorf("row %+v, want an unused code of account 42", row)
+		}
+		if row.CodeHash != HashRecoveryCode(code) || strings.Contains(row.CodeHash, code) {
+			t.Errorf("row of %q does not hold its hash", code)
+		}
+		if !IsRecoveryCode(code) {
+			t.Errorf("IsRecoveryCode(%q) = false", code)
+		}
+	}
+}
+
+func TestHashRecoveryCodeNormalizes(t *testing.T) {
+	want := HashRecoveryCode("abcdefgh-ijklmnop")
+	for _, code := range []string{"ABCDEFGH-IJKLMNOP", "abcdefghijklmnop", "  abcdefgh-ijklmnop  ", "ab-cd-ef-gh-ij-kl-mn-op"} {
+		if got := HashRecoveryCode(code); got != want {
+			t.Errorf("HashRecoveryCode(%q) differs from the code as shown", code)
+		}
+	}
+	if HashRecoveryCode("abcdefgh-ijklmnoq") == want {
+		t.Error("HashRecoveryCode() is the same for another code")
+	}
+}
+
+func TestIsRecoveryCode(t *testing.T) {
+	tests := []struct {
+		code string
+		want bool
+	}{
+		{code: "123456", want: false},
+		{code: " 123456 ", want: false},
+		{code: "abcdefgh-ijklmnop", want: true},
+		{code: "abcdefghijklmnop", want: true},
+		{code: "12345", want: true},
+	}
+
+	for _, tt := range tests {
+		if got := IsRecoveryCode(tt.code); got != tt.want {
+			t.Errorf("IsRecoveryCode(%q) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyAtAcceptsTheSkewOnly(t *testing.T) {
+	totp := UserTOTP{Secret: "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"}
+	now := time.Unix(1700000000, 0)
+	step := now.Unix() / int64(totpInterval)
+
+	for skew := -totpSkew - 1; skew <= totpSkew+1; skew++ {
+		code := totp.getTOTP().At((step + int64(skew)) * int64(totpInterval))
+		gotStep, ok := totp.VerifyAt(code, now)
+
+		wantOK := skew >= -totpSkew && skew <= totpSkew
+		if ok != wantOK {
+			t.Errorf("code of step %+d accepted = %v, want %v", skew, ok, wantOK)
+		}
+		if ok && gotStep != step+int64(skew) {
+			t.Errorf("code of step %+d verified at step %d, want %d", skew, gotStep, step+int64(skew))
+		}
+	}
+}
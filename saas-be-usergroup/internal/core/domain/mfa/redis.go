@@ -0,0 +1,93 @@
+package mfa
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/spf13/viper"
+)
+
+var (
+	defaultChallengeTTL         = time.Minute * 5
+	defaultChallengeMaxAttempts = int64(5)
+)
+
+func GetChallengeTTL() time.Duration {
+	if ttl := viper.GetDuration("mfa.challenge_ttl"); ttl > 0 {
+		return ttl
+	}
+	return defaultChallengeTTL
+}
+
+func GetChallengeMaxAttempts() int64 {
+	if maxAttempts := viper.GetInt64("mfa.challenge_max_attempts"); maxAttempts > 0 {
+		return maxAttempts
+	}
+	return defaultChallengeMaxAttempts
+}
+
+func getChallengeKey(token string) string {
+	return "mfa-challenge-" + token
+}
+
+func getChallengeAttemptsKey(token string) string {
+	return "mfa-challenge-" + token + "-attempts"
+}
+
+// Create stores the challenge returned by a login with the right password, the token proves only the first factor
+func (c Challenge) Create(ctx context.Context, client *redis.Client) (*Challenge, error) {
+	if err := client.SetEX(ctx, getChallengeKey(c.Token), c.UUID, GetChallengeTTL()).Err(); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+func GetChallengeByToken(ctx context.Context, client *redis.Client, token string) (*Challenge, error) {
+	uuid, err := client.Get(ctx, getChallengeKey(token)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return NewChallenge(token, uuid), nil
+}
+
+// Consume deletes the challenge so it can only be exchanged for tokens once
+func (c Challenge) Consume(ctx context.Context, client *redis.Client) (bool, error) {
+	deleted, err := client.Del(ctx, getChallengeKey(c.Token), getChallengeAttemptsKey(c.Token)).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return deleted > 0, nil
+}
+
+// Fail counts a wrong code, the challenge is dropped at mfa.challenge_max_attempts and the login has to start over
+func (c Challenge) Fail(ctx context.Context, client *redis.Client) error {
+	attempts, err := client.Incr(ctx, getChallengeAttemptsKey(c.Token)).Result()
+	if err != nil {
+		return err
+	}
+
+	if err = client.Expire(ctx, getChallengeAttemptsKey(c.Token), GetChallengeTTL()).Err(); err != nil {
+		return err
+	}
+
+	if attempts >= GetChallengeMaxAttempts() {
+		return client.Del(ctx, getChallengeKey(c.Token), getChallengeAttemptsKey(c.Token)).Err()
+	}
+
+	return nil
+}
+
+// MarkTOTPStepUsed refuses a code of a time step that has already been accepted for the user
+func MarkTOTPStepUsed(ctx context.Context, client *redis.Client, uuid string, step int64) (bool, error) {
+	key := "totp-used-" + uuid + "-" + strconv.FormatInt(step, 10)
+	ttl := time.Duration(totpInterval*(2*totpSkew+1)) * time.Second
+	return client.SetNX(ctx, key, 1, ttl).Result()
+}
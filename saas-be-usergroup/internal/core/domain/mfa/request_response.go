@@ -0,0 +1,43 @@
+package mfa
+
+type EnrollResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type VerifyTOTPRequest struct {
+	Code string `json:"code"`
+}
+
+func NewVerifyTOTPRequest() *VerifyTOTPRequest {
+	return &VerifyTOTPRequest{}
+}
+
+func (v VerifyTOTPRequest) GetCode() string {
+	return v.Code
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func NewRecoveryCodesResponse(codes []string) *RecoveryCodesResponse {
+	return &RecoveryCodesResponse{RecoveryCodes: codes}
+}
+
+type DisableTOTPRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+func NewDisableTOTPRequest() *DisableTOTPRequest {
+	return &DisableTOTPRequest{}
+}
+
+func (d DisableTOTPRequest) GetPassword() string {
+	return d.Password
+}
+
+func (d DisableTOTPRequest) GetCode() string {
+	return d.Code
+}
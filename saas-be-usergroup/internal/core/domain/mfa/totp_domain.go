@@ -0,0 +1,100 @@
+package mfa
+
+import (
+	"crypto/subtle"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/xlzd/gotp"
+)
+
+var (
+	defaultIssuer   = "saas-be-usergroup"
+	totpSecretBytes = 20
+	totpDigits      = 6
+	totpInterval    = 30
+	// one step before and after the current one is accepted for clock drift of the phone
+	totpSkew = 1
+)
+
+func getIssuer() string {
+	if issuer := viper.GetString("mfa.issuer"); issuer != "" {
+		return issuer
+	}
+	return defaultIssuer
+}
+
+type UserTOTP struct {
+	ID            uint64
+	UserAccountID uint64
+	Secret        string
+	Enabled       bool
+	EnabledTs     *time.Time
+	InsertTs      time.Time
+}
+
+func NewUserTOTP() *UserTOTP {
+	return &UserTOTP{}
+}
+
+// NewPendingUserTOTP generates a new secret, it is only enabled once the first code has been verified
+func NewPendingUserTOTP(userAccountID uint64) *UserTOTP {
+	return &UserTOTP{
+		UserAccountID: userAccountID,
+		Secret:        gotp.RandomSecret(totpSecretBytes),
+		InsertTs:      time.Now(),
+	}
+}
+
+func (u *UserTOTP) IsEmpty() bool {
+	return u == nil
+}
+
+func (u UserTOTP) IsEnabled() bool {
+	return u.Enabled
+}
+
+func (u UserTOTP) GetUserAccountID() uint64 {
+	return u.UserAccountID
+}
+
+func (u *UserTOTP) SetEnabled() {
+	now := time.Now()
+	u.Enabled = true
+	u.EnabledTs = &now
+}
+
+// SetSecret replaces the secret of an enrollment that has not been verified yet
+func (u *UserTOTP) SetSecret(secret string) {
+	u.Secret = secret
+	u.InsertTs = time.Now()
+}
+
+func (u UserTOTP) getTOTP() *gotp.TOTP {
+	return gotp.NewTOTP(u.Secret, totpDigits, totpInterval, nil)
+}
+
+func (u UserTOTP) GetProvisioningURI(accountName string) string {
+	return u.getTOTP().ProvisioningUri(accountName, getIssuer())
+}
+
+// VerifyAt returns the time step the code belongs to, the caller uses it to refuse the same code twice
+func (u UserTOTP) VerifyAt(code string, at time.Time) (int64, bool) {
+	totp := u.getTOTP()
+	step := at.Unix() / int64(totpInterval)
+	for skew := -totpSkew; skew <= totpSkew; skew++ {
+		candidate := step + int64(skew)
+		expected := totp.At(candidate * int64(totpInterval))
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return candidate, true
+		}
+	}
+	return 0, false
+}
+
+func (u UserTOTP) ToEnrollResponse(accountName string) *EnrollResponse {
+	return &EnrollResponse{
+		Secret:     u.Secret,
+		OTPAuthURI: u.GetProvisioningURI(accountName),
+	}
+}
@@ -0,0 +1,18 @@
+package mfa
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+func (c VerifyTOTPRequest) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.Code, validation.Required),
+	)
+}
+
+func (c DisableTOTPRequest) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.Password, validation.Required),
+		validation.Field(&c.Code, validation.Required),
+	)
+}
@@ -0,0 +1,174 @@
+package oauth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/url"
+	"regexp"
+)
+
+const (
+	consentTokenBytes      = 32
+	authorizationCodeBytes = 32
+)
+
+// a pkce code verifier is 43 to 128 unreserved characters, rfc 7636 section 4.1
+var codeVerifierRegex = regexp.MustCompile(`^[A-Za-z0-9\-._~]{43,128}$`)
+
+// Consent is an authorization request that has been checked and waits for the decision of the user
+type Consent struct {
+	Token         string
+	ClientID      string
+	UUID          string
+	RedirectURI   string
+	Scope         string
+	State         string
+	CodeChallenge string
+}
+
+func NewConsent(token string, clientID string, uuid string, redirectURI string, scope string, state string, codeChallenge string) *Consent {
+	return &Consent{
+		Token:         token,
+		ClientID:      clientID,
+		UUID:          uuid,
+		RedirectURI:   redirectURI,
+		Scope:         scope,
+		State:         state,
+		CodeChallenge: codeChallenge,
+	}
+}
+
+func (a AuthorizationRequest) ToConsent(uuid string, scope string) (*Consent, error) {
+	token, err := generateRandomToken(consentTokenBytes)
+	if err != nil {
+		return nil, err
+	}
+	return NewConsent(token, a.ClientID, uuid, a.RedirectURI, scope, a.State, a.CodeChallenge), nil
+}
+
+func (c *Consent) IsEmpty() bool {
+	return c == nil
+}
+
+func (c Consent) GetClientID() string {
+	return c.ClientID
+}
+
+func (c Consent) IsOwnedBy(uuid string) bool {
+	return c.UUID == uuid
+}
+
+func (c Consent) ToConsentResponse(client OAuthClient) *ConsentResponse {
+	scopes := make([]ScopeResponse, 0)
+	for _, scope := range splitList(c.Scope) {
+		scopes = append(scopes, ScopeResponse{Name: scope, Description: getScopeDescription(scope)})
+	}
+
+	return &ConsentResponse{
+		ConsentToken: c.Token,
+		Client: ClientResponse{
+			ClientID: client.GetClientID(),
+			Name:     client.GetName(),
+		},
+		Scopes:      scopes,
+		RedirectURI: c.RedirectURI,
+	}
+}
+
+// ToAuthorizationCode approves the consent, the code inherits everything the token request is checked against
+func (c Consent) ToAuthorizationCode() (*AuthorizationCode, error) {
+	code, err := generateRandomToken(authorizationCodeBytes)
+	if err != nil {
+		return nil, err
+	}
+	return NewAuthorizationCode(code, c.ClientID, c.UUID, c.RedirectURI, c.Scope, c.CodeChallenge), nil
+}
+
+// ToDeniedResponse sends the user back to the client with access_denied
+func (c Consent) ToDeniedResponse() *DecisionResponse {
+	return &DecisionResponse{RedirectURI: buildRedirectURI(c.RedirectURI, map[string]string{
+		"error": ErrorAccessDenied,
+		"state": c.State,
+	})}
+}
+
+func (c Consent) ToApprovedResponse(code AuthorizationCode) *DecisionResponse {
+	return &DecisionResponse{RedirectURI: buildRedirectURI(c.RedirectURI, map[string]string{
+		"code":  code.Code,
+		"state": c.State,
+	})}
+}
+
+type AuthorizationCode struct {
+	Code          string
+	ClientID      string
+	UUID          string
+	RedirectURI   string
+	Scope         string
+	CodeChallenge string
+}
+
+func NewAuthorizationCode(code string, clientID string, uuid string, redirectURI string, scope string, codeChallenge string) *AuthorizationCode {
+	return &AuthorizationCode{
+		Code:          code,
+		ClientID:      clientID,
+		UUID:          uuid,
+		RedirectURI:   redirectURI,
+		Scope:         scope,
+		CodeChallenge: codeChallenge,
+	}
+}
+
+func (a *AuthorizationCode) IsEmpty() bool {
+	return a == nil
+}
+
+func (a AuthorizationCode) GetUUID() string {
+	return a.UUID
+}
+
+func (a AuthorizationCode) GetScope() string {
+	return a.Scope
+}
+
+func (a AuthorizationCode) GetGrantID() string {
+	return GetGrantID(a.Code)
+}
+
+// GetGrantID names the tokens issued from a code, the grant of a replayed code is found again from the code alone
+func GetGrantID(code string) string {
+	return hashToken(code)
+}
+
+// IsIssuedFor checks the code was issued to the client for the same redirect uri, rfc 6749 section 4.1.3
+func (a AuthorizationCode) IsIssuedFor(clientID string, redirectURI string) bool {
+	return a.ClientID == clientID && a.RedirectURI == redirectURI
+}
+
+// VerifyCodeVerifier only knows S256, the plain method would give the verifier away with the challenge
+func (a AuthorizationCode) VerifyCodeVerifier(codeVerifier string) bool {
+	if !codeVerifierRegex.MatchString(codeVerifier) {
+		return false
+	}
+	sum := sha256.Sum256([]byte(codeVerifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(challenge), []byte(a.CodeChallenge)) == 1
+}
+
+// buildRedirectURI keeps the query the client registered and drops empty parameters
+func buildRedirectURI(redirectURI string, params map[string]string) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+
+	query := u.Query()
+	for key, value := range params {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
@@ -0,0 +1,131 @@
+package oauth
+
+import (
+	"crypto/subtle"
+	"time"
+)
+
+const (
+	clientIDBytes     = 16
+	clientSecretBytes = 32
+)
+
+// OAuthClient is a registered third-party app, a public client has no secret and must use pkce
+type OAuthClient struct {
+	ID               uint64
+	ClientID         string
+	ClientSecretHash string
+	Name             string
+	RedirectURIs     string
+	Scopes           string
+	GrantTypes       string
+	Confidential     bool
+	InsertTs         time.Time
+}
+
+// TableName keeps the table oauth_clients, the default naming would split the initialism
+func (OAuthClient) TableName() string {
+	return "oauth_clients"
+}
+
+func NewOAuthClient() *OAuthClient {
+	return &OAuthClient{}
+}
+
+func (o *OAuthClient) IsEmpty() bool {
+	return o == nil
+}
+
+func (o OAuthClient) GetClientID() string {
+	return o.ClientID
+}
+
+func (o OAuthClient) GetName() string {
+	return o.Name
+}
+
+func (o OAuthClient) IsConfidential() bool {
+	return o.Confidential
+}
+
+func (o OAuthClient) GetScopes() []string {
+	return splitList(o.Scopes)
+}
+
+func (o OAuthClient) HasRedirectURI(redirectURI string) bool {
+	return contains(splitList(o.RedirectURIs), redirectURI)
+}
+
+func (o OAuthClient) AllowsGrant(grantType string) bool {
+	return contains(splitList(o.GrantTypes), grantType)
+}
+
+// Authenticate checks the secret of a confidential client, a public client must not send one
+func (o OAuthClient) Authenticate(secret string) bool {
+	if !o.Confidential {
+		return secret == ""
+	}
+	if secret == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hashToken(secret)), []byte(o.ClientSecretHash)) == 1
+}
+
+// GrantScope returns the scopes to grant for the request, an empty request gets every scope of the client
+func (o OAuthClient) GrantScope(requested string) (string, bool) {
+	scopes := splitList(requested)
+	if len(scopes) == 0 {
+		return o.Scopes, true
+	}
+
+	granted := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !contains(o.GetScopes(), scope) {
+			return "", false
+		}
+		if !contains(granted, scope) {
+			granted = append(granted, scope)
+		}
+	}
+	return joinList(granted), true
+}
+
+func (o OAuthClient) ToRegisterClientResponse(secret string) *RegisterClientResponse {
+	return &RegisterClientResponse{
+		ClientID:     o.ClientID,
+		ClientSecret: secret,
+		Name:         o.Name,
+		RedirectURIs: splitList(o.RedirectURIs),
+		Scopes:       o.GetScopes(),
+		GrantTypes:   splitList(o.GrantTypes),
+		Confidential: o.Confidential,
+	}
+}
+
+// ToClient generates the credentials of the client, the secret is returned once and only its hash is kept
+func (r RegisterClientRequest) ToClient() (*OAuthClient, string, error) {
+	clientID, err := generateRandomToken(clientIDBytes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	client := &OAuthClient{
+		ClientID:     clientID,
+		Name:         r.Name,
+		RedirectURIs: joinList(r.RedirectURIs),
+		Scopes:       joinList(r.Scopes),
+		GrantTypes:   joinList(r.GrantTypes),
+		Confidential: r.Confidential,
+		InsertTs:     time.Now(),
+	}
+
+	var secret string
+	if r.Confidential {
+		if secret, err = generateRandomToken(clientSecretBytes); err != nil {
+			return nil, "", err
+		}
+		client.ClientSecretHash = hashToken(secret)
+	}
+
+	return client, secret, nil
+}
@@ -0,0 +1,166 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+
+	ResponseTypeCode          = "code"
+	CodeChallengeMethodS256   = "S256"
+	TokenTypeBearer           = "Bearer"
+	TokenTypeHintAccessToken  = "access_token"
+	TokenTypeHintRefreshToken = "refresh_token"
+
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// error codes of rfc 6749 section 5.2, the token, introspection and revocation endpoints answer with them as is
+const (
+	ErrorInvalidRequest          = "invalid_request"
+	ErrorInvalidClient           = "invalid_client"
+	ErrorInvalidGrant            = "invalid_grant"
+	ErrorUnauthorizedClient      = "unauthorized_client"
+	ErrorUnsupportedGrantType    = "unsupported_grant_type"
+	ErrorInvalidScope            = "invalid_scope"
+	ErrorAccessDenied            = "access_denied"
+	ErrorUnsupportedResponseType = "unsupported_response_type"
+	ErrorServerError             = "server_error"
+)
+
+var (
+	defaultScopes     = []string{ScopeProfile, ScopeEmail}
+	defaultCodeTTL    = time.Minute
+	defaultConsentTTL = time.Minute * 10
+	defaultAccessTTL  = time.Hour
+	defaultRefreshTTL = time.Hour * 24 * 30
+	scopeDescriptions = map[string]string{
+		ScopeProfile: "Read your name and username",
+		ScopeEmail:   "Read your email address",
+	}
+	supportedGrantTypes = []string{GrantAuthorizationCode, GrantRefreshToken, GrantClientCredentials}
+)
+
+// GetSupportedScopes lists every scope a client may be registered with, oauth.scopes in config replaces the defaults
+func GetSupportedScopes() []string {
+	if scopes := viper.GetStringSlice("oauth.scopes"); len(scopes) > 0 {
+		return scopes
+	}
+	return defaultScopes
+}
+
+func GetCodeTTL() time.Duration {
+	if ttl := viper.GetDuration("oauth.code_ttl"); ttl > 0 {
+		return ttl
+	}
+	return defaultCodeTTL
+}
+
+func GetConsentTTL() time.Duration {
+	if ttl := viper.GetDuration("oauth.consent_ttl"); ttl > 0 {
+		return ttl
+	}
+	return defaultConsentTTL
+}
+
+func GetAccessTTL() time.Duration {
+	if ttl := viper.GetDuration("oauth.access_ttl"); ttl > 0 {
+		return ttl
+	}
+	return defaultAccessTTL
+}
+
+func GetRefreshTTL() time.Duration {
+	if ttl := viper.GetDuration("oauth.refresh_ttl"); ttl > 0 {
+		return ttl
+	}
+	return defaultRefreshTTL
+}
+
+func isSupportedScope(scope string) bool {
+	return contains(GetSupportedScopes(), scope)
+}
+
+func isSupportedGrantType(grantType string) bool {
+	return contains(supportedGrantTypes, grantType)
+}
+
+func getScopeDescription(scope string) string {
+	if description, ok := scopeDescriptions[scope]; ok {
+		return description
+	}
+	return scope
+}
+
+// Error is the json error body of rfc 6749, Status is the http status it is sent with
+type Error struct {
+	Status      int    `json:"-"`
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func NewError(status int, code string, description string) *Error {
+	return &Error{
+		Status:      status,
+		Code:        code,
+		Description: description,
+	}
+}
+
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+func NewInvalidClientError() *Error {
+	return NewError(http.StatusUnauthorized, ErrorInvalidClient, "client authentication failed")
+}
+
+func NewServerError() *Error {
+	return NewError(http.StatusInternalServerError, ErrorServerError, "")
+}
+
+func generateRandomToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken keys the stored secrets and tokens, they are random enough for a plain sha256
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func splitList(value string) []string {
+	return strings.Fields(value)
+}
+
+func joinList(values []string) string {
+	return strings.Join(values, " ")
+}
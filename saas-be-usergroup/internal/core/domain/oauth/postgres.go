@@ -0,0 +1,27 @@
+package oauth
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+func (o *OAuthClient) Create(db *gorm.DB) (*OAuthClient, error) {
+	if err := db.Create(&o).Error; err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+func (o *OAuthClient) GetOneByClientID(db *gorm.DB, clientID string) (*OAuthClient, error) {
+	if err := db.Where("client_id = ?", clientID).First(&o).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return o, nil
+}
@@ -0,0 +1,215 @@
+package oauth
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func getConsentKey(token string) string {
+	return "oauth-consent-" + token
+}
+
+func getAuthorizationCodeKey(code string) string {
+	return "oauth-code-" + hashToken(code)
+}
+
+func getRefreshTokenKey(token string) string {
+	return getRefreshTokenKeyByHash(hashToken(token))
+}
+
+func getRefreshTokenKeyByHash(tokenHash string) string {
+	return "oauth-refresh-token-" + tokenHash
+}
+
+func getGrantKey(grantID string) string {
+	return "oauth-grant-" + grantID
+}
+
+func (c Consent) Create(ctx context.Context, client *redis.Client) (*Consent, error) {
+	if err := setHash(ctx, client, getConsentKey(c.Token), map[string]interface{}{
+		"client_id":      c.ClientID,
+		"uuid":           c.UUID,
+		"redirect_uri":   c.RedirectURI,
+		"scope":          c.Scope,
+		"state":          c.State,
+		"code_challenge": c.CodeChallenge,
+	}, GetConsentTTL()); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// ConsumeConsent returns the pending consent and deletes it, a decision is taken once
+func ConsumeConsent(ctx context.Context, client *redis.Client, token string) (*Consent, error) {
+	values, err := consumeHash(ctx, client, getConsentKey(token))
+	if err != nil || values == nil {
+		return nil, err
+	}
+
+	return NewConsent(token, values["client_id"], values["uuid"], values["redirect_uri"], values["scope"], values["state"], values["code_challenge"]), nil
+}
+
+func (a AuthorizationCode) Create(ctx context.Context, client *redis.Client) (*AuthorizationCode, error) {
+	if err := setHash(ctx, client, getAuthorizationCodeKey(a.Code), map[string]interface{}{
+		"client_id":      a.ClientID,
+		"uuid":           a.UUID,
+		"redirect_uri":   a.RedirectURI,
+		"scope":          a.Scope,
+		"code_challenge": a.CodeChallenge,
+	}, GetCodeTTL()); err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+// ConsumeAuthorizationCode returns the code and deletes it, rfc 6749 allows a code to be exchanged once
+func ConsumeAuthorizationCode(ctx context.Context, client *redis.Client, code string) (*AuthorizationCode, error) {
+	values, err := consumeHash(ctx, client, getAuthorizationCodeKey(code))
+	if err != nil || values == nil {
+		return nil, err
+	}
+
+	return NewAuthorizationCode(code, values["client_id"], values["uuid"], values["redirect_uri"], values["scope"], values["code_challenge"]), nil
+}
+
+// Create stores the token and points its grant at it, a replay of the code revokes the token the grant points at
+func (r RefreshToken) Create(ctx context.Context, client *redis.Client) (*RefreshToken, error) {
+	ttl := time.Until(r.ExpiresAt)
+	if _, err := client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, getRefreshTokenKey(r.Token), map[string]interface{}{
+			"client_id": r.ClientID,
+			"uuid":      r.UUID,
+			"grant_id":  r.GrantID,
+			"scope":     r.Scope,
+			"version":   r.Version,
+		})
+		pipe.Expire(ctx, getRefreshTokenKey(r.Token), ttl)
+		pipe.Set(ctx, getGrantKey(r.GrantID), hashToken(r.Token), maxDuration(ttl, GetAccessTTL()))
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+func GetRefreshToken(ctx context.Context, client *redis.Client, token string) (*RefreshToken, error) {
+	var (
+		values *redis.StringStringMapCmd
+		ttl    *redis.DurationCmd
+	)
+	if _, err := client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		values = pipe.HGetAll(ctx, getRefreshTokenKey(token))
+		ttl = pipe.TTL(ctx, getRefreshTokenKey(token))
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if len(values.Val()) == 0 {
+		return nil, nil
+	}
+
+	return toRefreshToken(token, values.Val(), time.Now().Add(ttl.Val())), nil
+}
+
+// ConsumeRefreshToken returns the token and deletes it, the client gets a new one with the new access token
+func ConsumeRefreshToken(ctx context.Context, client *redis.Client, token string) (*RefreshToken, error) {
+	values, err := consumeHash(ctx, client, getRefreshTokenKey(token))
+	if err != nil || values == nil {
+		return nil, err
+	}
+
+	return toRefreshToken(token, values, time.Time{}), nil
+}
+
+func toRefreshToken(token string, values map[string]string, expiresAt time.Time) *RefreshToken {
+	version, _ := strconv.ParseInt(values["version"], 10, 64)
+	return NewRefreshToken(token, values["client_id"], values["uuid"], values["grant_id"], values["scope"], version, expiresAt)
+}
+
+func (r RefreshToken) Delete(ctx context.Context, client *redis.Client) error {
+	return client.Del(ctx, getRefreshTokenKey(r.Token)).Err()
+}
+
+// CreateGrant records the tokens of a code exchanged without a refresh token, a replay of the code still finds
+// the grant and denies its access tokens
+func CreateGrant(ctx context.Context, client *redis.Client, grantID string) error {
+	return client.Set(ctx, getGrantKey(grantID), "", GetAccessTTL()).Err()
+}
+
+// IsGrantActive tells the grant was not revoked by a replay of its code
+func IsGrantActive(ctx context.Context, client *redis.Client, grantID string) (bool, error) {
+	exists, err := client.Exists(ctx, getGrantKey(grantID)).Result()
+	if err != nil {
+		return false, err
+	}
+	return exists > 0, nil
+}
+
+// RevokeGrant deletes the grant and the refresh token it points at, false when there is no such grant
+func RevokeGrant(ctx context.Context, client *redis.Client, grantID string) (bool, error) {
+	var (
+		tokenHash *redis.StringCmd
+		deleted   *redis.IntCmd
+	)
+	if _, err := client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		tokenHash = pipe.Get(ctx, getGrantKey(grantID))
+		deleted = pipe.Del(ctx, getGrantKey(grantID))
+		return nil
+	}); err != nil && err != redis.Nil {
+		return false, err
+	}
+
+	if deleted.Val() == 0 {
+		return false, nil
+	}
+	if tokenHash.Val() != "" {
+		if err := client.Del(ctx, getRefreshTokenKeyByHash(tokenHash.Val())).Err(); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func maxDuration(a time.Duration, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func setHash(ctx context.Context, client *redis.Client, key string, values map[string]interface{}, ttl time.Duration) error {
+	_, err := client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, values)
+		pipe.Expire(ctx, key, ttl)
+		return nil
+	})
+	return err
+}
+
+// consumeHash reads and deletes the hash in one transaction, nil when it does not exist or was consumed already
+func consumeHash(ctx context.Context, client *redis.Client, key string) (map[string]string, error) {
+	var (
+		values  *redis.StringStringMapCmd
+		deleted *redis.IntCmd
+	)
+	if _, err := client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		values = pipe.HGetAll(ctx, key)
+		deleted = pipe.Del(ctx, key)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if deleted.Val() == 0 {
+		return nil, nil
+	}
+
+	return values.Val(), nil
+}
@@ -0,0 +1,73 @@
+package oauth
+
+import "time"
+
+const refreshTokenBytes = 32
+
+// RefreshToken is opaque to the client, it is only stored by its hash and rotated on every use. It keeps the
+// grant it descends from and the token version of the user it was issued under
+type RefreshToken struct {
+	Token     string
+	ClientID  string
+	UUID      string
+	GrantID   string
+	Scope     string
+	Version   int64
+	ExpiresAt time.Time
+}
+
+func NewRefreshToken(token string, clientID string, uuid string, grantID string, scope string, version int64, expiresAt time.Time) *RefreshToken {
+	return &RefreshToken{
+		Token:     token,
+		ClientID:  clientID,
+		UUID:      uuid,
+		GrantID:   grantID,
+		Scope:     scope,
+		Version:   version,
+		ExpiresAt: expiresAt,
+	}
+}
+
+func GenerateRefreshToken(clientID string, uuid string, grantID string, scope string, version int64) (*RefreshToken, error) {
+	token, err := generateRandomToken(refreshTokenBytes)
+	if err != nil {
+		return nil, err
+	}
+	return NewRefreshToken(token, clientID, uuid, grantID, scope, version, time.Now().Add(GetRefreshTTL())), nil
+}
+
+func (r *RefreshToken) IsEmpty() bool {
+	return r == nil
+}
+
+func (r RefreshToken) GetUUID() string {
+	return r.UUID
+}
+
+func (r RefreshToken) GetGrantID() string {
+	return r.GrantID
+}
+
+func (r RefreshToken) GetScope() string {
+	return r.Scope
+}
+
+// IsRevokedBy tells the user revoked every token, changed or reset the password since it was issued
+func (r RefreshToken) IsRevokedBy(version int64) bool {
+	return r.Version < version
+}
+
+func (r RefreshToken) IsIssuedTo(clientID string) bool {
+	return r.ClientID == clientID
+}
+
+func (r RefreshToken) ToIntrospectionResponse() *IntrospectionResponse {
+	return &IntrospectionResponse{
+		Active:    true,
+		Scope:     r.Scope,
+		ClientID:  r.ClientID,
+		Subject:   r.UUID,
+		TokenType: TokenTypeHintRefreshToken,
+		ExpiresAt: r.ExpiresAt.Unix(),
+	}
+}
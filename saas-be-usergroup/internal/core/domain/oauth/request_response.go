@@ -0,0 +1,202 @@
+package oauth
+
+type RegisterClientRequest struct {
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Scopes       []string `json:"scopes"`
+	GrantTypes   []string `json:"grant_types"`
+	Confidential bool     `json:"confidential"`
+}
+
+func NewRegisterClientRequest() *RegisterClientRequest {
+	return &RegisterClientRequest{}
+}
+
+type RegisterClientResponse struct {
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret,omitempty"`
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Scopes       []string `json:"scopes"`
+	GrantTypes   []string `json:"grant_types"`
+	Confidential bool     `json:"confidential"`
+}
+
+// AuthorizationRequest is the query the client sent the user with, the frontend forwards it unchanged
+type AuthorizationRequest struct {
+	ResponseType        string `query:"response_type"`
+	ClientID            string `query:"client_id"`
+	RedirectURI         string `query:"redirect_uri"`
+	Scope               string `query:"scope"`
+	State               string `query:"state"`
+	CodeChallenge       string `query:"code_challenge"`
+	CodeChallengeMethod string `query:"code_challenge_method"`
+}
+
+func NewAuthorizationRequest() *AuthorizationRequest {
+	return &AuthorizationRequest{}
+}
+
+func (a AuthorizationRequest) GetClientID() string {
+	return a.ClientID
+}
+
+func (a AuthorizationRequest) GetRedirectURI() string {
+	return a.RedirectURI
+}
+
+func (a AuthorizationRequest) GetScope() string {
+	return a.Scope
+}
+
+// ToErrorRedirect sends the user back to the client, only once the redirect uri is known to be registered
+func (a AuthorizationRequest) ToErrorRedirect(code string) string {
+	return buildRedirectURI(a.RedirectURI, map[string]string{
+		"error": code,
+		"state": a.State,
+	})
+}
+
+type ClientResponse struct {
+	ClientID string `json:"client_id"`
+	Name     string `json:"name"`
+}
+
+type ScopeResponse struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type ConsentResponse struct {
+	ConsentToken string          `json:"consent_token"`
+	Client       ClientResponse  `json:"client"`
+	Scopes       []ScopeResponse `json:"scopes"`
+	RedirectURI  string          `json:"redirect_uri"`
+}
+
+type DecisionRequest struct {
+	ConsentToken string `json:"consent_token"`
+	Approve      bool   `json:"approve"`
+}
+
+func NewDecisionRequest() *DecisionRequest {
+	return &DecisionRequest{}
+}
+
+func (d DecisionRequest) GetConsentToken() string {
+	return d.ConsentToken
+}
+
+func (d DecisionRequest) IsApproved() bool {
+	return d.Approve
+}
+
+type DecisionResponse struct {
+	RedirectURI string `json:"redirect_uri"`
+}
+
+// ClientCredentials is filled from the Authorization header or from the form, rfc 6749 section 2.3.1
+type ClientCredentials struct {
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+func (c ClientCredentials) GetClientID() string {
+	return c.ClientID
+}
+
+func (c ClientCredentials) GetClientSecret() string {
+	return c.ClientSecret
+}
+
+type TokenRequest struct {
+	ClientCredentials
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	Scope        string `form:"scope"`
+}
+
+func NewTokenRequest() *TokenRequest {
+	return &TokenRequest{}
+}
+
+func (t TokenRequest) GetGrantType() string {
+	return t.GrantType
+}
+
+func (t TokenRequest) GetCode() string {
+	return t.Code
+}
+
+func (t TokenRequest) GetRedirectURI() string {
+	return t.RedirectURI
+}
+
+func (t TokenRequest) GetCodeVerifier() string {
+	return t.CodeVerifier
+}
+
+func (t TokenRequest) GetRefreshToken() string {
+	return t.RefreshToken
+}
+
+func (t TokenRequest) GetScope() string {
+	return t.Scope
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope"`
+}
+
+// TokenIntrospectionRequest serves the introspection and the revocation endpoints, they take the same form
+type TokenIntrospectionRequest struct {
+	ClientCredentials
+	Token         string `form:"token"`
+	TokenTypeHint string `form:"token_type_hint"`
+}
+
+func NewTokenIntrospectionRequest() *TokenIntrospectionRequest {
+	return &TokenIntrospectionRequest{}
+}
+
+func (t TokenIntrospectionRequest) GetToken() string {
+	return t.Token
+}
+
+func (t TokenIntrospectionRequest) IsRefreshTokenHint() bool {
+	return t.TokenTypeHint == TokenTypeHintRefreshToken
+}
+
+// IntrospectionResponse is rfc 7662 section 2.2, an inactive token answers with active alone
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	Issuer    string `json:"iss,omitempty"`
+	Audience  string `json:"aud,omitempty"`
+	JTI       string `json:"jti,omitempty"`
+}
+
+func NewInactiveIntrospectionResponse() *IntrospectionResponse {
+	return &IntrospectionResponse{Active: false}
+}
+
+type UserInfoResponse struct {
+	Subject           string `json:"sub"`
+	Name              string `json:"name,omitempty"`
+	GivenName         string `json:"given_name,omitempty"`
+	FamilyName        string `json:"family_name,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Email             string `json:"email,omitempty"`
+}
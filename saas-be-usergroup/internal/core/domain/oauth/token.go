@@ -0,0 +1,50 @@
+package oauth
+
+import (
+	"github.com/saas-be-usergroup/internal/core/domain/auth"
+	"github.com/saas-be-usergroup/internal/core/domain/user"
+)
+
+func ToTokenResponse(JWT auth.JWT, refreshToken *RefreshToken, scope string) *TokenResponse {
+	res := &TokenResponse{
+		AccessToken: JWT.AccessToken,
+		TokenType:   TokenTypeBearer,
+		ExpiresIn:   JWT.ExpiresIn,
+		Scope:       scope,
+	}
+	if !refreshToken.IsEmpty() {
+		res.RefreshToken = refreshToken.Token
+	}
+	return res
+}
+
+func ToIntrospectionResponse(claims auth.JWTClaims) *IntrospectionResponse {
+	return &IntrospectionResponse{
+		Active:    true,
+		Scope:     claims.GetScope(),
+		ClientID:  claims.GetClientID(),
+		Subject:   claims.Subject,
+		TokenType: TokenTypeHintAccessToken,
+		ExpiresAt: claims.ExpiresAt,
+		IssuedAt:  claims.IssuedAt,
+		Issuer:    claims.Issuer,
+		Audience:  claims.Audience,
+		JTI:       claims.GetJTI(),
+	}
+}
+
+// ToUserInfoResponse only releases the claims the token was granted a scope for
+func ToUserInfoResponse(u user.User, scope string) *UserInfoResponse {
+	scopes := splitList(scope)
+	res := &UserInfoResponse{Subject: u.GetUUID()}
+	if contains(scopes, ScopeProfile) {
+		res.Name = u.GetName()
+		res.GivenName = u.FirstName
+		res.FamilyName = u.LastName
+		res.PreferredUsername = u.UserName
+	}
+	if contains(scopes, ScopeEmail) {
+		res.Email = u.GetEmail()
+	}
+	return res
+}
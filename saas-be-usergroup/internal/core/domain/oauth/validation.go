@@ -0,0 +1,74 @@
+package oauth
+
+import (
+	"errors"
+	"net/url"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+var (
+	ErrUnsupportedScope         = errors.New("unsupported scope")
+	ErrUnsupportedGrantType     = errors.New("unsupported grant type")
+	ErrInvalidRedirectURI       = errors.New("must be an absolute https uri without fragment, http is only allowed for localhost")
+	ErrRedirectURIRequired      = errors.New("is required for the authorization_code grant")
+	ErrConfidentialOnlyGrant    = errors.New("client_credentials is only allowed for a confidential client")
+	ErrRefreshTokenWithoutGrant = errors.New("refresh_token needs the authorization_code grant")
+)
+
+func (r RegisterClientRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Name, validation.Required, validation.Length(1, 100)),
+		validation.Field(&r.GrantTypes, validation.Required, validation.Each(validation.By(validateGrantType)), validation.By(r.validateGrantCombination)),
+		validation.Field(&r.Scopes, validation.Required, validation.Each(validation.By(validateScope))),
+		validation.Field(&r.RedirectURIs, validation.When(contains(r.GrantTypes, GrantAuthorizationCode), validation.Required.ErrorObject(validation.NewError("validation_redirect_uri_required", ErrRedirectURIRequired.Error()))), validation.Each(validation.By(validateRedirectURI))),
+	)
+}
+
+func (r RegisterClientRequest) validateGrantCombination(value interface{}) error {
+	if contains(r.GrantTypes, GrantClientCredentials) && !r.Confidential {
+		return ErrConfidentialOnlyGrant
+	}
+	if contains(r.GrantTypes, GrantRefreshToken) && !contains(r.GrantTypes, GrantAuthorizationCode) {
+		return ErrRefreshTokenWithoutGrant
+	}
+	return nil
+}
+
+func validateGrantType(value interface{}) error {
+	if grantType, _ := value.(string); !isSupportedGrantType(grantType) {
+		return ErrUnsupportedGrantType
+	}
+	return nil
+}
+
+func validateScope(value interface{}) error {
+	if scope, _ := value.(string); !isSupportedScope(scope) {
+		return ErrUnsupportedScope
+	}
+	return nil
+}
+
+func validateRedirectURI(value interface{}) error {
+	redirectURI, _ := value.(string)
+	u, err := url.Parse(redirectURI)
+	if err != nil || !u.IsAbs() || u.Host == "" || u.Fragment != "" {
+		return ErrInvalidRedirectURI
+	}
+
+	switch u.Scheme {
+	case "https":
+		return nil
+	case "http":
+		if host := u.Hostname(); host == "localhost" || host == "127.0.0.1" {
+			return nil
+		}
+	}
+	return ErrInvalidRedirectURI
+}
+
+func (d DecisionRequest) Validate() error {
+	return validation.ValidateStruct(&d,
+		validation.Field(&d.ConsentToken, validation.Required),
+	)
+}
@@ -0,0 +1,219 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrProviderUnavailable = errors.New("identity provider is unavailable")
+	ErrIssuerMismatch      = errors.New("discovered issuer does not match the configured one")
+)
+
+const (
+	metadataCacheTTL  = time.Hour
+	maxResponseLength = 1 << 20
+)
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type metadataEntry struct {
+	metadata  metadata
+	expiresAt time.Time
+}
+
+type keySetEntry struct {
+	keys      map[string]crypto.PublicKey
+	expiresAt time.Time
+}
+
+// metadata and signing keys of the providers are kept for metadataCacheTTL,
+// an id token signed with an unknown kid fetches the keys again for rotation
+var (
+	cacheMu       sync.Mutex
+	metadataCache = make(map[string]metadataEntry)
+	keySetCache   = make(map[string]keySetEntry)
+)
+
+// resolve fills the endpoints that are not configured from the discovery document of the issuer
+func (p *Provider) resolve(ctx context.Context) error {
+	if !p.IsOIDC() || (p.AuthorizationEndpoint != "" && p.TokenEndpoint != "" && p.JWKSURI != "") {
+		return nil
+	}
+
+	discovered, err := p.getMetadata(ctx)
+	if err != nil {
+		return err
+	}
+
+	if p.AuthorizationEndpoint == "" {
+		p.AuthorizationEndpoint = discovered.AuthorizationEndpoint
+	}
+	if p.TokenEndpoint == "" {
+		p.TokenEndpoint = discovered.TokenEndpoint
+	}
+	if p.UserinfoEndpoint == "" {
+		p.UserinfoEndpoint = discovered.UserinfoEndpoint
+	}
+	if p.JWKSURI == "" {
+		p.JWKSURI = discovered.JWKSURI
+	}
+
+	if p.AuthorizationEndpoint == "" || p.TokenEndpoint == "" || p.JWKSURI == "" {
+		return ErrProviderConfig
+	}
+	return nil
+}
+
+func (p Provider) getMetadata(ctx context.Context) (*metadata, error) {
+	issuer := p.Issuer
+	cacheMu.Lock()
+	entry, ok := metadataCache[issuer]
+	cacheMu.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return &entry.metadata, nil
+	}
+
+	var discovered metadata
+	if err := p.getJSON(ctx, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration", "", &discovered); err != nil {
+		return nil, err
+	}
+	if discovered.Issuer != issuer {
+		return nil, ErrIssuerMismatch
+	}
+
+	cacheMu.Lock()
+	metadataCache[issuer] = metadataEntry{metadata: discovered, expiresAt: time.Now().Add(metadataCacheTTL)}
+	cacheMu.Unlock()
+	return &discovered, nil
+}
+
+type jsonWebKey struct {
+	KID string `json:"kid"`
+	KTY string `json:"kty"`
+	Use string `json:"use"`
+	CRV string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (p Provider) getSigningKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	jwksURI := p.JWKSURI
+	cacheMu.Lock()
+	entry, ok := keySetCache[jwksURI]
+	cacheMu.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		if key, found := entry.keys[kid]; found {
+			return key, nil
+		}
+	}
+
+	var keySet struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.getJSON(ctx, jwksURI, "", &keySet); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(keySet.Keys))
+	for _, jwk := range keySet.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		// keys of an unsupported type are skipped, the provider may publish more than we verify
+		if key, err := jwk.toPublicKey(); err == nil {
+			keys[jwk.KID] = key
+		}
+	}
+
+	cacheMu.Lock()
+	keySetCache[jwksURI] = keySetEntry{keys: keys, expiresAt: time.Now().Add(metadataCacheTTL)}
+	cacheMu.Unlock()
+
+	key, found := keys[kid]
+	if !found {
+		return nil, ErrInvalidIDToken
+	}
+	return key, nil
+}
+
+func (j jsonWebKey) toPublicKey() (crypto.PublicKey, error) {
+	switch j.KTY {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(j.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid rsa exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if j.CRV != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", j.CRV)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("point is not on the curve")
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", j.KTY)
+}
+
+// getJSON calls the provider, any failure to reach it or a non 2xx answer is ErrProviderUnavailable
+func (p Provider) getJSON(ctx context.Context, url string, accessToken string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("%w: %s answered %d", ErrProviderUnavailable, url, res.StatusCode)
+	}
+
+	if err = json.NewDecoder(io.LimitReader(res.Body, maxResponseLength)).Decode(out); err != nil {
+		return fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+	}
+	return nil
+}
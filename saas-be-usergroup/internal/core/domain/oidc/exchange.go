@@ -0,0 +1,194 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidAuthorizationCode = errors.New("invalid authorization code")
+	ErrSubjectMismatch          = errors.New("userinfo subject does not match the id token")
+)
+
+// ExternalIdentity is the user as the provider describes it
+type ExternalIdentity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	FirstName     string
+	LastName      string
+}
+
+func (e ExternalIdentity) IsEmailVerified() bool {
+	return e.EmailVerified && e.Email != ""
+}
+
+func (e ExternalIdentity) GetEmail() string {
+	return strings.ToLower(strings.TrimSpace(e.Email))
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+	Error       string `json:"error"`
+}
+
+// Prepare resolves the endpoints of the provider before the login is started
+func (p *Provider) Prepare(ctx context.Context) error {
+	return p.resolve(ctx)
+}
+
+// Exchange trades the code for the tokens with the pkce verifier and returns the identity they describe
+func (p *Provider) Exchange(ctx context.Context, code string, state LoginState) (*ExternalIdentity, error) {
+	if err := p.resolve(ctx); err != nil {
+		return nil, err
+	}
+
+	tokens, err := p.exchangeCode(ctx, code, state.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.IsOIDC() {
+		return p.getOIDCIdentity(ctx, tokens, state.Nonce)
+	}
+	return p.getGitHubIdentity(ctx, tokens.AccessToken)
+}
+
+func (p Provider) exchangeCode(ctx context.Context, code string, codeVerifier string) (*tokenResponse, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("client_id", p.ClientID)
+	form.Set("client_secret", p.ClientSecret)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+	}
+	defer res.Body.Close()
+
+	// a used, expired or forged code is a 400 of the token endpoint
+	if res.StatusCode >= 400 && res.StatusCode < 500 {
+		return nil, ErrInvalidAuthorizationCode
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, fmt.Errorf("%w: token endpoint answered %d", ErrProviderUnavailable, res.StatusCode)
+	}
+
+	var tokens tokenResponse
+	if err = json.NewDecoder(io.LimitReader(res.Body, maxResponseLength)).Decode(&tokens); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+	}
+
+	// github answers 200 with an error field
+	if tokens.Error != "" {
+		return nil, ErrInvalidAuthorizationCode
+	}
+	if tokens.AccessToken == "" || (p.IsOIDC() && tokens.IDToken == "") {
+		return nil, ErrInvalidAuthorizationCode
+	}
+
+	return &tokens, nil
+}
+
+func (p Provider) getOIDCIdentity(ctx context.Context, tokens *tokenResponse, nonce string) (*ExternalIdentity, error) {
+	claims, err := p.verifyIDToken(ctx, tokens.IDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	identity := &ExternalIdentity{
+		Provider:      p.Name,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		FirstName:     claims.GivenName,
+		LastName:      claims.FamilyName,
+	}
+
+	// providers may keep the email out of the id token and only serve it on userinfo
+	if identity.Email != "" || p.UserinfoEndpoint == "" {
+		return identity, nil
+	}
+
+	var userinfo IDTokenClaims
+	if err = p.getJSON(ctx, p.UserinfoEndpoint, tokens.AccessToken, &userinfo); err != nil {
+		return nil, err
+	}
+	if userinfo.Subject != claims.Subject {
+		return nil, ErrSubjectMismatch
+	}
+
+	identity.Email = userinfo.Email
+	identity.EmailVerified = bool(userinfo.EmailVerified)
+	if identity.FirstName == "" && identity.LastName == "" {
+		identity.FirstName, identity.LastName = userinfo.GivenName, userinfo.FamilyName
+	}
+	return identity, nil
+}
+
+// getGitHubIdentity reads the account and its primary email, github tells whether the email is verified
+// only on the emails endpoint
+func (p Provider) getGitHubIdentity(ctx context.Context, accessToken string) (*ExternalIdentity, error) {
+	var account struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := p.getJSON(ctx, p.APIURL+"/user", accessToken, &account); err != nil {
+		return nil, err
+	}
+	if account.ID == 0 {
+		return nil, ErrProviderUnavailable
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.getJSON(ctx, p.APIURL+"/user/emails", accessToken, &emails); err != nil {
+		return nil, err
+	}
+
+	identity := &ExternalIdentity{
+		Provider: p.Name,
+		Subject:  strconv.FormatInt(account.ID, 10),
+	}
+	for _, email := range emails {
+		if email.Primary {
+			identity.Email = email.Email
+			identity.EmailVerified = email.Verified
+		}
+	}
+
+	// github has a single display name, the first word goes to the first name
+	name := strings.TrimSpace(account.Name)
+	if name == "" {
+		name = account.Login
+	}
+	identity.FirstName = name
+	if i := strings.Index(name, " "); i > 0 {
+		identity.FirstName, identity.LastName = name[:i], strings.TrimSpace(name[i+1:])
+	}
+
+	return identity, nil
+}
@@ -0,0 +1,484 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	testClientID    = "partner-app"
+	testRedirectURL = "http://localhost:3000/auth/callback/stand-in"
+	testKeyID       = "stand-in-key"
+)
+
+// authorization is what the stand-in remembers of an authorization request until its code is exchanged
+type authorization struct {
+	codeChallenge string
+	nonce         string
+}
+
+// standInProvider serves discovery, jwks, the token endpoint and userinfo the way an oidc provider does
+type standInProvider struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu             sync.Mutex
+	codes          map[string]authorization
+	issuer         string
+	discoveryFails bool
+	jwksCalls      int
+	// signWith signs the id tokens with a key that is not published when set
+	signWith *rsa.PrivateKey
+	// mutate changes the claims and the header of the next id token before it is signed
+	mutate func(claims jwt.MapClaims, token *jwt.Token)
+}
+
+func newStandInProvider(t *testing.T) *standInProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate the signing key: %v", err)
+	}
+
+	s := &standInProvider{t: t, key: key, codes: make(map[string]authorization)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/jwks", s.jwks)
+	mux.HandleFunc("/token", s.token)
+	mux.HandleFunc("/userinfo", s.userinfo)
+	s.server = httptest.NewServer(mux)
+	s.issuer = s.server.URL
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func (s *standInProvider) provider(t *testing.T) *Provider {
+	t.Helper()
+	p, err := NewProvider("stand-in", ProviderConfig{
+		Type:         ProviderTypeOIDC,
+		Issuer:       s.server.URL,
+		ClientID:     testClientID,
+		ClientSecret: "secret",
+		RedirectURL:  testRedirectURL,
+	}, s.server.Client())
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	return p
+}
+
+func (s *standInProvider) discovery(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.discoveryFails {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, metadata{
+		Issuer:                s.issuer,
+		AuthorizationEndpoint: s.server.URL + "/authorize",
+		TokenEndpoint:         s.server.URL + "/token",
+		UserinfoEndpoint:      s.server.URL + "/userinfo",
+		JWKSURI:               s.server.URL + "/jwks",
+	})
+}
+
+func (s *standInProvider) jwks(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	s.jwksCalls++
+	s.mu.Unlock()
+
+	writeJSON(w, map[string][]jsonWebKey{"keys": {
+		{KID: "encryption-key", KTY: "RSA", Use: "enc", N: "AQAB", E: "AQAB"},
+		{
+			KID: testKeyID,
+			KTY: "RSA",
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(s.key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.key.E)).Bytes()),
+		},
+	}})
+}
+
+// authorize plays the consent of the user and returns the code the provider redirects back with
+func (s *standInProvider) authorize(t *testing.T, authorizationURL string) string {
+	t.Helper()
+	u, err := url.Parse(authorizationURL)
+	if err != nil {
+		t.Fatalf("invalid authorization url %q: %v", authorizationURL, err)
+	}
+	query := u.Query()
+	if query.Get("client_id") != testClientID || query.Get("redirect_uri") != testRedirectURL {
+		t.Fatalf("authorization url %q does not carry the client", authorizationURL)
+	}
+
+	code := "code-" + query.Get("state")[:8]
+	s.mu.Lock()
+	s.codes[code] = authorization{codeChallenge: query.Get("code_challenge"), nonce: query.Get("nonce")}
+	s.mu.Unlock()
+	return code
+}
+
+func (s *standInProvider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	granted, ok := s.codes[r.PostForm.Get("code")]
+	delete(s.codes, r.PostForm.Get("code"))
+	mutate, signWith := s.mutate, s.signWith
+	s.mu.Unlock()
+
+	// the verifier must hash to the challenge of the authorization request
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != granted.codeChallenge ||
+		r.PostForm.Get("client_id") != testClientID || r.PostForm.Get("redirect_uri") != testRedirectURL {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            s.issuer,
+		"sub":            "subject-1",
+		"aud":            testClientID,
+		"exp":            now.Add(time.Minute * 5).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          granted.nonce,
+		"email":          "alice@example.com",
+		"email_verified": true,
+		"given_name":     "Alice",
+		"family_name":    "Liddell",
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = testKeyID
+	if mutate != nil {
+		mutate(claims, token)
+	}
+
+	var key interface{} = s.key
+	if signWith != nil {
+		key = signWith
+	}
+	if token.Method == jwt.SigningMethodHS256 {
+		key = []byte("secret")
+	}
+	idToken, err := token.SignedString(key)
+	if err != nil {
+		s.t.Errorf("failed to sign the id token: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]string{"access_token": "access-token", "id_token": idToken})
+}
+
+func (s *standInProvider) userinfo(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer access-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"sub": "subject-1", "email": "userinfo@example.com", "email_verified": "true"})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// login runs a login against the stand-in from the begin url to the exchange of the code
+func login(t *testing.T, s *standInProvider) (*ExternalIdentity, error) {
+	t.Helper()
+	p := s.provider(t)
+	if err := p.Prepare(context.Background()); err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+
+	state, err := GenerateLoginState(p.GetName())
+	if err != nil {
+		t.Fatalf("GenerateLoginState() error = %v", err)
+	}
+	code := s.authorize(t, state.ToBeginResponse(*p).AuthorizationURL)
+
+	return p.Exchange(context.Background(), code, *state)
+}
+
+func TestBeginResponseCarriesPKCEChallengeAndNonce(t *testing.T) {
+	s := newStandInProvider(t)
+	p := s.provider(t)
+	if err := p.Prepare(context.Background()); err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+
+	state, err := GenerateLoginState(p.GetName())
+	if err != nil {
+		t.Fatalf("GenerateLoginState() error = %v", err)
+	}
+	begin := state.ToBeginResponse(*p)
+
+	if !strings.HasPrefix(begin.AuthorizationURL, s.server.URL+"/authorize?") {
+		t.Fatalf("authorization url %q, want the discovered authorization endpoint", begin.AuthorizationURL)
+	}
+	u, _ := url.Parse(begin.AuthorizationURL)
+	query := u.Query()
+
+	sum := sha256.Sum256([]byte(state.CodeVerifier))
+	if query.Get("code_challenge") != base64.RawURLEncoding.EncodeToString(sum[:]) || query.Get("code_challenge_method") != "S256" {
+		t.Errorf("code_challenge %q with %q, want the S256 of the verifier", query.Get("code_challenge"), query.Get("code_challenge_method"))
+	}
+	if strings.Contains(begin.AuthorizationURL, state.CodeVerifier) {
+		t.Error("authorization url carries the code verifier")
+	}
+	if query.Get("state") != state.State || begin.State != state.State || query.Get("nonce") != state.Nonce {
+		t.Errorf("state %q and nonce %q, want the ones of the login state", query.Get("state"), query.Get("nonce"))
+	}
+	if query.Get("response_type") != "code" || query.Get("scope") != "openid email profile" {
+		t.Errorf("response_type %q and scope %q, want code with the default oidc scopes", query.Get("response_type"), query.Get("scope"))
+	}
+}
+
+func TestGenerateLoginStateIsUnique(t *testing.T) {
+	first, err := GenerateLoginState("stand-in")
+	if err != nil {
+		t.Fatalf("GenerateLoginState() error = %v", err)
+	}
+	second, err := GenerateLoginState("stand-in")
+	if err != nil {
+		t.Fatalf("GenerateLoginState() error = %v", err)
+	}
+
+	if first.State == second.State || first.CodeVerifier == second.CodeVerifier || first.Nonce == second.Nonce {
+		t.Error("two logins share a state, verifier or nonce")
+	}
+	if first.State == first.CodeVerifier || first.CodeVerifier == first.Nonce {
+		t.Error("a login reuses the same random value")
+	}
+}
+
+func TestExchangeVerifiesIDToken(t *testing.T) {
+	s := newStandInProvider(t)
+
+	identity, err := login(t, s)
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+
+	want := ExternalIdentity{Provider: "stand-in", Subject: "subject-1", Email: "alice@example.com", EmailVerified: true, FirstName: "Alice", LastName: "Liddell"}
+	if *identity != want {
+		t.Errorf("Exchange() = %+v, want %+v", *identity, want)
+	}
+}
+
+func TestExchangeAcceptsAuthorizedPartyOfMultipleAudiences(t *testing.T) {
+	s := newStandInProvider(t)
+	s.mutate = func(claims jwt.MapClaims, _ *jwt.Token) {
+		claims["aud"] = []string{testClientID, "another-app"}
+		claims["azp"] = testClientID
+	}
+
+	if _, err := login(t, s); err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+}
+
+func TestExchangeFallsBackToUserinfo(t *testing.T) {
+	s := newStandInProvider(t)
+	s.mutate = func(claims jwt.MapClaims, _ *jwt.Token) {
+		delete(claims, "email")
+		delete(claims, "email_verified")
+	}
+
+	identity, err := login(t, s)
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	if identity.Email != "userinfo@example.com" || !identity.EmailVerified {
+		t.Errorf("Exchange() email %q verified %v, want the email of userinfo", identity.Email, identity.EmailVerified)
+	}
+}
+
+func TestExchangeRejectsIDToken(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate a key: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		mutate   func(claims jwt.MapClaims, token *jwt.Token)
+		signWith *rsa.PrivateKey
+	}{
+		{name: "other issuer", mutate: func(claims jwt.MapClaims, _ *jwt.Token) { claims["iss"] = "https://attacker.example.com" }},
+		{name: "other audience", mutate: func(claims jwt.MapClaims, _ *jwt.Token) { claims["aud"] = "another-app" }},
+		{name: "multiple audiences without azp", mutate: func(claims jwt.MapClaims, _ *jwt.Token) {
+			claims["aud"] = []string{testClientID, "another-app"}
+		}},
+		{name: "multiple audiences for another azp", mutate: func(claims jwt.MapClaims, _ *jwt.Token) {
+			claims["aud"] = []string{testClientID, "another-app"}
+			claims["azp"] = "another-app"
+		}},
+		{name: "other nonce", mutate: func(claims jwt.MapClaims, _ *jwt.Token) { claims["nonce"] = "replayed-nonce" }},
+		{name: "missing nonce", mutate: func(claims jwt.MapClaims, _ *jwt.Token) { delete(claims, "nonce") }},
+		{name: "missing subject", mutate: func(claims jwt.MapClaims, _ *jwt.Token) { delete(claims, "sub") }},
+		{name: "expired", mutate: func(claims jwt.MapClaims, _ *jwt.Token) { claims["exp"] = time.Now().Add(-time.Hour).Unix() }},
+		{name: "issued in the future", mutate: func(claims jwt.MapClaims, _ *jwt.Token) { claims["iat"] = time.Now().Add(time.Hour).Unix() }},
+		{name: "unknown kid", mutate: func(_ jwt.MapClaims, token *jwt.Token) { token.Header["kid"] = "rotated-away" }},
+		{name: "encryption key kid", mutate: func(_ jwt.MapClaims, token *jwt.Token) { token.Header["kid"] = "encryption-key" }},
+		{name: "hmac with the client secret", mutate: func(_ jwt.MapClaims, token *jwt.Token) {
+			token.Method = jwt.SigningMethodHS256
+			token.Header["alg"] = jwt.SigningMethodHS256.Alg()
+		}},
+		{name: "signed by another key under the published kid", signWith: otherKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStandInProvider(t)
+			s.mutate, s.signWith = tt.mutate, tt.signWith
+
+			if _, err := login(t, s); !errors.Is(err, ErrInvalidIDToken) {
+				t.Errorf("Exchange() error = %v, want ErrInvalidIDToken", err)
+			}
+		})
+	}
+}
+
+func TestExchangeRejectsOtherCodeVerifier(t *testing.T) {
+	s := newStandInProvider(t)
+	p := s.provider(t)
+	if err := p.Prepare(context.Background()); err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+
+	state, _ := GenerateLoginState(p.GetName())
+	code := s.authorize(t, state.ToBeginResponse(*p).AuthorizationURL)
+
+	// the callback of another login can not redeem this code
+	other, _ := GenerateLoginState(p.GetName())
+	if _, err := p.Exchange(context.Background(), code, *other); !errors.Is(err, ErrInvalidAuthorizationCode) {
+		t.Errorf("Exchange() error = %v, want ErrInvalidAuthorizationCode", err)
+	}
+}
+
+func TestExchangeRejectsReplayedCode(t *testing.T) {
+	s := newStandInProvider(t)
+	p := s.provider(t)
+	if err := p.Prepare(context.Background()); err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+
+	state, _ := GenerateLoginState(p.GetName())
+	code := s.authorize(t, state.ToBeginResponse(*p).AuthorizationURL)
+	if _, err := p.Exchange(context.Background(), code, *state); err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	if _, err := p.Exchange(context.Background(), code, *state); !errors.Is(err, ErrInvalidAuthorizationCode) {
+		t.Errorf("second Exchange() error = %v, want ErrInvalidAuthorizationCode", err)
+	}
+}
+
+func TestPrepareRejectsIssuerMismatch(t *testing.T) {
+	s := newStandInProvider(t)
+	s.issuer = "https://attacker.example.com"
+
+	if err := s.provider(t).Prepare(context.Background()); !errors.Is(err, ErrIssuerMismatch) {
+		t.Errorf("Prepare() error = %v, want ErrIssuerMismatch", err)
+	}
+}
+
+func TestPrepareProviderUnavailable(t *testing.T) {
+	s := newStandInProvider(t)
+	s.discoveryFails = true
+
+	if err := s.provider(t).Prepare(context.Background()); !errors.Is(err, ErrProviderUnavailable) {
+		t.Errorf("Prepare() error = %v, want ErrProviderUnavailable", err)
+	}
+}
+
+func TestSigningKeysAreCached(t *testing.T) {
+	s := newStandInProvider(t)
+
+	for i := 0; i < 2; i++ {
+		if _, err := login(t, s); err != nil {
+			t.Fatalf("Exchange() error = %v", err)
+		}
+	}
+	if s.jwksCalls != 1 {
+		t.Errorf("fetched the jwks %d times, want 1", s.jwksCalls)
+	}
+}
+
+func TestNewProviderRequiresAClient(t *testing.T) {
+	config := ProviderConfig{Type: ProviderTypeOIDC, Issuer: "https://accounts.example.com", ClientID: testClientID, RedirectURL: testRedirectURL}
+	if _, err := NewProvider("stand-in", config, nil); !errors.Is(err, ErrProviderConfig) {
+		t.Errorf("NewProvider() error = %v, want ErrProviderConfig", err)
+	}
+}
+
+func TestGitHubExchange(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.PostForm.Get("code") != "github-code" || r.PostForm.Get("code_verifier") == "" {
+			writeJSON(w, map[string]string{"error": "bad_verification_code"})
+			return
+		}
+		writeJSON(w, map[string]string{"access_token": "github-token"})
+	})
+	mux.HandleFunc("/api/user", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, map[string]interface{}{"id": 42, "login": "alice", "name": "Alice Liddell"})
+	})
+	mux.HandleFunc("/api/user/emails", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, []map[string]interface{}{
+			{"email": "old@example.com", "primary": false, "verified": true},
+			{"email": "alice@example.com", "primary": true, "verified": true},
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	p, err := NewProvider("github", ProviderConfig{
+		Type:                  ProviderTypeGitHub,
+		ClientID:              testClientID,
+		RedirectURL:           testRedirectURL,
+		AuthorizationEndpoint: server.URL + "/login/oauth/authorize",
+		TokenEndpoint:         server.URL + "/login/oauth/access_token",
+		APIURL:                server.URL + "/api",
+	}, server.Client())
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	state, _ := GenerateLoginState(p.GetName())
+
+	if _, err = p.Exchange(context.Background(), "used-code", *state); !errors.Is(err, ErrInvalidAuthorizationCode) {
+		t.Errorf("Exchange() of a bad code error = %v, want ErrInvalidAuthorizationCode", err)
+	}
+
+	identity, err := p.Exchange(context.Background(), "github-code", *state)
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	want := ExternalIdentity{Provider: "github", Subject: "42", Email: "alice@example.com", EmailVerified: true, FirstName: "Alice", LastName: "Liddell"}
+	if *identity != want {
+		t.Errorf("Exchange() = %+v, want %+v", *identity, want)
+	}
+}
@@ -0,0 +1,114 @@
+package oidc
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var ErrInvalidIDToken = errors.New("invalid id token")
+
+// idTokenLeeway absorbs the clock difference with the provider
+const idTokenLeeway = time.Minute
+
+// audience is a single string or an array in the id token
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(b, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+	return nil
+}
+
+func (a audience) contains(value string) bool {
+	for _, v := range a {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// assertion is a boolean claim some providers send as the string "true"
+type assertion bool
+
+func (a *assertion) UnmarshalJSON(b []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(b, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case bool:
+		*a = assertion(v)
+	case string:
+		*a = v == "true"
+	}
+	return nil
+}
+
+type IDTokenClaims struct {
+	Issuer          string    `json:"iss"`
+	Subject         string    `json:"sub"`
+	Audience        audience  `json:"aud"`
+	AuthorizedParty string    `json:"azp"`
+	ExpiresAt       int64     `json:"exp"`
+	IssuedAt        int64     `json:"iat"`
+	Nonce           string    `json:"nonce"`
+	Email           string    `json:"email"`
+	EmailVerified   assertion `json:"email_verified"`
+	GivenName       string    `json:"given_name"`
+	FamilyName      string    `json:"family_name"`
+}
+
+func (c IDTokenClaims) Valid() error {
+	now := time.Now()
+	if c.ExpiresAt == 0 || now.After(time.Unix(c.ExpiresAt, 0).Add(idTokenLeeway)) {
+		return ErrInvalidIDToken
+	}
+	if c.IssuedAt != 0 && now.Add(idTokenLeeway).Before(time.Unix(c.IssuedAt, 0)) {
+		return ErrInvalidIDToken
+	}
+	return nil
+}
+
+// verifyIDToken checks the signature with the keys of the provider, then the issuer, the audience and
+// the nonce of the login it was requested for
+func (p Provider) verifyIDToken(ctx context.Context, idToken string, nonce string) (*IDTokenClaims, error) {
+	claims := &IDTokenClaims{}
+	parser := &jwt.Parser{ValidMethods: []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg()}}
+	if _, err := parser.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.getSigningKey(ctx, kid)
+	}); err != nil {
+		var validationErr *jwt.ValidationError
+		if errors.As(err, &validationErr) && errors.Is(validationErr.Inner, ErrProviderUnavailable) {
+			return nil, validationErr.Inner
+		}
+		return nil, ErrInvalidIDToken
+	}
+
+	if claims.Issuer != p.Issuer || claims.Subject == "" || !claims.Audience.contains(p.ClientID) {
+		return nil, ErrInvalidIDToken
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.ClientID {
+		return nil, ErrInvalidIDToken
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, ErrInvalidIDToken
+	}
+
+	return claims, nil
+}
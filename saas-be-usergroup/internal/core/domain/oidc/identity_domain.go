@@ -0,0 +1,79 @@
+package oidc
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/saas-be-usergroup/internal/core/domain/user"
+)
+
+// UserIdentity links an account of a provider to a user, the subject is stable where the email may change
+type UserIdentity struct {
+	ID            uint64
+	UserAccountID uint64
+	Provider      string
+	Subject       string
+	Email         string
+	LastLoginTs   *time.Time
+	InsertTs      time.Time
+}
+
+func NewUserIdentity() *UserIdentity {
+	return &UserIdentity{}
+}
+
+func (u *UserIdentity) IsEmpty() bool {
+	return u == nil
+}
+
+func (u UserIdentity) GetUserAccountID() uint64 {
+	return u.UserAccountID
+}
+
+func (u *UserIdentity) SetLoggedIn(email string) {
+	now := time.Now()
+	u.Email = email
+	u.LastLoginTs = &now
+}
+
+func (e ExternalIdentity) ToUserIdentity() *UserIdentity {
+	now := time.Now()
+	return &UserIdentity{
+		Provider:    e.Provider,
+		Subject:     e.Subject,
+		Email:       e.GetEmail(),
+		LastLoginTs: &now,
+		InsertTs:    now,
+	}
+}
+
+// ToUser creates the account of a first social login, the provider has verified the email already
+func (e ExternalIdentity) ToUser() (*user.User, error) {
+	u := &user.User{
+		UUID:      uuid.New().String(),
+		FirstName: e.FirstName,
+		LastName:  e.LastName,
+		Email:     e.GetEmail(),
+		Status:    user.UserNew,
+		InsertTs:  time.Now(),
+	}
+	if err := u.Verify(); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// ToVerifiedUser finishes a pending email registration of the same address, the names are kept when already set.
+// The password of the sign-up is cleared, whoever chose it has not proven the email. An account in any other status
+// is refused by the lifecycle
+func (e ExternalIdentity) ToVerifiedUser(u *user.User) (*user.User, error) {
+	if err := u.Verify(); err != nil {
+		return nil, err
+	}
+	u.SetPassword("")
+	if u.FirstName == "" && u.LastName == "" {
+		u.SetFirstName(e.FirstName)
+		u.SetLastName(e.LastName)
+	}
+	return u, nil
+}
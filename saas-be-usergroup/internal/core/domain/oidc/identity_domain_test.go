@@ -0,0 +1,65 @@
+package oidc
+
+import (
+	"testing"
+
+	"github.com/saas-be-usergroup/internal/core/domain/user"
+)
+
+func TestToVerifiedUserClearsTheSignUpPassword(t *testing.T) {
+	identity := ExternalIdentity{Provider: "stand-in", Subject: "subject", FirstName: "Alice", LastName: "Smith"}
+
+	for _, status := range []user.UserStatus{user.UserNew, user.UserConfirmed} {
+		pending := &user.User{Email: "alice@example.com", Password: "hashed", Status: status}
+
+		verified, err := identity.ToVerifiedUser(pending)
+		if err != nil {
+			t.Fatalf("ToVerifiedUser(%s) error = %v", status, err)
+		}
+		if !verified.IsVerified() {
+			t.Errorf("ToVerifiedUser(%s) status = %s, want %s", status, verified.GetStatus(), user.UserVerified)
+		}
+		if verified.GetPassword() != "" {
+			t.Errorf("ToVerifiedUser(%s) kept the password of the sign-up", status)
+		}
+		if verified.FirstName != "Alice" || verified.LastName != "Smith" {
+			t.Errorf("ToVerifiedUser(%s) name = %s %s, want the name of the provider", status, verified.FirstName, verified.LastName)
+		}
+	}
+}
+
+func TestToVerifiedUserRefusesAnAccountInUse(t *testing.T) {
+	identity := ExternalIdentity{Provider: "stand-in", Subject: "subject"}
+
+	for _, status := range []user.UserStatus{user.UserVerified, user.UserSuspended, user.UserDeleted} {
+		account := &user.User{Email: "alice@example.com", Password: "hashed", Status: status}
+
+		if _, err := identity.ToVerifiedUser(account); err == nil {
+			t.Errorf("ToVerifiedUser(%s) error = nil, want the lifecycle to refuse it", status)
+		}
+		if account.GetPassword() != "hashed" {
+			t.Errorf("ToVerifiedUser(%s) cleared the password of an account in use", status)
+		}
+	}
+}
+
+func TestLinkStateIsOnlyFinishedByItsUser(t *testing.T) {
+	login, err := GenerateLoginState("stand-in")
+	if err != nil {
+		t.Fatalf("GenerateLoginState() error = %v", err)
+	}
+	if login.IsLink() || login.IsLinkOf("") {
+		t.Error("a login state is taken for a link")
+	}
+
+	link, err := GenerateLinkState("stand-in", "user-uuid")
+	if err != nil {
+		t.Fatalf("GenerateLinkState() error = %v", err)
+	}
+	if !link.IsLink() || !link.IsLinkOf("user-uuid") {
+		t.Error("a link state is not taken for the link of its user")
+	}
+	if link.IsLinkOf("other-uuid") {
+		t.Error("a link state is taken for the link of another user")
+	}
+}
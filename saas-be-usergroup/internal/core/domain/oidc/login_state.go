@@ -0,0 +1,99 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strings"
+)
+
+const randomValueBytes = 32
+
+// LoginState ties the callback to the login that was started, the code verifier never leaves the server.
+// UserUUID is only set when a logged in user links the provider to the account
+type LoginState struct {
+	State        string
+	Provider     string
+	CodeVerifier string
+	Nonce        string
+	UserUUID     string
+}
+
+func NewLoginState(state string, provider string, codeVerifier string, nonce string) *LoginState {
+	return &LoginState{
+		State:        state,
+		Provider:     provider,
+		CodeVerifier: codeVerifier,
+		Nonce:        nonce,
+	}
+}
+
+func GenerateLoginState(provider string) (*LoginState, error) {
+	values := make([]string, 3)
+	for i := range values {
+		b := make([]byte, randomValueBytes)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		values[i] = base64.RawURLEncoding.EncodeToString(b)
+	}
+	return NewLoginState(values[0], provider, values[1], values[2]), nil
+}
+
+// GenerateLinkState starts a login that links the identity to the account of userUUID instead of logging in,
+// an empty userUUID is a plain login
+func GenerateLinkState(provider string, userUUID string) (*LoginState, error) {
+	state, err := GenerateLoginState(provider)
+	if err != nil {
+		return nil, err
+	}
+	state.UserUUID = userUUID
+	return state, nil
+}
+
+func (l *LoginState) IsEmpty() bool {
+	return l == nil
+}
+
+func (l LoginState) GetProvider() string {
+	return l.Provider
+}
+
+func (l LoginState) IsLink() bool {
+	return l.UserUUID != ""
+}
+
+// IsLinkOf tells the link was started by the user calling back, a login state is never one
+func (l LoginState) IsLinkOf(userUUID string) bool {
+	return l.IsLink() && l.UserUUID == userUUID
+}
+
+func (l LoginState) getCodeChallenge() string {
+	sum := sha256.Sum256([]byte(l.CodeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (l LoginState) ToBeginResponse(p Provider) *BeginResponse {
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.ClientID)
+	query.Set("redirect_uri", p.RedirectURL)
+	query.Set("scope", strings.Join(p.Scopes, " "))
+	query.Set("state", l.State)
+	query.Set("code_challenge", l.getCodeChallenge())
+	query.Set("code_challenge_method", "S256")
+	if p.IsOIDC() {
+		query.Set("nonce", l.Nonce)
+	}
+
+	separator := "?"
+	if strings.Contains(p.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return &BeginResponse{
+		AuthorizationURL: p.AuthorizationEndpoint + separator + query.Encode(),
+		State:            l.State,
+	}
+}
@@ -0,0 +1,43 @@
+package oidc
+
+import (
+	"errors"
+
+	"github.com/saas-be-usergroup/internal/core/domain/user"
+	"gorm.io/gorm"
+)
+
+func (u *UserIdentity) GetOneByProviderSubject(db *gorm.DB, provider string, subject string) (*UserIdentity, error) {
+	if err := db.Where("provider = ? AND subject = ?", provider, subject).First(&u).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return u, nil
+}
+
+func (u *UserIdentity) Update(db *gorm.DB) (*UserIdentity, error) {
+	if err := db.Save(&u).Error; err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// Link saves the user and the identity pointing at it together, a new user gets its id on the way
+func (u *UserIdentity) Link(db *gorm.DB, userAccount *user.User) (*UserIdentity, error) {
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(userAccount).Error; err != nil {
+			return err
+		}
+		u.UserAccountID = userAccount.GetID()
+		return tx.Create(&u).Error
+	}); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
@@ -0,0 +1,131 @@
+package oidc
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	ProviderTypeOIDC   = "oidc"
+	ProviderTypeGitHub = "github"
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown identity provider")
+	ErrProviderConfig  = errors.New("identity provider is misconfigured")
+)
+
+const (
+	githubAuthorizationEndpoint = "https://github.com/login/oauth/authorize"
+	githubTokenEndpoint         = "https://github.com/login/oauth/access_token"
+	githubAPIURL                = "https://api.github.com"
+)
+
+var (
+	defaultStateTTL     = time.Minute * 10
+	defaultHTTPTimeout  = time.Second * 10
+	defaultOIDCScopes   = []string{"openid", "email", "profile"}
+	defaultGitHubScopes = []string{"read:user", "user:email"}
+)
+
+func GetStateTTL() time.Duration {
+	if ttl := viper.GetDuration("oidc.state_ttl"); ttl > 0 {
+		return ttl
+	}
+	return defaultStateTTL
+}
+
+// NewHTTPClient returns the client the providers are called with, oidc.http_timeout bounds every call
+func NewHTTPClient() *http.Client {
+	timeout := viper.GetDuration("oidc.http_timeout")
+	if timeout <= 0 {
+		timeout = defaultHTTPTimeout
+	}
+	return &http.Client{Timeout: timeout}
+}
+
+// ProviderConfig is one entry of oidc.providers. An oidc provider only needs its issuer, the endpoints are
+// discovered, set them explicitly for a provider without discovery. github is plain oauth2 and has no issuer
+type ProviderConfig struct {
+	Type                  string   `mapstructure:"type"`
+	Issuer                string   `mapstructure:"issuer"`
+	ClientID              string   `mapstructure:"client_id"`
+	ClientSecret          string   `mapstructure:"client_secret"`
+	RedirectURL           string   `mapstructure:"redirect_url"`
+	Scopes                []string `mapstructure:"scopes"`
+	AuthorizationEndpoint string   `mapstructure:"authorization_endpoint"`
+	TokenEndpoint         string   `mapstructure:"token_endpoint"`
+	UserinfoEndpoint      string   `mapstructure:"userinfo_endpoint"`
+	JWKSURI               string   `mapstructure:"jwks_uri"`
+	APIURL                string   `mapstructure:"api_url"`
+}
+
+type Provider struct {
+	Name string
+	ProviderConfig
+	client *http.Client
+}
+
+// GetProvider reads oidc.providers.<name>, a provider without client_id is disabled
+func GetProvider(name string, client *http.Client) (*Provider, error) {
+	key := "oidc.providers." + strings.ToLower(name)
+	if !viper.IsSet(key) {
+		return nil, ErrUnknownProvider
+	}
+
+	var config ProviderConfig
+	if err := viper.UnmarshalKey(key, &config); err != nil {
+		return nil, err
+	}
+	if config.ClientID == "" {
+		return nil, ErrUnknownProvider
+	}
+
+	return NewProvider(name, config, client)
+}
+
+// NewProvider checks the config and fills the defaults of its type, the provider is called through client
+func NewProvider(name string, config ProviderConfig, client *http.Client) (*Provider, error) {
+	if config.ClientID == "" || config.RedirectURL == "" || client == nil {
+		return nil, ErrProviderConfig
+	}
+
+	switch config.Type {
+	case ProviderTypeOIDC:
+		if config.Issuer == "" {
+			return nil, ErrProviderConfig
+		}
+		if len(config.Scopes) == 0 {
+			config.Scopes = defaultOIDCScopes
+		}
+	case ProviderTypeGitHub:
+		if config.AuthorizationEndpoint == "" {
+			config.AuthorizationEndpoint = githubAuthorizationEndpoint
+		}
+		if config.TokenEndpoint == "" {
+			config.TokenEndpoint = githubTokenEndpoint
+		}
+		if config.APIURL == "" {
+			config.APIURL = githubAPIURL
+		}
+		if len(config.Scopes) == 0 {
+			config.Scopes = defaultGitHubScopes
+		}
+	default:
+		return nil, ErrProviderConfig
+	}
+
+	return &Provider{Name: strings.ToLower(name), ProviderConfig: config, client: client}, nil
+}
+
+func (p Provider) GetName() string {
+	return p.Name
+}
+
+func (p Provider) IsOIDC() bool {
+	return p.Type == ProviderTypeOIDC
+}
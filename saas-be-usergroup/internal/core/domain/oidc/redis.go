@@ -0,0 +1,51 @@
+package oidc
+
+import (
+	"context"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func getLoginStateKey(state string) string {
+	return "oidc-state-" + state
+}
+
+func (l LoginState) Create(ctx context.Context, client *redis.Client) (*LoginState, error) {
+	if _, err := client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, getLoginStateKey(l.State), map[string]interface{}{
+			"provider":      l.Provider,
+			"code_verifier": l.CodeVerifier,
+			"nonce":         l.Nonce,
+			"user_uuid":     l.UserUUID,
+		})
+		pipe.Expire(ctx, getLoginStateKey(l.State), GetStateTTL())
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return &l, nil
+}
+
+// ConsumeLoginState returns the started login and deletes it, a callback is accepted once
+func ConsumeLoginState(ctx context.Context, client *redis.Client, state string) (*LoginState, error) {
+	var (
+		values  *redis.StringStringMapCmd
+		deleted *redis.IntCmd
+	)
+	if _, err := client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		values = pipe.HGetAll(ctx, getLoginStateKey(state))
+		deleted = pipe.Del(ctx, getLoginStateKey(state))
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if deleted.Val() == 0 {
+		return nil, nil
+	}
+
+	loginState := NewLoginState(state, values.Val()["provider"], values.Val()["code_verifier"], values.Val()["nonce"])
+	loginState.UserUUID = values.Val()["user_uuid"]
+	return loginState, nil
+}
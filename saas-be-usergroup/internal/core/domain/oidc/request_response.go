@@ -0,0 +1,41 @@
+package oidc
+
+type BeginRequest struct {
+	Provider string `json:"provider"`
+}
+
+func NewBeginRequest(provider string) *BeginRequest {
+	return &BeginRequest{Provider: provider}
+}
+
+func (b BeginRequest) GetProvider() string {
+	return b.Provider
+}
+
+type BeginResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+	State            string `json:"state"`
+}
+
+// CallbackRequest carries the query of the redirect back from the provider, the frontend posts it as is
+type CallbackRequest struct {
+	Provider string `json:"provider"`
+	Code     string `json:"code"`
+	State    string `json:"state"`
+}
+
+func NewCallbackRequest(provider string) *CallbackRequest {
+	return &CallbackRequest{Provider: provider}
+}
+
+func (c CallbackRequest) GetProvider() string {
+	return c.Provider
+}
+
+func (c CallbackRequest) GetCode() string {
+	return c.Code
+}
+
+func (c CallbackRequest) GetState() string {
+	return c.State
+}
@@ -0,0 +1,23 @@
+package oidc
+
+import (
+	"regexp"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+var providerNameRegex = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+func (b BeginRequest) Validate() error {
+	return validation.ValidateStruct(&b,
+		validation.Field(&b.Provider, validation.Required, validation.Match(providerNameRegex)),
+	)
+}
+
+func (c CallbackRequest) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.Provider, validation.Required, validation.Match(providerNameRegex)),
+		validation.Field(&c.Code, validation.Required, validation.Length(1, 2048)),
+		validation.Field(&c.State, validation.Required, validation.Length(1, 128)),
+	)
+}
@@ -0,0 +1,138 @@
+package ratelimit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// fakeRedis is an in-process RESP server that answers the gcra script with the same steps in go,
+// redis runs the lua in production
+type fakeRedis struct {
+	listener net.Listener
+	mu       sync.Mutex
+	tats     map[string]int64
+}
+
+func newFakeRedis(t *testing.T) (*fakeRedis, *redis.Client) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	f := &fakeRedis{listener: listener, tats: map[string]int64{}}
+	go f.serve()
+
+	client := redis.NewClient(&redis.Options{Addr: listener.Addr().String()})
+	t.Cleanup(func() {
+		client.Close()
+		listener.Close()
+	})
+	return f, client
+}
+
+func (f *fakeRedis) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		conn.Write([]byte(f.exec(args)))
+	}
+}
+
+func (f *fakeRedis) exec(args []string) string {
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "EVALSHA", "EVAL":
+		// EVALSHA <sha> 1 <key> <now> <emission> <period>
+		if len(args) != 7 {
+			return "-ERR wrong number of arguments\r\n"
+		}
+		var values [3]int64
+		for i, arg := range args[4:] {
+			values[i], _ = strconv.ParseInt(arg, 10, 64)
+		}
+		reply := f.gcra(args[3], values[0], values[1], values[2])
+		return fmt.Sprintf("*3\r\n:%d\r\n:%d\r\n:%d\r\n", reply[0], reply[1], reply[2])
+	default:
+		return "-ERR unknown command '" + args[0] + "'\r\n"
+	}
+}
+
+// gcra follows gcraScript line by line
+func (f *fakeRedis) gcra(key string, now int64, emission int64, period int64) [3]int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	tat, ok := f.tats[key]
+	if !ok || tat < now {
+		tat = now
+	}
+
+	newTat := tat + emission
+	allowAt := newTat - period
+	if allowAt > now {
+		return [3]int64{0, allowAt - now, tat - now}
+	}
+
+	f.tats[key] = newTat
+	return [3]int64{1, 0, newTat - now}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, errors.New("expected a resp array")
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(header[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
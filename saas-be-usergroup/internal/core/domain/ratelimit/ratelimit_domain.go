@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Policy allows Limit requests per Period for every key, a client may spend the whole limit at once
+// then gets one request back every Period / Limit
+type Policy struct {
+	Name   string
+	Limit  int64
+	Period time.Duration
+}
+
+// NewPolicy declares a policy with its defaults, ratelimit.policies.<name>.limit and .period override them
+func NewPolicy(name string, limit int64, period time.Duration) *Policy {
+	if configLimit := viper.GetInt64("ratelimit.policies." + name + ".limit"); configLimit > 0 {
+		limit = configLimit
+	}
+	if configPeriod := viper.GetDuration("ratelimit.policies." + name + ".period"); configPeriod > 0 {
+		period = configPeriod
+	}
+
+	return &Policy{
+		Name:   name,
+		Limit:  limit,
+		Period: period,
+	}
+}
+
+func IsEnabled() bool {
+	return !viper.IsSet("ratelimit.enabled") || viper.GetBool("ratelimit.enabled")
+}
+
+func (p Policy) GetName() string {
+	return p.Name
+}
+
+func (p Policy) GetLimit() int64 {
+	return p.Limit
+}
+
+func (p Policy) getEmissionInterval() time.Duration {
+	return p.Period / time.Duration(p.Limit)
+}
+
+func (p Policy) getKey(key string) string {
+	return "ratelimit-" + p.Name + "-" + key
+}
+
+type Result struct {
+	Allowed    bool
+	Limit      int64
+	Remaining  int64
+	RetryAfter time.Duration
+	ResetAfter time.Duration
+}
+
+func (r Result) IsAllowed() bool {
+	return r.Allowed
+}
+
+func (r Result) GetLimit() int64 {
+	return r.Limit
+}
+
+func (r Result) GetRemaining() int64 {
+	return r.Remaining
+}
+
+func (r Result) GetRetryAfter() time.Duration {
+	return r.RetryAfter
+}
+
+func (r Result) GetResetAfter() time.Duration {
+	return r.ResetAfter
+}
@@ -0,0 +1,132 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestAllowSpendsTheLimitThenDenies(t *testing.T) {
+	_, client := newFakeRedis(t)
+	policy := &Policy{Name: "test", Limit: 3, Period: time.Minute}
+
+	for i := int64(1); i <= 3; i++ {
+		result, err := policy.Allow(context.Background(), client, "ip-1")
+		if err != nil {
+			t.Fatalf("Allow() error = %v", err)
+		}
+		if !result.IsAllowed() {
+			t.Fatalf("request %d denied, want allowed", i)
+		}
+		if result.GetLimit() != 3 || result.GetRemaining() != 3-i {
+			t.Errorf("request %d limit %d remaining %d, want 3 and %d", i, result.GetLimit(), result.GetRemaining(), 3-i)
+		}
+		if result.GetRetryAfter() != 0 {
+			t.Errorf("request %d retry after %s, want 0", i, result.GetRetryAfter())
+		}
+	}
+
+	result, err := policy.Allow(context.Background(), client, "ip-1")
+	if err != nil {
+		t.Fatalf("Allow() error = %v", err)
+	}
+	if result.IsAllowed() || result.GetRemaining() != 0 {
+		t.Fatalf("allowed %v remaining %d, want denied with 0 remaining", result.IsAllowed(), result.GetRemaining())
+	}
+
+	// one request comes back every period / limit
+	if retryAfter := result.GetRetryAfter(); retryAfter <= 0 || retryAfter > 20*time.Second {
+		t.Errorf("retry after %s, want within the 20s emission interval", retryAfter)
+	}
+	if resetAfter := result.GetResetAfter(); resetAfter <= 40*time.Second || resetAfter > time.Minute {
+		t.Errorf("reset after %s, want close to the whole period", resetAfter)
+	}
+}
+
+func TestAllowGivesARequestBackEveryEmissionInterval(t *testing.T) {
+	_, client := newFakeRedis(t)
+	policy := &Policy{Name: "test", Limit: 2, Period: 200 * time.Millisecond}
+
+	for i := 0; i < 2; i++ {
+		if result, err := policy.Allow(context.Background(), client, "ip-1"); err != nil || !result.IsAllowed() {
+			t.Fatalf("request %d = %v, %v, want allowed", i, result, err)
+		}
+	}
+	denied, err := policy.Allow(context.Background(), client, "ip-1")
+	if err != nil || denied.IsAllowed() {
+		t.Fatalf("third request = %v, %v, want denied", denied, err)
+	}
+
+	time.Sleep(denied.GetRetryAfter() + 10*time.Millisecond)
+
+	if result, err := policy.Allow(context.Background(), client, "ip-1"); err != nil || !result.IsAllowed() {
+		t.Fatalf("request after retry after = %v, %v, want allowed", result, err)
+	}
+	if result, err := policy.Allow(context.Background(), client, "ip-1"); err != nil || result.IsAllowed() {
+		t.Fatalf("second request after retry after = %v, %v, want denied", result, err)
+	}
+}
+
+func TestAllowKeepsABucketPerKeyAndPolicy(t *testing.T) {
+	_, client := newFakeRedis(t)
+	login := &Policy{Name: "login", Limit: 1, Period: time.Minute}
+	register := &Policy{Name: "register", Limit: 1, Period: time.Minute}
+
+	for _, call := range []struct {
+		policy *Policy
+		key    string
+	}{
+		{policy: login, key: "ip-1"},
+		{policy: login, key: "ip-2"},
+		{policy: register, key: "ip-1"},
+	} {
+		result, err := call.policy.Allow(context.Background(), client, call.key)
+		if err != nil || !result.IsAllowed() {
+			t.Fatalf("%s %s = %v, %v, want allowed", call.policy.GetName(), call.key, result, err)
+		}
+	}
+
+	if result, err := login.Allow(context.Background(), client, "ip-1"); err != nil || result.IsAllowed() {
+		t.Fatalf("login ip-1 again = %v, %v, want denied", result, err)
+	}
+}
+
+func TestAllowFailsWithoutRedis(t *testing.T) {
+	fake, client := newFakeRedis(t)
+	fake.listener.Close()
+
+	policy := &Policy{Name: "test", Limit: 1, Period: time.Minute}
+	if _, err := policy.Allow(context.Background(), client, "ip-1"); err == nil {
+		t.Fatal("Allow() error = nil, want the connection error")
+	}
+}
+
+func TestNewPolicyReadsConfig(t *testing.T) {
+	viper.Set("ratelimit.policies.login.limit", 5)
+	viper.Set("ratelimit.policies.login.period", "30s")
+	t.Cleanup(func() {
+		viper.Set("ratelimit.policies.login.limit", nil)
+		viper.Set("ratelimit.policies.login.period", nil)
+	})
+
+	if policy := NewPolicy("login", 20, time.Minute); policy.GetLimit() != 5 || policy.Period != 30*time.Second {
+		t.Errorf("NewPolicy(login) = %+v, want the limit and the period of the config", policy)
+	}
+	if policy := NewPolicy("register", 20, time.Minute); policy.GetLimit() != 20 || policy.Period != time.Minute {
+		t.Errorf("NewPolicy(register) = %+v, want the declared limit and period", policy)
+	}
+}
+
+func TestIsEnabled(t *testing.T) {
+	if !IsEnabled() {
+		t.Error("IsEnabled() = false, want the limiter on when the config leaves it out")
+	}
+
+	viper.Set("ratelimit.enabled", false)
+	t.Cleanup(func() { viper.Set("ratelimit.enabled", nil) })
+	if IsEnabled() {
+		t.Error("IsEnabled() = true, want false")
+	}
+}
@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+var ErrUnexpectedReply = errors.New("unexpected rate limit script reply")
+
+// gcra keeps a single theoretical arrival time per key, the request is allowed when
+// the arrival time minus the whole period is not in the future
+var gcraScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local emission = tonumber(ARGV[2])
+local period = tonumber(ARGV[3])
+
+local tat = tonumber(redis.call("GET", KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+
+local newTat = tat + emission
+local allowAt = newTat - period
+if allowAt > now then
+	return {0, allowAt - now, tat - now}
+end
+
+redis.call("SET", KEYS[1], newTat, "PX", newTat - now)
+return {1, 0, newTat - now}
+`)
+
+// Allow spends one request of the key, the clock of the caller is used so every instance should be in sync
+func (p Policy) Allow(ctx context.Context, client *redis.Client, key string) (*Result, error) {
+	emission := p.getEmissionInterval().Milliseconds()
+	if emission <= 0 {
+		emission = 1
+	}
+
+	reply, err := gcraScript.Run(ctx, client, []string{p.getKey(key)},
+		time.Now().UnixNano()/int64(time.Millisecond), emission, p.Period.Milliseconds()).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	replies, ok := reply.([]interface{})
+	if !ok || len(replies) != 3 {
+		return nil, ErrUnexpectedReply
+	}
+
+	values := make([]int64, len(replies))
+	for i, value := range replies {
+		values[i], _ = value.(int64)
+	}
+
+	resetAfter := time.Duration(values[2]) * time.Millisecond
+	remaining := (p.Period.Milliseconds() - values[2]) / emission
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return &Result{
+		Allowed:    values[0] == 1,
+		Limit:      p.Limit,
+		Remaining:  remaining,
+		RetryAfter: time.Duration(values[1]) * time.Millisecond,
+		ResetAfter: resetAfter,
+	}, nil
+}
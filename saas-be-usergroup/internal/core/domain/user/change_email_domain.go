@@ -0,0 +1,39 @@
+package user
+
+type ChangeEmail struct {
+	UUID            string
+	UserAccountUUID string
+	Email           string
+}
+
+func NewChangeEmail(uuid string, userAccountUUID string, email string) *ChangeEmail {
+	return &ChangeEmail{
+		UUID:            uuid,
+		UserAccountUUID: userAccountUUID,
+		Email:           email,
+	}
+}
+
+func (c ChangeEmail) GetUUID() string {
+	return c.UUID
+}
+
+func (c ChangeEmail) GetUserAccountUUID() string {
+	return c.UserAccountUUID
+}
+
+func (c ChangeEmail) GetEmail() string {
+	return c.Email
+}
+
+func (c *ChangeEmail) IsNotFound() bool {
+	return c == nil || c.Email == ""
+}
+
+func (c ChangeEmail) IsOwnedBy(userAccountUUID string) bool {
+	return c.UserAccountUUID == userAccountUUID
+}
+
+func (c ChangeEmail) ToChangeEmailBeforeResponse() *ChangeEmailBeforeResponse {
+	return &ChangeEmailBeforeResponse{UUID: c.UUID}
+}
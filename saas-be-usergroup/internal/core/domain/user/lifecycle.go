@@ -0,0 +1,98 @@
+package user
+
+import (
+	"errors"
+	"github.com/spf13/viper"
+	"time"
+)
+
+var ErrInvalidStatusTransition = errors.New("invalid account status transition")
+
+const defaultPurgeAfter = time.Hour * 24 * 7
+
+// statusTransitions is the account lifecycle, a status missing from the map has no way out.
+// A sign-up that is started again goes back from confirmed to new, and an identity provider
+// vouching for the email verifies a new account at once
+var statusTransitions = map[UserStatus][]UserStatus{
+	UserNew:       {UserConfirmed, UserVerified, UserDeleted},
+	UserConfirmed: {UserNew, UserVerified, UserDeleted},
+	UserVerified:  {UserSuspended, UserDeleted},
+	UserSuspended: {UserVerified, UserDeleted},
+}
+
+// GetPurgeAfter is the age after which a sign-up that was never finished is removed
+func GetPurgeAfter() time.Duration {
+	if age := viper.GetDuration("registration.purge_after"); age > 0 {
+		return age
+	}
+	return defaultPurgeAfter
+}
+
+// GetStatus reads rows written before the status was always set as new
+func (u User) GetStatus() UserStatus {
+	if u.Status == "" {
+		return UserNew
+	}
+	return u.Status
+}
+
+func (u User) CanTransitionTo(status UserStatus) bool {
+	for _, next := range statusTransitions[u.GetStatus()] {
+		if next == status {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *User) transitionTo(status UserStatus) error {
+	if !u.CanTransitionTo(status) {
+		return ErrInvalidStatusTransition
+	}
+	u.Status = status
+	return nil
+}
+
+// Confirm records the otp of the registration email has been checked
+func (u *User) Confirm() error {
+	if err := u.transitionTo(UserConfirmed); err != nil {
+		return err
+	}
+	u.ConfirmationTime = time.Now()
+	return nil
+}
+
+// Restart takes a sign-up that was not finished back to the otp step, it keeps the row and the uuid
+func (u *User) Restart() error {
+	if u.IsNew() {
+		return nil
+	}
+	return u.transitionTo(UserNew)
+}
+
+// Verify finishes the registration, the account can login from now on. A suspended account is only
+// taken back by Reactivate
+func (u *User) Verify() error {
+	if !u.IsPending() {
+		return ErrInvalidStatusTransition
+	}
+	if u.IsNew() {
+		u.ConfirmationTime = time.Now()
+	}
+	return u.transitionTo(UserVerified)
+}
+
+func (u *User) Suspend() error {
+	return u.transitionTo(UserSuspended)
+}
+
+func (u *User) Reactivate() error {
+	if !u.IsSuspended() {
+		return ErrInvalidStatusTransition
+	}
+	return u.transitionTo(UserVerified)
+}
+
+func (u *User) Delete() error {
+	return u.transitionTo(UserDeleted)
+}
@@ -0,0 +1,99 @@
+package user
+
+import "testing"
+
+var allStatuses = []UserStatus{"", UserNew, UserConfirmed, UserVerified, UserSuspended, UserDeleted}
+
+func TestCanTransitionTo(t *testing.T) {
+	allowed := map[UserStatus][]UserStatus{
+		"":            {UserConfirmed, UserVerified, UserDeleted},
+		UserNew:       {UserConfirmed, UserVerified, UserDeleted},
+		UserConfirmed: {UserNew, UserVerified, UserDeleted},
+		UserVerified:  {UserSuspended, UserDeleted},
+		UserSuspended: {UserVerified, UserDeleted},
+		UserDeleted:   {},
+	}
+
+	for _, from := range allStatuses {
+		for _, to := range allStatuses {
+			want := false
+			for _, next := range allowed[from] {
+				if next == to {
+					want = true
+				}
+			}
+
+			u := User{Status: from}
+			if got := u.CanTransitionTo(to); got != want {
+				t.Errorf("%q -> %q = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestLifecycleTransitions(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    UserStatus
+		apply   func(*User) error
+		want    UserStatus
+		refused bool
+	}{
+		{name: "confirm new", from: UserNew, apply: (*User).Confirm, want: UserConfirmed},
+		{name: "confirm an older row", from: "", apply: (*User).Confirm, want: UserConfirmed},
+		{name: "confirm verified", from: UserVerified, apply: (*User).Confirm, refused: true},
+		{name: "restart confirmed", from: UserConfirmed, apply: (*User).Restart, want: UserNew},
+		{name: "restart new", from: UserNew, apply: (*User).Restart, want: UserNew},
+		{name: "restart verified", from: UserVerified, apply: (*User).Restart, refused: true},
+		{name: "verify new", from: UserNew, apply: (*User).Verify, want: UserVerified},
+		{name: "verify confirmed", from: UserConfirmed, apply: (*User).Verify, want: UserVerified},
+		{name: "verify suspended", from: UserSuspended, apply: (*User).Verify, refused: true},
+		{name: "verify deleted", from: UserDeleted, apply: (*User).Verify, refused: true},
+		{name: "suspend verified", from: UserVerified, apply: (*User).Suspend, want: UserSuspended},
+		{name: "suspend confirmed", from: UserConfirmed, apply: (*User).Suspend, refused: true},
+		{name: "suspend suspended", from: UserSuspended, apply: (*User).Suspend, refused: true},
+		{name: "reactivate suspended", from: UserSuspended, apply: (*User).Reactivate, want: UserVerified},
+		{name: "reactivate verified", from: UserVerified, apply: (*User).Reactivate, refused: true},
+		{name: "reactivate confirmed", from: UserConfirmed, apply: (*User).Reactivate, refused: true},
+		{name: "delete new", from: UserNew, apply: (*User).Delete, want: UserDeleted},
+		{name: "delete verified", from: UserVerified, apply: (*User).Delete, want: UserDeleted},
+		{name: "delete suspended", from: UserSuspended, apply: (*User).Delete, want: UserDeleted},
+		{name: "delete deleted", from: UserDeleted, apply: (*User).Delete, refused: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := &User{Status: tt.from}
+			err := tt.apply(u)
+
+			if tt.refused {
+				if err != ErrInvalidStatusTransition {
+					t.Fatalf("error = %v, want %v", err, ErrInvalidStatusTransition)
+				}
+				if u.Status != tt.from {
+					t.Errorf("status = %q after a refused transition, want %q", u.Status, tt.from)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if u.Status != tt.want {
+				t.Errorf("status = %q, want %q", u.Status, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfirmAndVerifyStampTheConfirmation(t *testing.T) {
+	for _, apply := range []func(*User) error{(*User).Confirm, (*User).Verify} {
+		u := &User{Status: UserNew}
+		if err := apply(u); err != nil {
+			t.Fatalf("error = %v", err)
+		}
+		if u.ConfirmationTime.IsZero() {
+			t.Error("confirmation time is not set")
+		}
+	}
+}
@@ -2,9 +2,21 @@ package user
 
 import (
 	"errors"
+	"github.com/jackc/pgconn"
 	"gorm.io/gorm"
+	"time"
 )
 
+// uniqueViolation is the postgres error code of a unique index refusing a row
+const uniqueViolation = "23505"
+
+// ErrEmailTaken is the unique index on the email of the registered accounts refusing a second one
+var ErrEmailTaken = errors.New("email is held by another account")
+
+// pendingStatuses are the sign-ups that were not finished, rows without a status are older ones and
+// the queries read a null status as an empty one
+var pendingStatuses = []UserStatus{"", UserNew, UserConfirmed}
+
 func (u *User) Create(db *gorm.DB) (*User, error) {
 	if err := db.Save(&u).Error; err != nil {
 		return nil, err
@@ -34,7 +46,7 @@ func (u *User) GetOneByID(db *gorm.DB, id uint64) (*User, error) {
 }
 
 func (u *User) GetOneByEmail(db *gorm.DB, email string) (*User, error) {
-	if err := db.Where("email = ?", email).First(&u).Error; err != nil {
+	if err := db.Where("email = ? AND COALESCE(status, '') <> ?", email, UserDeleted).First(&u).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
@@ -58,7 +70,7 @@ func (u *User) GetOneByUUID(db *gorm.DB, uuid string) (*User, error) {
 }
 
 func (u *User) GetOneByUsername(db *gorm.DB, username string) (*User, error) {
-	if err := db.Where("username = ?", username).First(&u).Error; err != nil {
+	if err := db.Where("username = ? AND COALESCE(status, '') <> ?", username, UserDeleted).First(&u).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
@@ -88,9 +100,47 @@ func (u *User) IsEmailAvailable(db *gorm.DB, email string) (bool, error) {
 		return false, err
 	}
 
-	if u.IsEmpty() || !u.IsVerified() {
+	// a pending sign-up does not hold the email, it is reused by the next registration
+	if u.IsEmpty() || u.IsPending() {
 		return true, nil
 	}
 
 	return false, nil
 }
+
+// ClaimEmail moves the account onto the email, the unfinished sign-ups holding it are removed in the same
+// transaction so they can not be registered on top of it
+func (u *User) ClaimEmail(db *gorm.DB, email string) (*User, error) {
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := u.ReleasePendingEmail(tx, email); err != nil {
+			return err
+		}
+
+		u.SetEmail(email)
+		return tx.Save(&u).Error
+	}); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return nil, ErrEmailTaken
+		}
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// ReleasePendingEmail removes the unfinished sign-ups of other rows holding the email
+func (u *User) ReleasePendingEmail(db *gorm.DB, email string) error {
+	return db.Where("email = ? AND uuid <> ? AND COALESCE(status, '') IN ? AND COALESCE(password, '') = ''", email, u.UUID, pendingStatuses).Delete(&User{}).Error
+}
+
+// DeletePendingBefore removes the sign-ups started before the given time that never got verified. A row with
+// a password finished its registration before the status was kept and is never purged
+func (u *User) DeletePendingBefore(db *gorm.DB, before time.Time) (int64, error) {
+	result := db.Where("COALESCE(status, '') IN ? AND COALESCE(password, '') = '' AND insert_ts < ?", pendingStatuses, before).Delete(&User{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,49 @@
+package user
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Create stores the pending email, its otp is kept apart with the change-email purpose
+func (c ChangeEmail) Create(ctx context.Context, client *redis.Client, expiredInSecond uint64) (*ChangeEmail, error) {
+	key := "change-email-" + c.GetUUID()
+	if _, err := client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, map[string]interface{}{
+			"user_account_uuid": c.GetUserAccountUUID(),
+			"email":             c.GetEmail(),
+		})
+		pipe.Expire(ctx, key, time.Duration(expiredInSecond)*time.Second)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+func GetChangeEmailByUUID(ctx context.Context, client *redis.Client, uuid string) (*ChangeEmail, error) {
+	values, err := client.HGetAll(ctx, "change-email-"+uuid).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	// HGetAll returns an empty map instead of redis.Nil when the key does not exist
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	return NewChangeEmail(uuid, values["user_account_uuid"], values["email"]), nil
+}
+
+// Consume deletes the pending email once its otp is verified, false means another request has consumed it
+func (c ChangeEmail) Consume(ctx context.Context, client *redis.Client) (bool, error) {
+	deleted, err := client.Del(ctx, "change-email-"+c.GetUUID()).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return deleted > 0, nil
+}
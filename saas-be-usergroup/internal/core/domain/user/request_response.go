@@ -1,11 +1,7 @@
 package user
 
-import (
-	"strings"
-)
-
 func IsPasswordMatched(password string, confirmPassword string) bool {
-	return strings.EqualFold(password, confirmPassword)
+	return password == confirmPassword
 }
 
 type UpdateRequest struct {
@@ -14,6 +10,28 @@ type UpdateRequest struct {
 	UserName  *string `json:"user_name"`
 }
 
+func NewUpdateRequest() *UpdateRequest {
+	return &UpdateRequest{}
+}
+
+// ToUpdateUser applies the partial request, nil fields are left unchanged
+func (r UpdateRequest) ToUpdateUser(u *User) *User {
+	if r.FirstName != nil {
+		u.SetFirstName(*r.FirstName)
+	}
+	if r.LastName != nil {
+		u.SetLastName(*r.LastName)
+	}
+	if r.UserName != nil {
+		u.SetUsername(*r.UserName)
+	}
+	return u
+}
+
+func (r UpdateRequest) IsUsernameChanged(u *User) bool {
+	return r.UserName != nil && *r.UserName != u.UserName
+}
+
 type UpdateResponse struct {
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
@@ -56,6 +74,14 @@ type ChangeEmailBeforeRequest struct {
 	Email string `json:"email"`
 }
 
+func NewChangeEmailBeforeRequest() *ChangeEmailBeforeRequest {
+	return &ChangeEmailBeforeRequest{}
+}
+
+func (c ChangeEmailBeforeRequest) GetEmail() string {
+	return c.Email
+}
+
 type ChangeEmailBeforeResponse struct {
 	UUID string `json:"uuid"`
 }
@@ -65,11 +91,48 @@ type ChangeEmailConfirmationRequest struct {
 	UUID string `json:"uuid"`
 }
 
+func NewChangeEmailConfirmationRequest() *ChangeEmailConfirmationRequest {
+	return &ChangeEmailConfirmationRequest{}
+}
+
+func (c ChangeEmailConfirmationRequest) GetOTP() string {
+	return c.OTP
+}
+
+func (c ChangeEmailConfirmationRequest) GetUUID() string {
+	return c.UUID
+}
+
 type ChangePasswordConfirmationRequest struct {
 	OTP string `json:"otp"`
 }
 
+func NewChangePasswordConfirmationRequest() *ChangePasswordConfirmationRequest {
+	return &ChangePasswordConfirmationRequest{}
+}
+
+func (c ChangePasswordConfirmationRequest) GetOTP() string {
+	return c.OTP
+}
+
+type ChangePasswordConfirmationResponse struct {
+	SessionToken string `json:"session_token"`
+}
+
 type DoChangePasswordRequest struct {
+	SessionToken    string `json:"session_token"`
 	Password        string `json:"password"`
 	ConfirmPassword string `json:"confirm_password"`
 }
+
+func NewDoChangePasswordRequest() *DoChangePasswordRequest {
+	return &DoChangePasswordRequest{}
+}
+
+func (d DoChangePasswordRequest) GetSessionToken() string {
+	return d.SessionToken
+}
+
+func (d DoChangePasswordRequest) GetPassword() string {
+	return d.Password
+}
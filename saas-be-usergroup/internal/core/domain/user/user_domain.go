@@ -9,8 +9,10 @@ type UserStatus string
 
 const (
 	UserNew       UserStatus = "new"
-	UserConfirmed            = "confirmed"
-	UserVerifieed            = "verified"
+	UserConfirmed UserStatus = "confirmed"
+	UserVerified  UserStatus = "verified"
+	UserSuspended UserStatus = "suspended"
+	UserDeleted   UserStatus = "deleted"
 )
 
 type User struct {
@@ -42,6 +44,10 @@ func (u *User) SetPassword(hashedPassword string) {
 	u.Password = hashedPassword
 }
 
+func (u *User) SetEmail(email string) {
+	u.Email = email
+}
+
 func NewUser() *User {
 	return &User{}
 }
@@ -51,7 +57,7 @@ func (u *User) IsEmpty() bool {
 }
 
 func (u User) IsNew() bool {
-	return u.Status == UserNew
+	return u.GetStatus() == UserNew
 }
 
 func (u User) IsConfirmed() bool {
@@ -59,7 +65,16 @@ func (u User) IsConfirmed() bool {
 }
 
 func (u User) IsVerified() bool {
-	return u.Status == UserVerifieed
+	return u.Status == UserVerified
+}
+
+func (u User) IsSuspended() bool {
+	return u.Status == UserSuspended
+}
+
+// IsPending tells a sign-up that has not been finished, the row is reused when the email registers again
+func (u User) IsPending() bool {
+	return u.IsNew() || u.IsConfirmed()
 }
 
 func (u User) GetUUID() string {
@@ -70,12 +85,8 @@ func (u User) GetEmail() string {
 	return u.Email
 }
 
-func (u *User) SetStatusConfirmed() {
-	u.Status = UserConfirmed
-}
-
-func (u *User) SetStatusVerified() {
-	u.Status = UserVerifieed
+func (u User) GetUsername() string {
+	return u.UserName
 }
 
 func (u *User) ToTransformer() *Transformer {
@@ -88,6 +99,14 @@ func (u *User) ToTransformer() *Transformer {
 	}
 }
 
+func (u *User) ToUpdateResponse() *UpdateResponse {
+	return &UpdateResponse{
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		UserName:  u.UserName,
+	}
+}
+
 func (u User) GetName() string {
 	return fmt.Sprintf("%s %s", u.FirstName, u.LastName)
 }
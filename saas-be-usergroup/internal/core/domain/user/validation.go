@@ -8,9 +8,9 @@ import (
 
 func (c UpdateRequest) Validate() error {
 	return validation.ValidateStruct(&c,
-		validation.Field(&c.FirstName, validation.Min(1)),
-		validation.Field(&c.LastName, validation.Min(1)),
-		validation.Field(&c.UserName, validation.Min(1)),
+		validation.Field(&c.FirstName, validation.NilOrNotEmpty),
+		validation.Field(&c.LastName, validation.NilOrNotEmpty),
+		validation.Field(&c.UserName, validation.NilOrNotEmpty),
 	)
 }
 
@@ -34,8 +34,8 @@ func (c ChangeEmailBeforeRequest) Validate() error {
 
 func (c ChangeEmailConfirmationRequest) Validate() error {
 	return validation.ValidateStruct(&c,
-		validation.Field(&c.UUID, validation.Required, is.Email),
-		validation.Field(&c.OTP, validation.Required, is.Email),
+		validation.Field(&c.UUID, validation.Required),
+		validation.Field(&c.OTP, validation.Required),
 	)
 }
 
@@ -47,13 +47,14 @@ func (c ChangePasswordConfirmationRequest) Validate() error {
 
 func (c DoChangePasswordRequest) Validate() error {
 	if err := validation.ValidateStruct(&c,
-		validation.Field(&c.Password, validation.Required, validation.Min(8)),
-		validation.Field(&c.ConfirmPassword, validation.Required, validation.Min(8)),
+		validation.Field(&c.SessionToken, validation.Required),
+		validation.Field(&c.Password, validation.Required),
+		validation.Field(&c.ConfirmPassword, validation.Required),
 	); err != nil {
 		return err
 	}
 
-	if IsPasswordMatched(c.Password, c.ConfirmPassword) {
+	if !IsPasswordMatched(c.Password, c.ConfirmPassword) {
 		return errors.New("Password does not match")
 	}
 
@@ -0,0 +1,195 @@
+package webauthn
+
+import (
+	"errors"
+	"math"
+)
+
+var ErrInvalidCBOR = errors.New("invalid cbor")
+
+const cborMaxDepth = 16
+
+// cborDecoder reads the subset of cbor used by webauthn, attestation objects and cose keys
+// are encoded with definite lengths so indefinite length items are refused
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+// decodeCBOR returns the first item and the number of bytes it used,
+// authenticator data carries the cose key followed by optional extensions
+func decodeCBOR(data []byte) (interface{}, int, error) {
+	d := &cborDecoder{data: data}
+	value, err := d.decode(0)
+	if err != nil {
+		return nil, 0, err
+	}
+	return value, d.pos, nil
+}
+
+func (d *cborDecoder) readByte() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, ErrInvalidCBOR
+	}
+	b := d.data[d.pos]
+	d.pos++
+	return b, nil
+}
+
+func (d *cborDecoder) readN(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, ErrInvalidCBOR
+	}
+	b := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+func (d *cborDecoder) readArgument(info byte) (uint64, error) {
+	if info < 24 {
+		return uint64(info), nil
+	}
+
+	var size uint64
+	switch info {
+	case 24:
+		size = 1
+	case 25:
+		size = 2
+	case 26:
+		size = 4
+	case 27:
+		size = 8
+	default:
+		return 0, ErrInvalidCBOR
+	}
+
+	b, err := d.readN(size)
+	if err != nil {
+		return 0, err
+	}
+
+	var arg uint64
+	for _, v := range b {
+		arg = arg<<8 | uint64(v)
+	}
+	return arg, nil
+}
+
+func (d *cborDecoder) decode(depth int) (interface{}, error) {
+	if depth > cborMaxDepth {
+		return nil, ErrInvalidCBOR
+	}
+
+	b, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+	major, info := b>>5, b&0x1f
+
+	if major == 7 {
+		return d.decodeSimple(info)
+	}
+
+	arg, err := d.readArgument(info)
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, ErrInvalidCBOR
+		}
+		return int64(arg), nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, ErrInvalidCBOR
+		}
+		return -1 - int64(arg), nil
+	case 2:
+		raw, err := d.readN(arg)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), raw...), nil
+	case 3:
+		raw, err := d.readN(arg)
+		if err != nil {
+			return nil, err
+		}
+		return string(raw), nil
+	case 4:
+		// every item takes at least one byte, a larger count can only be malformed
+		if arg > uint64(len(d.data)-d.pos) {
+			return nil, ErrInvalidCBOR
+		}
+		items := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			item, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case 5:
+		if arg > uint64(len(d.data)-d.pos) {
+			return nil, ErrInvalidCBOR
+		}
+		items := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			key, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, ErrInvalidCBOR
+			}
+			value, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items[key] = value
+		}
+		return items, nil
+	case 6:
+		// tags carry no meaning for webauthn, the tagged item is returned as is
+		return d.decode(depth + 1)
+	}
+
+	return nil, ErrInvalidCBOR
+}
+
+func (d *cborDecoder) decodeSimple(info byte) (interface{}, error) {
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+	case 25:
+		_, err := d.readN(2)
+		return nil, err
+	case 26:
+		raw, err := d.readN(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(uint32(raw[0])<<24 | uint32(raw[1])<<16 | uint32(raw[2])<<8 | uint32(raw[3]))), nil
+	case 27:
+		raw, err := d.readN(8)
+		if err != nil {
+			return nil, err
+		}
+		var bits uint64
+		for _, v := range raw {
+			bits = bits<<8 | uint64(v)
+		}
+		return math.Float64frombits(bits), nil
+	}
+	return nil, ErrInvalidCBOR
+}
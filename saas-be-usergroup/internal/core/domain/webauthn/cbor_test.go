@@ -0,0 +1,134 @@
+package webauthn
+
+import (
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("bad hex fixture %q: %v", s, err)
+	}
+	return b
+}
+
+// the fixtures come from the examples of rfc 8949 appendix a
+func TestDecodeCBOR(t *testing.T) {
+	tests := []struct {
+		name string
+		hex  string
+		want interface{}
+	}{
+		{"zero", "00", int64(0)},
+		{"small uint", "17", int64(23)},
+		{"one byte uint", "1818", int64(24)},
+		{"two byte uint", "1903e8", int64(1000)},
+		{"four byte uint", "1a000f4240", int64(1000000)},
+		{"eight byte uint", "1b000000e8d4a51000", int64(1000000000000)},
+		{"negative", "20", int64(-1)},
+		{"negative cose alg", "390100", int64(-257)},
+		{"byte string", "4401020304", []byte{1, 2, 3, 4}},
+		{"empty byte string", "40", []byte(nil)},
+		{"text string", "6449455446", "IETF"},
+		{"utf-8 text", "62c3bc", "ü"},
+		{"array", "83010203", []interface{}{int64(1), int64(2), int64(3)}},
+		{"nested array", "8301820203820405", []interface{}{int64(1), []interface{}{int64(2), int64(3)}, []interface{}{int64(4), int64(5)}}},
+		{"int keyed map", "a201020304", map[interface{}]interface{}{int64(1): int64(2), int64(3): int64(4)}},
+		{"text keyed map", "a26161016162820203", map[interface{}]interface{}{"a": int64(1), "b": []interface{}{int64(2), int64(3)}}},
+		{"false", "f4", false},
+		{"true", "f5", true},
+		{"null", "f6", nil},
+		{"float32", "fa47c35000", float64(100000)},
+		{"float64", "fb3ff199999999999a", 1.1},
+		{"tagged item", "c11a514b67b0", int64(1363896240)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := mustHex(t, tt.hex)
+			got, n, err := decodeCBOR(data)
+			if err != nil {
+				t.Fatalf("decodeCBOR(%s) error = %v", tt.hex, err)
+			}
+			if n != len(data) {
+				t.Errorf("decodeCBOR(%s) used %d bytes, want %d", tt.hex, n, len(data))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeCBOR(%s) = %#v, want %#v", tt.hex, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORReturnsFirstItemLength(t *testing.T) {
+	// a cose key followed by an extensions map, as in authenticator data with the ED flag
+	data := mustHex(t, "a10102a0")
+	_, n, err := decodeCBOR(data)
+	if err != nil {
+		t.Fatalf("decodeCBOR() error = %v", err)
+	}
+	if n != 3 {
+		t.Errorf("decodeCBOR() used %d bytes, want 3", n)
+	}
+}
+
+func TestDecodeCBORMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		hex  string
+	}{
+		{"empty input", ""},
+		{"truncated uint argument", "19e8"},
+		{"truncated eight byte argument", "1b0000"},
+		{"reserved additional info", "1c"},
+		{"truncated byte string", "440102"},
+		{"byte string longer than input", "5affffffff00"},
+		{"truncated text string", "644945"},
+		{"truncated array", "830102"},
+		{"array count larger than input", "9b00000000ffffffff"},
+		{"map missing value", "a101"},
+		{"map count larger than input", "bb00000000ffffffff01"},
+		{"map with array key", "a1800102"},
+		{"map with byte string key", "a1410102"},
+		{"indefinite length byte string", "5f4101ff"},
+		{"indefinite length array", "9f01ff"},
+		{"break outside of an indefinite item", "ff"},
+		{"negative overflowing int64", "3bffffffffffffffff"},
+		{"uint overflowing int64", "1bffffffffffffffff"},
+		{"truncated float32", "fa47c3"},
+		{"truncated float64", "fb3ff1999999"},
+		{"unassigned simple value", "e0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := decodeCBOR(mustHex(t, tt.hex)); !errors.Is(err, ErrInvalidCBOR) {
+				t.Errorf("decodeCBOR(%s) error = %v, want ErrInvalidCBOR", tt.hex, err)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORDepthLimit(t *testing.T) {
+	data := make([]byte, 0, cborMaxDepth+3)
+	for i := 0; i < cborMaxDepth+2; i++ {
+		data = append(data, 0x81)
+	}
+	data = append(data, 0x00)
+
+	if _, _, err := decodeCBOR(data); !errors.Is(err, ErrInvalidCBOR) {
+		t.Errorf("decodeCBOR() of %d nested arrays error = %v, want ErrInvalidCBOR", cborMaxDepth+2, err)
+	}
+}
+
+// half precision floats never appear in webauthn, they are read past and decode to nil
+func TestDecodeCBORFloat16IsSkipped(t *testing.T) {
+	got, n, err := decodeCBOR(mustHex(t, "f93c00"))
+	if err != nil || n != 3 || got != nil {
+		t.Errorf("decodeCBOR(f93c00) = %v, %d, %v, want nil, 3, nil", got, n, err)
+	}
+}
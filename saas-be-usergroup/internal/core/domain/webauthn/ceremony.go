@@ -0,0 +1,348 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+const (
+	publicKeyCredentialType = "public-key"
+	clientDataTypeCreate    = "webauthn.create"
+	clientDataTypeGet       = "webauthn.get"
+	attestationNone         = "none"
+
+	// cose algorithm identifiers
+	algorithmES256 = int64(-7)
+	algorithmEdDSA = int64(-8)
+	algorithmRS256 = int64(-257)
+
+	flagUserPresent      = 0x01
+	flagUserVerified     = 0x04
+	flagAttestedCredData = 0x40
+
+	authenticatorDataMinLength = 37
+	maxCredentialIDLength      = 1023
+)
+
+var (
+	ErrInvalidClientData        = errors.New("invalid client data")
+	ErrInvalidOrigin            = errors.New("origin is not allowed")
+	ErrInvalidAuthenticatorData = errors.New("invalid authenticator data")
+	ErrInvalidRPID              = errors.New("credential was created for another relying party")
+	ErrUserNotPresent           = errors.New("user presence is required")
+	ErrUserNotVerified          = errors.New("user verification is required")
+	ErrUnsupportedAttestation   = errors.New("unsupported attestation format")
+	ErrUnsupportedAlgorithm     = errors.New("unsupported public key algorithm")
+	ErrInvalidPublicKey         = errors.New("invalid public key")
+	ErrInvalidSignature         = errors.New("invalid signature")
+	ErrCredentialMismatch       = errors.New("credential id does not match")
+	ErrSignCountRegressed       = errors.New("signature counter did not increase, the authenticator may be cloned")
+)
+
+var supportedAlgorithms = []int64{algorithmES256, algorithmEdDSA, algorithmRS256}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// ParseClientDataChallenge reads the challenge out of the client data, the login looks its session up with it
+func ParseClientDataChallenge(encoded string) (string, error) {
+	data, _, err := parseClientData(encoded)
+	if err != nil {
+		return "", err
+	}
+	return data.Challenge, nil
+}
+
+func parseClientData(encoded string) (*clientData, []byte, error) {
+	raw, err := decodeBase64URL(encoded)
+	if err != nil {
+		return nil, nil, ErrInvalidClientData
+	}
+
+	var data clientData
+	if err = json.Unmarshal(raw, &data); err != nil {
+		return nil, nil, ErrInvalidClientData
+	}
+	return &data, raw, nil
+}
+
+func (c clientData) verify(ceremonyType string, challenge string) error {
+	if c.Type != ceremonyType {
+		return ErrInvalidClientData
+	}
+	if subtle.ConstantTimeCompare([]byte(c.Challenge), []byte(challenge)) != 1 {
+		return ErrInvalidClientData
+	}
+	for _, origin := range GetOrigins() {
+		if c.Origin == origin {
+			return nil
+		}
+	}
+	return ErrInvalidOrigin
+}
+
+type authenticatorData struct {
+	raw          []byte
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	aaguid       []byte
+	credentialID []byte
+	publicKey    map[interface{}]interface{}
+}
+
+func parseAuthenticatorData(raw []byte) (*authenticatorData, error) {
+	if len(raw) < authenticatorDataMinLength {
+		return nil, ErrInvalidAuthenticatorData
+	}
+
+	data := &authenticatorData{
+		raw:       raw,
+		rpIDHash:  raw[:32],
+		flags:     raw[32],
+		signCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+
+	if data.flags&flagAttestedCredData == 0 {
+		return data, nil
+	}
+
+	// aaguid, length of the credential id, the credential id and its cose public key
+	rest := raw[authenticatorDataMinLength:]
+	if len(rest) < 18 {
+		return nil, ErrInvalidAuthenticatorData
+	}
+	data.aaguid = rest[:16]
+	idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if idLength > maxCredentialIDLength || len(rest) < idLength {
+		return nil, ErrInvalidAuthenticatorData
+	}
+	data.credentialID = rest[:idLength]
+
+	publicKey, _, err := decodeCBOR(rest[idLength:])
+	if err != nil {
+		return nil, ErrInvalidAuthenticatorData
+	}
+	var ok bool
+	if data.publicKey, ok = publicKey.(map[interface{}]interface{}); !ok {
+		return nil, ErrInvalidAuthenticatorData
+	}
+
+	return data, nil
+}
+
+func (a authenticatorData) verify() error {
+	rpIDHash := sha256.Sum256([]byte(GetRPID()))
+	if subtle.ConstantTimeCompare(a.rpIDHash, rpIDHash[:]) != 1 {
+		return ErrInvalidRPID
+	}
+	if a.flags&flagUserPresent == 0 {
+		return ErrUserNotPresent
+	}
+	if isUserVerificationRequired() && a.flags&flagUserVerified == 0 {
+		return ErrUserNotVerified
+	}
+	return nil
+}
+
+// VerifyRegistration checks the attestation against the challenge of the registration and returns the
+// credential to store. Only the "none" format is accepted, the options never ask for an attestation so
+// the authenticator model is not checked
+func VerifyRegistration(in RegistrationCredential, challenge string, userAccountID uint64, name string) (*UserWebauthnCredential, error) {
+	data, _, err := parseClientData(in.Response.ClientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+	if err = data.verify(clientDataTypeCreate, challenge); err != nil {
+		return nil, err
+	}
+
+	rawAttestation, err := decodeBase64URL(in.Response.AttestationObject)
+	if err != nil {
+		return nil, ErrInvalidAuthenticatorData
+	}
+	decoded, _, err := decodeCBOR(rawAttestation)
+	if err != nil {
+		return nil, ErrInvalidAuthenticatorData
+	}
+	attestation, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, ErrInvalidAuthenticatorData
+	}
+	if format, _ := attestation["fmt"].(string); format != attestationNone {
+		return nil, ErrUnsupportedAttestation
+	}
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return nil, ErrInvalidAuthenticatorData
+	}
+
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err = authData.verify(); err != nil {
+		return nil, err
+	}
+	if authData.credentialID == nil {
+		return nil, ErrInvalidAuthenticatorData
+	}
+	if in.ID != encodeBase64URL(authData.credentialID) {
+		return nil, ErrCredentialMismatch
+	}
+
+	publicKey, algorithm, err := parseCOSEKey(authData.publicKey)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, ErrInvalidPublicKey
+	}
+
+	return &UserWebauthnCredential{
+		UserAccountID: userAccountID,
+		CredentialID:  encodeBase64URL(authData.credentialID),
+		PublicKey:     der,
+		Algorithm:     algorithm,
+		SignCount:     authData.signCount,
+		AAGUID:        hex.EncodeToString(authData.aaguid),
+		Name:          name,
+		Transports:    strings.Join(in.Response.Transports, ","),
+	}, nil
+}
+
+// VerifyAssertion checks the signature of a login with the stored public key and returns the new signature counter
+func (u UserWebauthnCredential) VerifyAssertion(in AssertionCredential, challenge string) (uint32, error) {
+	if in.ID != u.CredentialID {
+		return 0, ErrCredentialMismatch
+	}
+
+	data, rawClientData, err := parseClientData(in.Response.ClientDataJSON)
+	if err != nil {
+		return 0, err
+	}
+	if err = data.verify(clientDataTypeGet, challenge); err != nil {
+		return 0, err
+	}
+
+	rawAuthData, err := decodeBase64URL(in.Response.AuthenticatorData)
+	if err != nil {
+		return 0, ErrInvalidAuthenticatorData
+	}
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return 0, err
+	}
+	if err = authData.verify(); err != nil {
+		return 0, err
+	}
+
+	signature, err := decodeBase64URL(in.Response.Signature)
+	if err != nil {
+		return 0, ErrInvalidSignature
+	}
+	publicKey, err := u.getPublicKey()
+	if err != nil {
+		return 0, ErrInvalidPublicKey
+	}
+	clientDataHash := sha256.Sum256(rawClientData)
+	signed := append(append([]byte(nil), authData.raw...), clientDataHash[:]...)
+	if err = verifySignature(publicKey, u.Algorithm, signed, signature); err != nil {
+		return 0, err
+	}
+
+	// authenticators without a counter always send zero, otherwise it has to grow with every assertion
+	if (authData.signCount != 0 || u.SignCount != 0) && authData.signCount <= u.SignCount {
+		return 0, ErrSignCountRegressed
+	}
+
+	return authData.signCount, nil
+}
+
+// IsUserHandleOf tells whether the user handle returned by a discoverable credential belongs to the uuid
+func IsUserHandleOf(userHandle string, uuid string) bool {
+	if userHandle == "" {
+		return true
+	}
+	raw, err := decodeBase64URL(userHandle)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(raw, []byte(uuid))
+}
+
+func parseCOSEKey(key map[interface{}]interface{}) (crypto.PublicKey, int64, error) {
+	keyType, _ := key[int64(1)].(int64)
+	algorithm, _ := key[int64(3)].(int64)
+
+	switch algorithm {
+	case algorithmES256:
+		curve, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		y, _ := key[int64(-3)].([]byte)
+		if keyType != 2 || curve != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, ErrInvalidPublicKey
+		}
+		publicKey := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !publicKey.Curve.IsOnCurve(publicKey.X, publicKey.Y) {
+			return nil, 0, ErrInvalidPublicKey
+		}
+		return publicKey, algorithm, nil
+	case algorithmEdDSA:
+		curve, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		if keyType != 1 || curve != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, ErrInvalidPublicKey
+		}
+		return ed25519.PublicKey(x), algorithm, nil
+	case algorithmRS256:
+		n, _ := key[int64(-1)].([]byte)
+		e, _ := key[int64(-2)].([]byte)
+		if keyType != 3 || len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, ErrInvalidPublicKey
+		}
+		exponent := new(big.Int).SetBytes(e)
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, algorithm, nil
+	}
+
+	return nil, 0, ErrUnsupportedAlgorithm
+}
+
+func verifySignature(publicKey crypto.PublicKey, algorithm int64, signed []byte, signature []byte) error {
+	digest := sha256.Sum256(signed)
+
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		if algorithm == algorithmES256 && ecdsa.VerifyASN1(key, digest[:], signature) {
+			return nil
+		}
+	case ed25519.PublicKey:
+		if algorithm == algorithmEdDSA && ed25519.Verify(key, signed, signature) {
+			return nil
+		}
+	case *rsa.PublicKey:
+		if algorithm == algorithmRS256 && rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil {
+			return nil
+		}
+	}
+
+	return ErrInvalidSignature
+}
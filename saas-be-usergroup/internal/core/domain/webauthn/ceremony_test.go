@@ -0,0 +1,405 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sort"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	testRPID   = "localhost"
+	testOrigin = "http://localhost:3000"
+)
+
+func setTestRelyingParty(t *testing.T) {
+	t.Helper()
+	viper.Set("webauthn.rp_id", testRPID)
+	viper.Set("webauthn.origins", []string{testOrigin})
+	viper.Set("webauthn.user_verification", UserVerificationPreferred)
+	t.Cleanup(func() {
+		viper.Set("webauthn.rp_id", nil)
+		viper.Set("webauthn.origins", nil)
+		viper.Set("webauthn.user_verification", nil)
+	})
+}
+
+// encodeTestCBOR writes the definite length items the authenticator fixtures need
+func encodeTestCBOR(v interface{}) []byte {
+	var buf bytes.Buffer
+	writeTestCBOR(&buf, v)
+	return buf.Bytes()
+}
+
+func writeTestCBORHead(buf *bytes.Buffer, major byte, arg uint64) {
+	switch {
+	case arg < 24:
+		buf.WriteByte(major<<5 | byte(arg))
+	case arg <= 0xff:
+		buf.WriteByte(major<<5 | 24)
+		buf.WriteByte(byte(arg))
+	case arg <= 0xffff:
+		buf.WriteByte(major<<5 | 25)
+		binary.Write(buf, binary.BigEndian, uint16(arg))
+	case arg <= 0xffffffff:
+		buf.WriteByte(major<<5 | 26)
+		binary.Write(buf, binary.BigEndian, uint32(arg))
+	default:
+		buf.WriteByte(major<<5 | 27)
+		binary.Write(buf, binary.BigEndian, arg)
+	}
+}
+
+func writeTestCBOR(buf *bytes.Buffer, v interface{}) {
+	switch value := v.(type) {
+	case int64:
+		if value < 0 {
+			writeTestCBORHead(buf, 1, uint64(-1-value))
+		} else {
+			writeTestCBORHead(buf, 0, uint64(value))
+		}
+	case []byte:
+		writeTestCBORHead(buf, 2, uint64(len(value)))
+		buf.Write(value)
+	case string:
+		writeTestCBORHead(buf, 3, uint64(len(value)))
+		buf.WriteString(value)
+	case map[interface{}]interface{}:
+		// keys are sorted so the fixtures are stable between runs
+		keys := make([]interface{}, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return string(encodeTestCBOR(keys[i])) < string(encodeTestCBOR(keys[j]))
+		})
+		writeTestCBORHead(buf, 5, uint64(len(value)))
+		for _, key := range keys {
+			writeTestCBOR(buf, key)
+			writeTestCBOR(buf, value[key])
+		}
+	default:
+		panic("unsupported test cbor value")
+	}
+}
+
+// testAuthenticator signs like a platform authenticator holding one credential
+type testAuthenticator struct {
+	algorithm    int64
+	credentialID []byte
+	coseKey      map[interface{}]interface{}
+	sign         func(signed []byte) []byte
+}
+
+func newTestAuthenticator(t *testing.T, algorithm int64) *testAuthenticator {
+	t.Helper()
+
+	a := &testAuthenticator{algorithm: algorithm, credentialID: []byte("credential-" + big.NewInt(-algorithm).String())}
+	switch algorithm {
+	case algorithmES256:
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("failed to generate p-256 key: %v", err)
+		}
+		a.coseKey = map[interface{}]interface{}{
+			int64(1): int64(2), int64(3): algorithmES256, int64(-1): int64(1),
+			int64(-2): key.X.FillBytes(make([]byte, 32)), int64(-3): key.Y.FillBytes(make([]byte, 32)),
+		}
+		a.sign = func(signed []byte) []byte {
+			digest := sha256.Sum256(signed)
+			signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+			if err != nil {
+				t.Fatalf("failed to sign: %v", err)
+			}
+			return signature
+		}
+	case algorithmEdDSA:
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatalf("failed to generate ed25519 key: %v", err)
+		}
+		a.coseKey = map[interface{}]interface{}{
+			int64(1): int64(1), int64(3): algorithmEdDSA, int64(-1): int64(6), int64(-2): []byte(public),
+		}
+		a.sign = func(signed []byte) []byte {
+			return ed25519.Sign(private, signed)
+		}
+	case algorithmRS256:
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatalf("failed to generate rsa key: %v", err)
+		}
+		a.coseKey = map[interface{}]interface{}{
+			int64(1): int64(3), int64(3): algorithmRS256,
+			int64(-1): key.N.Bytes(), int64(-2): big.NewInt(int64(key.E)).Bytes(),
+		}
+		a.sign = func(signed []byte) []byte {
+			digest := sha256.Sum256(signed)
+			signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+			if err != nil {
+				t.Fatalf("failed to sign: %v", err)
+			}
+			return signature
+		}
+	}
+	return a
+}
+
+func testClientData(ceremonyType string, challenge string, origin string) []byte {
+	raw, _ := json.Marshal(clientData{Type: ceremonyType, Challenge: challenge, Origin: origin})
+	return raw
+}
+
+func testAuthenticatorData(flags byte, signCount uint32, attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+	data := append([]byte(nil), rpIDHash[:]...)
+	data = append(data, flags)
+	counter := make([]byte, 4)
+	binary.BigEndian.PutUint32(counter, signCount)
+	data = append(data, counter...)
+	return append(data, attested...)
+}
+
+func (a testAuthenticator) attestedCredentialData() []byte {
+	// zero aaguid, then the length of the credential id
+	data := make([]byte, 18, 18+len(a.credentialID))
+	binary.BigEndian.PutUint16(data[16:], uint16(len(a.credentialID)))
+	data = append(data, a.credentialID...)
+	return append(data, encodeTestCBOR(a.coseKey)...)
+}
+
+func (a testAuthenticator) register(challenge string) RegistrationCredential {
+	authData := testAuthenticatorData(flagUserPresent|flagUserVerified|flagAttestedCredData, 0, a.attestedCredentialData())
+	attestation := encodeTestCBOR(map[interface{}]interface{}{
+		"fmt":      attestationNone,
+		"attStmt":  map[interface{}]interface{}{},
+		"authData": authData,
+	})
+
+	return RegistrationCredential{
+		ID:   encodeBase64URL(a.credentialID),
+		Type: publicKeyCredentialType,
+		Response: AttestationResponse{
+			ClientDataJSON:    encodeBase64URL(testClientData(clientDataTypeCreate, challenge, testOrigin)),
+			AttestationObject: encodeBase64URL(attestation),
+		},
+	}
+}
+
+func (a testAuthenticator) assert(challenge string, signCount uint32) AssertionCredential {
+	clientDataJSON := testClientData(clientDataTypeGet, challenge, testOrigin)
+	authData := testAuthenticatorData(flagUserPresent|flagUserVerified, signCount, nil)
+	clientDataHash := sha256.Sum256(clientDataJSON)
+
+	return AssertionCredential{
+		ID:   encodeBase64URL(a.credentialID),
+		Type: publicKeyCredentialType,
+		Response: AssertionResponse{
+			ClientDataJSON:    encodeBase64URL(clientDataJSON),
+			AuthenticatorData: encodeBase64URL(authData),
+			Signature:         encodeBase64URL(a.sign(append(append([]byte(nil), authData...), clientDataHash[:]...))),
+		},
+	}
+}
+
+func registerTestCredential(t *testing.T, a *testAuthenticator) *UserWebauthnCredential {
+	t.Helper()
+	credential, err := VerifyRegistration(a.register("register-challenge"), "register-challenge", 7, "laptop")
+	if err != nil {
+		t.Fatalf("VerifyRegistration() error = %v", err)
+	}
+	return credential
+}
+
+func TestCeremonyRoundTrip(t *testing.T) {
+	setTestRelyingParty(t)
+
+	for _, algorithm := range supportedAlgorithms {
+		a := newTestAuthenticator(t, algorithm)
+		t.Run(big.NewInt(algorithm).String(), func(t *testing.T) {
+			credential := registerTestCredential(t, a)
+			if credential.Algorithm != algorithm || credential.UserAccountID != 7 || credential.CredentialID != encodeBase64URL(a.credentialID) {
+				t.Fatalf("registered %+v, want algorithm %d for account 7", credential, algorithm)
+			}
+
+			signCount, err := credential.VerifyAssertion(a.assert("login-challenge", 1), "login-challenge")
+			if err != nil {
+				t.Fatalf("VerifyAssertion() error = %v", err)
+			}
+			if signCount != 1 {
+				t.Errorf("VerifyAssertion() sign count = %d, want 1", signCount)
+			}
+		})
+	}
+}
+
+func TestVerifyAssertionRejects(t *testing.T) {
+	setTestRelyingParty(t)
+
+	for _, algorithm := range supportedAlgorithms {
+		a := newTestAuthenticator(t, algorithm)
+		other := newTestAuthenticator(t, algorithm)
+		credential := registerTestCredential(t, a)
+
+		tests := []struct {
+			name   string
+			mutate func(in *AssertionCredential)
+			stored uint32
+			want   error
+		}{
+			{"signature of another key", func(in *AssertionCredential) {
+				in.Response.Signature = other.assert("login-challenge", 1).Response.Signature
+			}, 0, ErrInvalidSignature},
+			{"tampered authenticator data", func(in *AssertionCredential) {
+				in.Response.AuthenticatorData = encodeBase64URL(testAuthenticatorData(flagUserPresent|flagUserVerified, 2, nil))
+			}, 0, ErrInvalidSignature},
+			{"truncated signature", func(in *AssertionCredential) {
+				raw, _ := decodeBase64URL(in.Response.Signature)
+				in.Response.Signature = encodeBase64URL(raw[:len(raw)/2])
+			}, 0, ErrInvalidSignature},
+			{"signature that is not base64url", func(in *AssertionCredential) {
+				in.Response.Signature = "not base64url!"
+			}, 0, ErrInvalidSignature},
+			{"other challenge", func(in *AssertionCredential) {
+				*in = a.assert("another-challenge", 1)
+			}, 0, ErrInvalidClientData},
+			{"registration client data", func(in *AssertionCredential) {
+				in.Response.ClientDataJSON = encodeBase64URL(testClientData(clientDataTypeCreate, "login-challenge", testOrigin))
+			}, 0, ErrInvalidClientData},
+			{"foreign origin", func(in *AssertionCredential) {
+				in.Response.ClientDataJSON = encodeBase64URL(testClientData(clientDataTypeGet, "login-challenge", "https://evil.example"))
+			}, 0, ErrInvalidOrigin},
+			{"truncated authenticator data", func(in *AssertionCredential) {
+				in.Response.AuthenticatorData = encodeBase64URL(testAuthenticatorData(flagUserPresent, 1, nil)[:authenticatorDataMinLength-1])
+			}, 0, ErrInvalidAuthenticatorData},
+			{"other credential id", func(in *AssertionCredential) {
+				in.ID = encodeBase64URL([]byte("unknown"))
+			}, 0, ErrCredentialMismatch},
+			{"sign count not increased", func(in *AssertionCredential) {}, 1, ErrSignCountRegressed},
+		}
+
+		for _, tt := range tests {
+			t.Run(big.NewInt(algorithm).String()+"/"+tt.name, func(t *testing.T) {
+				in := a.assert("login-challenge", 1)
+				tt.mutate(&in)
+
+				stored := *credential
+				stored.SignCount = tt.stored
+				if _, err := stored.VerifyAssertion(in, "login-challenge"); !errors.Is(err, tt.want) {
+					t.Errorf("VerifyAssertion() error = %v, want %v", err, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestVerifyRegistrationRejectsMalformedAttestation(t *testing.T) {
+	setTestRelyingParty(t)
+	a := newTestAuthenticator(t, algorithmES256)
+
+	withAttestation := func(attestation []byte) RegistrationCredential {
+		in := a.register("register-challenge")
+		in.Response.AttestationObject = encodeBase64URL(attestation)
+		return in
+	}
+	valid, _ := decodeBase64URL(a.register("register-challenge").Response.AttestationObject)
+
+	tests := []struct {
+		name string
+		in   RegistrationCredential
+		want error
+	}{
+		{"truncated attestation object", withAttestation(valid[:len(valid)-10]), ErrInvalidAuthenticatorData},
+		{"attestation that is not a map", withAttestation(encodeTestCBOR("none")), ErrInvalidAuthenticatorData},
+		{"packed attestation", withAttestation(encodeTestCBOR(map[interface{}]interface{}{
+			"fmt": "packed", "attStmt": map[interface{}]interface{}{}, "authData": []byte{},
+		})), ErrUnsupportedAttestation},
+		{"authenticator data without credential", withAttestation(encodeTestCBOR(map[interface{}]interface{}{
+			"fmt": attestationNone, "attStmt": map[interface{}]interface{}{}, "authData": testAuthenticatorData(flagUserPresent, 0, nil),
+		})), ErrInvalidAuthenticatorData},
+		{"credential id overrunning the data", withAttestation(encodeTestCBOR(map[interface{}]interface{}{
+			"fmt": attestationNone, "attStmt": map[interface{}]interface{}{},
+			"authData": testAuthenticatorData(flagUserPresent|flagAttestedCredData, 0, append(make([]byte, 16), 0x03, 0xff, 0x01)),
+		})), ErrInvalidAuthenticatorData},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := VerifyRegistration(tt.in, "register-challenge", 7, "laptop"); !errors.Is(err, tt.want) {
+				t.Errorf("VerifyRegistration() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCOSEKeyRejects(t *testing.T) {
+	p256 := newTestAuthenticator(t, algorithmES256).coseKey
+	ed := newTestAuthenticator(t, algorithmEdDSA).coseKey
+
+	with := func(key map[interface{}]interface{}, label int64, value interface{}) map[interface{}]interface{} {
+		changed := make(map[interface{}]interface{}, len(key))
+		for k, v := range key {
+			changed[k] = v
+		}
+		changed[label] = value
+		return changed
+	}
+
+	tests := []struct {
+		name string
+		key  map[interface{}]interface{}
+		want error
+	}{
+		{"es256 with okp key type", with(p256, 1, int64(1)), ErrInvalidPublicKey},
+		{"es256 on p-384", with(p256, -1, int64(2)), ErrInvalidPublicKey},
+		{"es256 with short x", with(p256, -2, make([]byte, 31)), ErrInvalidPublicKey},
+		{"es256 point off the curve", with(p256, -3, bytes.Repeat([]byte{1}, 32)), ErrInvalidPublicKey},
+		{"eddsa on x448", with(ed, -1, int64(7)), ErrInvalidPublicKey},
+		{"eddsa with short key", with(ed, -2, make([]byte, 31)), ErrInvalidPublicKey},
+		{"rs256 with 1024 bit modulus", map[interface{}]interface{}{
+			int64(1): int64(3), int64(3): algorithmRS256, int64(-1): make([]byte, 128), int64(-2): []byte{1, 0, 1},
+		}, ErrInvalidPublicKey},
+		{"rs256 without exponent", map[interface{}]interface{}{
+			int64(1): int64(3), int64(3): algorithmRS256, int64(-1): make([]byte, 256),
+		}, ErrInvalidPublicKey},
+		{"es384", with(p256, 3, int64(-35)), ErrUnsupportedAlgorithm},
+		{"missing algorithm", map[interface{}]interface{}{int64(1): int64(2)}, ErrUnsupportedAlgorithm},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := parseCOSEKey(tt.key); !errors.Is(err, tt.want) {
+				t.Errorf("parseCOSEKey() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifySignatureRejectsAlgorithmMismatch(t *testing.T) {
+	a := newTestAuthenticator(t, algorithmEdDSA)
+	publicKey, _, err := parseCOSEKey(a.coseKey)
+	if err != nil {
+		t.Fatalf("parseCOSEKey() error = %v", err)
+	}
+
+	signed := []byte("signed data")
+	if err = verifySignature(publicKey, algorithmEdDSA, signed, a.sign(signed)); err != nil {
+		t.Fatalf("verifySignature() error = %v", err)
+	}
+	// the stored algorithm has to match the key, an ed25519 key never verifies as es256
+	if err = verifySignature(publicKey, algorithmES256, signed, a.sign(signed)); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("verifySignature() error = %v, want ErrInvalidSignature", err)
+	}
+}
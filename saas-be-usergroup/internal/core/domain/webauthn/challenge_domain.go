@@ -0,0 +1,96 @@
+package webauthn
+
+import (
+	"crypto/rand"
+	"strings"
+)
+
+const challengeBytes = 32
+
+// Challenge is the random value a ceremony signs, UUID is empty for a login with a discoverable credential
+type Challenge struct {
+	Challenge string
+	UUID      string
+}
+
+func NewChallenge(challenge string, uuid string) *Challenge {
+	return &Challenge{
+		Challenge: challenge,
+		UUID:      uuid,
+	}
+}
+
+func GenerateChallenge(uuid string) (*Challenge, error) {
+	b := make([]byte, challengeBytes)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return NewChallenge(encodeBase64URL(b), uuid), nil
+}
+
+func (c *Challenge) IsEmpty() bool {
+	return c == nil
+}
+
+func (c Challenge) GetChallenge() string {
+	return c.Challenge
+}
+
+func (c Challenge) GetUUID() string {
+	return c.UUID
+}
+
+// ToCreationOptions lists the existing credentials of the user so the same authenticator is not registered twice
+func (c Challenge) ToCreationOptions(email string, displayName string, credentials []UserWebauthnCredential) *CreationOptionsResponse {
+	params := make([]CredentialParameter, 0, len(supportedAlgorithms))
+	for _, algorithm := range supportedAlgorithms {
+		params = append(params, CredentialParameter{Type: publicKeyCredentialType, Algorithm: algorithm})
+	}
+
+	if strings.TrimSpace(displayName) == "" {
+		displayName = email
+	}
+
+	return &CreationOptionsResponse{
+		PublicKey: CreationOptions{
+			Challenge: c.Challenge,
+			RP: RelyingParty{
+				ID:   GetRPID(),
+				Name: GetRPName(),
+			},
+			User: UserEntity{
+				ID:          encodeBase64URL([]byte(c.UUID)),
+				Name:        email,
+				DisplayName: displayName,
+			},
+			PubKeyCredParams:   params,
+			Timeout:            GetTimeout().Milliseconds(),
+			ExcludeCredentials: toDescriptors(credentials),
+			AuthenticatorSelection: AuthenticatorSelection{
+				ResidentKey:      "preferred",
+				UserVerification: GetUserVerification(),
+			},
+			Attestation: attestationNone,
+		},
+	}
+}
+
+func (c Challenge) ToRequestOptions(credentials []UserWebauthnCredential) *RequestOptionsResponse {
+	return &RequestOptionsResponse{
+		PublicKey: RequestOptions{
+			Challenge:        c.Challenge,
+			RPID:             GetRPID(),
+			Timeout:          GetTimeout().Milliseconds(),
+			AllowCredentials: toDescriptors(credentials),
+			UserVerification: GetUserVerification(),
+		},
+	}
+}
+
+func toDescriptors(credentials []UserWebauthnCredential) []CredentialDescriptor {
+	descriptors := make([]CredentialDescriptor, 0, len(credentials))
+	for _, credential := range credentials {
+		descriptors = append(descriptors, credential.toDescriptor())
+	}
+	return descriptors
+}
@@ -0,0 +1,44 @@
+package webauthn
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+func (u *UserWebauthnCredential) Create(db *gorm.DB) (*UserWebauthnCredential, error) {
+	if err := db.Create(&u).Error; err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+func (u *UserWebauthnCredential) Update(db *gorm.DB) (*UserWebauthnCredential, error) {
+	if err := db.Save(&u).Error; err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+func (u *UserWebauthnCredential) GetOneByCredentialID(db *gorm.DB, credentialID string) (*UserWebauthnCredential, error) {
+	if err := db.Where("credential_id = ?", credentialID).First(&u).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return u, nil
+}
+
+func GetCredentialsByUserAccountID(db *gorm.DB, userAccountID uint64) ([]UserWebauthnCredential, error) {
+	var credentials []UserWebauthnCredential
+	if err := db.Where("user_account_id = ?", userAccountID).Order("id").Find(&credentials).Error; err != nil {
+		return nil, err
+	}
+
+	return credentials, nil
+}
@@ -0,0 +1,73 @@
+package webauthn
+
+import (
+	"context"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func getRegistrationChallengeKey(uuid string) string {
+	return "webauthn-registration-" + uuid
+}
+
+func getLoginChallengeKey(challenge string) string {
+	return "webauthn-login-" + challenge
+}
+
+// CreateRegistration keeps one pending registration per user, beginning again replaces the challenge
+func (c Challenge) CreateRegistration(ctx context.Context, client *redis.Client) (*Challenge, error) {
+	if err := client.SetEX(ctx, getRegistrationChallengeKey(c.UUID), c.Challenge, GetTimeout()).Err(); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// ConsumeRegistration returns the pending registration of the user and deletes it, it can be finished only once
+func ConsumeRegistration(ctx context.Context, client *redis.Client, uuid string) (*Challenge, error) {
+	challenge, ok, err := consume(ctx, client, getRegistrationChallengeKey(uuid))
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	return NewChallenge(challenge, uuid), nil
+}
+
+// CreateLogin is keyed by the challenge itself, the assertion carries it back in its client data
+func (c Challenge) CreateLogin(ctx context.Context, client *redis.Client) (*Challenge, error) {
+	if err := client.SetEX(ctx, getLoginChallengeKey(c.Challenge), c.UUID, GetTimeout()).Err(); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+func ConsumeLogin(ctx context.Context, client *redis.Client, challenge string) (*Challenge, error) {
+	uuid, ok, err := consume(ctx, client, getLoginChallengeKey(challenge))
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	return NewChallenge(challenge, uuid), nil
+}
+
+// consume reads and deletes the key in one transaction, only the request that deleted it gets ok
+func consume(ctx context.Context, client *redis.Client, key string) (string, bool, error) {
+	var (
+		value   *redis.StringCmd
+		deleted *redis.IntCmd
+	)
+	if _, err := client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		value = pipe.Get(ctx, key)
+		deleted = pipe.Del(ctx, key)
+		return nil
+	}); err != nil && err != redis.Nil {
+		return "", false, err
+	}
+
+	if deleted.Val() == 0 {
+		return "", false, nil
+	}
+
+	return value.Val(), true, nil
+}
@@ -0,0 +1,136 @@
+package webauthn
+
+import "time"
+
+// the options and credentials keep the camelCase names of the webauthn json serialization,
+// the browser hands them to navigator.credentials and back without any mapping
+
+type RelyingParty struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type UserEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type      string `json:"type"`
+	Algorithm int64  `json:"alg"`
+}
+
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+type CreationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	RP                     RelyingParty           `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+type CreationOptionsResponse struct {
+	PublicKey CreationOptions `json:"publicKey"`
+}
+
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	RPID             string                 `json:"rpId"`
+	Timeout          int64                  `json:"timeout"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+type RequestOptionsResponse struct {
+	PublicKey RequestOptions `json:"publicKey"`
+}
+
+type AttestationResponse struct {
+	ClientDataJSON    string   `json:"clientDataJSON"`
+	AttestationObject string   `json:"attestationObject"`
+	Transports        []string `json:"transports"`
+}
+
+type RegistrationCredential struct {
+	ID       string              `json:"id"`
+	RawID    string              `json:"rawId"`
+	Type     string              `json:"type"`
+	Response AttestationResponse `json:"response"`
+}
+
+type AssertionResponse struct {
+	ClientDataJSON    string `json:"clientDataJSON"`
+	AuthenticatorData string `json:"authenticatorData"`
+	Signature         string `json:"signature"`
+	UserHandle        string `json:"userHandle"`
+}
+
+type AssertionCredential struct {
+	ID       string            `json:"id"`
+	RawID    string            `json:"rawId"`
+	Type     string            `json:"type"`
+	Response AssertionResponse `json:"response"`
+}
+
+type FinishRegistrationRequest struct {
+	Name       string                 `json:"name"`
+	Credential RegistrationCredential `json:"credential"`
+}
+
+func NewFinishRegistrationRequest() *FinishRegistrationRequest {
+	return &FinishRegistrationRequest{}
+}
+
+func (f FinishRegistrationRequest) GetName() string {
+	return f.Name
+}
+
+func (f FinishRegistrationRequest) GetCredential() RegistrationCredential {
+	return f.Credential
+}
+
+// BeginLoginRequest may leave the email empty, the browser then offers the discoverable credentials it holds
+type BeginLoginRequest struct {
+	Email string `json:"email"`
+}
+
+func NewBeginLoginRequest() *BeginLoginRequest {
+	return &BeginLoginRequest{}
+}
+
+func (b BeginLoginRequest) GetEmail() string {
+	return b.Email
+}
+
+type FinishLoginRequest struct {
+	Credential AssertionCredential `json:"credential"`
+}
+
+func NewFinishLoginRequest() *FinishLoginRequest {
+	return &FinishLoginRequest{}
+}
+
+func (f FinishLoginRequest) GetCredential() AssertionCredential {
+	return f.Credential
+}
+
+type CredentialResponse struct {
+	CredentialID string     `json:"credential_id"`
+	Name         string     `json:"name"`
+	CreatedAt    time.Time  `json:"created_at"`
+	LastUsedAt   *time.Time `json:"last_used_at"`
+}
@@ -0,0 +1,56 @@
+package webauthn
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+func (r RegistrationCredential) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.ID, validation.Required),
+		validation.Field(&r.Type, validation.Required, validation.In(publicKeyCredentialType)),
+		validation.Field(&r.Response, validation.Required),
+	)
+}
+
+func (a AttestationResponse) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.ClientDataJSON, validation.Required),
+		validation.Field(&a.AttestationObject, validation.Required),
+	)
+}
+
+func (a AssertionCredential) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.ID, validation.Required),
+		validation.Field(&a.Type, validation.Required, validation.In(publicKeyCredentialType)),
+		validation.Field(&a.Response, validation.Required),
+	)
+}
+
+func (a AssertionResponse) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.ClientDataJSON, validation.Required),
+		validation.Field(&a.AuthenticatorData, validation.Required),
+		validation.Field(&a.Signature, validation.Required),
+	)
+}
+
+func (f FinishRegistrationRequest) Validate() error {
+	return validation.ValidateStruct(&f,
+		validation.Field(&f.Name, validation.Length(0, 64)),
+		validation.Field(&f.Credential, validation.Required),
+	)
+}
+
+func (b BeginLoginRequest) Validate() error {
+	return validation.ValidateStruct(&b,
+		validation.Field(&b.Email, is.Email),
+	)
+}
+
+func (f FinishLoginRequest) Validate() error {
+	return validation.ValidateStruct(&f,
+		validation.Field(&f.Credential, validation.Required),
+	)
+}
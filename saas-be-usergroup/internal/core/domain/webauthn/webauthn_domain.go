@@ -0,0 +1,128 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	UserVerificationRequired    = "required"
+	UserVerificationPreferred   = "preferred"
+	UserVerificationDiscouraged = "discouraged"
+)
+
+var (
+	defaultRPName           = "saas-be-usergroup"
+	defaultTimeout          = time.Minute * 5
+	defaultUserVerification = UserVerificationPreferred
+)
+
+// GetRPID is the domain the credentials are scoped to, it has to be the origin host or one of its parents
+func GetRPID() string {
+	return viper.GetString("webauthn.rp_id")
+}
+
+func GetRPName() string {
+	if name := viper.GetString("webauthn.rp_name"); name != "" {
+		return name
+	}
+	return defaultRPName
+}
+
+func GetOrigins() []string {
+	return viper.GetStringSlice("webauthn.origins")
+}
+
+func GetTimeout() time.Duration {
+	if timeout := viper.GetDuration("webauthn.timeout"); timeout > 0 {
+		return timeout
+	}
+	return defaultTimeout
+}
+
+func GetUserVerification() string {
+	switch verification := viper.GetString("webauthn.user_verification"); verification {
+	case UserVerificationRequired, UserVerificationPreferred, UserVerificationDiscouraged:
+		return verification
+	}
+	return defaultUserVerification
+}
+
+func isUserVerificationRequired() bool {
+	return GetUserVerification() == UserVerificationRequired
+}
+
+type UserWebauthnCredential struct {
+	ID            uint64
+	UserAccountID uint64
+	CredentialID  string
+	PublicKey     []byte
+	Algorithm     int64
+	SignCount     uint32
+	AAGUID        string
+	Name          string
+	Transports    string
+	LastUsedTs    *time.Time
+	InsertTs      time.Time
+}
+
+func NewUserWebauthnCredential() *UserWebauthnCredential {
+	return &UserWebauthnCredential{}
+}
+
+func (u *UserWebauthnCredential) IsEmpty() bool {
+	return u == nil
+}
+
+func (u UserWebauthnCredential) GetUserAccountID() uint64 {
+	return u.UserAccountID
+}
+
+func (u UserWebauthnCredential) GetCredentialID() string {
+	return u.CredentialID
+}
+
+func (u UserWebauthnCredential) getPublicKey() (crypto.PublicKey, error) {
+	return x509.ParsePKIXPublicKey(u.PublicKey)
+}
+
+// SetUsed stores the counter of the last assertion, it only ever moves forward
+func (u *UserWebauthnCredential) SetUsed(signCount uint32) {
+	now := time.Now()
+	u.SignCount = signCount
+	u.LastUsedTs = &now
+}
+
+func (u UserWebauthnCredential) ToCredentialResponse() *CredentialResponse {
+	return &CredentialResponse{
+		CredentialID: u.CredentialID,
+		Name:         u.Name,
+		CreatedAt:    u.InsertTs,
+		LastUsedAt:   u.LastUsedTs,
+	}
+}
+
+func (u UserWebauthnCredential) toDescriptor() CredentialDescriptor {
+	descriptor := CredentialDescriptor{
+		Type: publicKeyCredentialType,
+		ID:   u.CredentialID,
+	}
+	if u.Transports != "" {
+		descriptor.Transports = strings.Split(u.Transports, ",")
+	}
+	return descriptor
+}
+
+func encodeBase64URL(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeBase64URL takes the unpadded encoding browsers produce and tolerates padding
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
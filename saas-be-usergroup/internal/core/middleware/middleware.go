@@ -2,43 +2,169 @@ package middleware
 
 import (
 	"context"
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
 	"github.com/gofiber/fiber/v2"
-	fiberJwt "github.com/gofiber/jwt/v2"
-	"github.com/golang-jwt/jwt/v4"
+	"github.com/saas-be-usergroup/internal/core/domain/auth"
 	responseErr "github.com/saas-be-usergroup/internal/error"
 	"github.com/spf13/viper"
 )
 
-func Protected() func(*fiber.Ctx) error {
-	return fiberJwt.New(fiberJwt.Config{
-		SigningKey:   []byte(viper.GetString("jwt.access_secret")),
-		ErrorHandler: jwtError})
+var (
+	ErrMissingJWT        = errors.New("Missing or malformed JWT")
+	ErrNotAdmin          = errors.New("admin access required")
+	ErrInsufficientScope = errors.New("token does not grant the required scope")
+)
+
+// Protected accepts only a valid access token of our own logins that has not been revoked,
+// the claims are stored in the "user" local
+func Protected(client *redis.Client) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		claims, err := verifyAccessToken(c, client)
+		if err != nil {
+			return accessTokenError(c, err)
+		}
+
+		// a third-party token only reaches the routes of its scopes
+		if claims.IsClientToken() {
+			return jwtError(c, auth.ErrInvalidToken)
+		}
+
+		c.Locals("user", claims)
+		return c.Next()
+	}
+}
+
+// OAuthProtected accepts only a token issued to a third-party client for a user with the given scope
+func OAuthProtected(client *redis.Client, scope string) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		claims, err := verifyAccessToken(c, client)
+		if err != nil {
+			return accessTokenError(c, err)
+		}
+
+		if !claims.IsClientToken() || claims.GetUUID() == "" {
+			return jwtError(c, auth.ErrInvalidToken)
+		}
+
+		if !hasScope(claims.GetScope(), scope) {
+			c.Set(fiber.HeaderWWWAuthenticate, `Bearer error="insufficient_scope", scope="`+scope+`"`)
+			return responseErr.Response(c, responseErr.New(fiber.StatusForbidden, responseErr.WithMessage(ErrInsufficientScope.Error())))
+		}
+
+		c.Locals("user", claims)
+		return c.Next()
+	}
+}
+
+var errRevocationUnavailable = errors.New("revocation list unavailable")
+
+func verifyAccessToken(c *fiber.Ctx, client *redis.Client) (*auth.JWTClaims, error) {
+	token, err := jwtFromHeader(c)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := auth.ParseAccessToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	// redis being unavailable must not let a revoked token through
+	revoked, err := auth.IsAccessTokenRevoked(c.Context(), client, claims)
+	if err != nil {
+		return nil, errRevocationUnavailable
+	}
+	if revoked {
+		return nil, auth.ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+func accessTokenError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, errRevocationUnavailable) {
+		return responseErr.Response(c, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error())))
+	}
+	return jwtError(c, err)
+}
+
+func hasScope(granted string, scope string) bool {
+	for _, s := range strings.Fields(granted) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func jwtFromHeader(c *fiber.Ctx) (string, error) {
+	header := c.Get(fiber.HeaderAuthorization)
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return header[7:], nil
+	}
+	return "", ErrMissingJWT
 }
 
 func jwtError(c *fiber.Ctx, err error) error {
-	if err.Error() == "Missing or malformed JWT" {
+	if errors.Is(err, ErrMissingJWT) {
 		c.Status(fiber.StatusBadRequest)
 		return responseErr.Response(c, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(err.Error())))
 	} else {
 		c.Status(fiber.StatusUnauthorized)
-		return responseErr.Response(c, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(err.Error())))
+		return responseErr.Response(c, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage("Invalid or expired JWT")))
+	}
+}
+
+// AdminProtected accepts only requests carrying admin.api_key in the X-Admin-Key header,
+// every request is rejected while the key is not configured
+func AdminProtected() func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		apiKey := viper.GetString("admin.api_key")
+		if apiKey == "" || subtle.ConstantTimeCompare([]byte(c.Get("X-Admin-Key")), []byte(apiKey)) != 1 {
+			return responseErr.Response(c, responseErr.New(fiber.StatusForbidden, responseErr.WithMessage(ErrNotAdmin.Error())))
+		}
+		return c.Next()
 	}
 }
 
 type JWTData struct {
-	UUID string
+	UUID      string
+	JTI       string
+	SessionID string
+	ExpiresAt time.Time
 }
 
-func NewJWTData(uuid string) *JWTData {
-	return &JWTData{UUID: uuid}
+func NewJWTData(uuid string, jti string, sessionID string, expiresAt time.Time) *JWTData {
+	return &JWTData{UUID: uuid, JTI: jti, SessionID: sessionID, ExpiresAt: expiresAt}
 }
 
 func (j JWTData) GetUUID() string {
 	return j.UUID
 }
 
+func (j JWTData) GetJTI() string {
+	return j.JTI
+}
+
+func (j JWTData) GetSessionID() string {
+	return j.SessionID
+}
+
+func (j JWTData) GetExpiresAt() time.Time {
+	return j.ExpiresAt
+}
+
 func ExportData(c context.Context) *JWTData {
-	token := c.Value("user").(*jwt.Token)
-	claims := token.Claims.(jwt.MapClaims)
-	return NewJWTData(claims["uuid"].(string))
+	claims := c.Value("user").(*auth.JWTClaims)
+	return NewJWTData(claims.GetUUID(), claims.GetJTI(), claims.GetSessionID(), claims.GetExpiresAt())
+}
+
+// ExportScope returns the scopes granted to the client of a token accepted by OAuthProtected
+func ExportScope(c context.Context) string {
+	return c.Value("user").(*auth.JWTClaims).GetScope()
 }
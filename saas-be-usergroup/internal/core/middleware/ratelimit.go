@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/saas-be-usergroup/internal/core/domain/auth"
+	"github.com/saas-be-usergroup/internal/core/domain/ratelimit"
+	responseErr "github.com/saas-be-usergroup/internal/error"
+	"go.uber.org/zap"
+)
+
+var ErrTooManyRequests = errors.New("too many requests, please try again later")
+
+const CodeRateLimited = "RATE_LIMITED"
+
+// KeyFunc picks the bucket of the request, an empty key skips the limiter
+type KeyFunc func(c *fiber.Ctx) string
+
+func KeyByIP(c *fiber.Ctx) string {
+	return "ip-" + c.IP()
+}
+
+// KeyByUser needs Protected in front of it
+func KeyByUser(c *fiber.Ctx) string {
+	claims, ok := c.Locals("user").(*auth.JWTClaims)
+	if !ok {
+		return KeyByIP(c)
+	}
+	return "user-" + claims.GetUUID()
+}
+
+// KeyByEmail reads the email of the json body, a request without one falls back to the ip
+func KeyByEmail(c *fiber.Ctx) string {
+	var body struct {
+		Email string `json:"email"`
+	}
+	if err := json.Unmarshal(c.Body(), &body); err != nil || body.Email == "" {
+		return KeyByIP(c)
+	}
+	return "email-" + strings.ToLower(strings.TrimSpace(body.Email))
+}
+
+// RateLimit answers 429 once the key has spent the policy, every response carries the RateLimit-* headers
+// of the policy with the fewest requests left.
+// A redis failure is logged and lets the request through, the limiter should not take the api down with it
+func RateLimit(client *redis.Client, logger *zap.Logger, policy *ratelimit.Policy, keyFunc KeyFunc) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		if !ratelimit.IsEnabled() {
+			return c.Next()
+		}
+
+		key := keyFunc(c)
+		if key == "" {
+			return c.Next()
+		}
+
+		result, err := policy.Allow(c.Context(), client, key)
+		if err != nil {
+			logger.Error("failed to check rate limit on redis, request let through : ", zap.String("policy", policy.GetName()), zap.Error(err))
+			return c.Next()
+		}
+
+		// a route under several policies reports the one closest to its limit, a denying policy always wins
+		current, err := strconv.ParseInt(c.GetRespHeader("RateLimit-Remaining"), 10, 64)
+		if err != nil || result.GetRemaining() < current || !result.IsAllowed() {
+			c.Set("RateLimit-Limit", strconv.FormatInt(result.GetLimit(), 10))
+			c.Set("RateLimit-Remaining", strconv.FormatInt(result.GetRemaining(), 10))
+			c.Set("RateLimit-Reset", strconv.FormatInt(int64(math.Ceil(result.GetResetAfter().Seconds())), 10))
+		}
+
+		if !result.IsAllowed() {
+			retryAfter := int64(math.Ceil(result.GetRetryAfter().Seconds()))
+			c.Set(fiber.HeaderRetryAfter, strconv.FormatInt(retryAfter, 10))
+			return responseErr.Response(c, responseErr.New(fiber.StatusTooManyRequests, responseErr.WithCode(CodeRateLimited), responseErr.WithMessage(ErrTooManyRequests.Error()), responseErr.WithMeta(map[string]interface{}{
+				"retry_after": retryAfter,
+			})))
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,175 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/saas-be-usergroup/internal/core/domain/ratelimit"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// newLimitedApp serves GET / behind the given policies in order
+func newLimitedApp(client *redis.Client, policies ...*ratelimit.Policy) *fiber.App {
+	app := fiber.New()
+	handlers := make([]fiber.Handler, 0, len(policies)+1)
+	for _, policy := range policies {
+		handlers = append(handlers, RateLimit(client, zap.NewNop(), policy, KeyByIP))
+	}
+	handlers = append(handlers, func(c *fiber.Ctx) error {
+		return c.SendString("OK")
+	})
+	app.Get("/", handlers...)
+	return app
+}
+
+func doRequest(t *testing.T, app *fiber.App) (int, map[string]string, string) {
+	t.Helper()
+
+	res, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+
+	headers := map[string]string{}
+	for _, name := range []string{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", fiber.HeaderRetryAfter} {
+		headers[name] = res.Header.Get(name)
+	}
+	return res.StatusCode, headers, string(body)
+}
+
+func TestRateLimitAllowsWithinThePolicy(t *testing.T) {
+	_, client := newFakeRedis(t)
+	app := newLimitedApp(client, &ratelimit.Policy{Name: "test", Limit: 2, Period: time.Minute})
+
+	for _, wantRemaining := range []string{"1", "0"} {
+		status, headers, body := doRequest(t, app)
+		if status != fiber.StatusOK || body != "OK" {
+			t.Fatalf("status %d body %q, want 200 OK", status, body)
+		}
+		if headers["RateLimit-Limit"] != "2" || headers["RateLimit-Remaining"] != wantRemaining {
+			t.Errorf("limit %q remaining %q, want 2 and %s", headers["RateLimit-Limit"], headers["RateLimit-Remaining"], wantRemaining)
+		}
+		if reset, err := strconv.Atoi(headers["RateLimit-Reset"]); err != nil || reset <= 0 || reset > 60 {
+			t.Errorf("reset %q, want seconds within the period", headers["RateLimit-Reset"])
+		}
+		if headers[fiber.HeaderRetryAfter] != "" {
+			t.Errorf("retry after %q on an allowed request", headers[fiber.HeaderRetryAfter])
+		}
+	}
+}
+
+func TestRateLimitDeniesWithRetryAfter(t *testing.T) {
+	_, client := newFakeRedis(t)
+	app := newLimitedApp(client, &ratelimit.Policy{Name: "test", Limit: 1, Period: time.Minute})
+
+	doRequest(t, app)
+	status, headers, body := doRequest(t, app)
+	if status != fiber.StatusTooManyRequests {
+		t.Fatalf("status %d, want 429", status)
+	}
+	if headers["RateLimit-Remaining"] != "0" {
+		t.Errorf("remaining %q, want 0", headers["RateLimit-Remaining"])
+	}
+
+	retryAfter, err := strconv.Atoi(headers[fiber.HeaderRetryAfter])
+	if err != nil || retryAfter <= 0 || retryAfter > 60 {
+		t.Fatalf("retry after %q, want seconds within the period", headers[fiber.HeaderRetryAfter])
+	}
+
+	var reply struct {
+		Code string `json:"code"`
+		Meta struct {
+			RetryAfter int `json:"retry_after"`
+		} `json:"meta"`
+	}
+	if err = json.Unmarshal([]byte(body), &reply); err != nil {
+		t.Fatalf("decode body %q: %v", body, err)
+	}
+	if reply.Code != CodeRateLimited || reply.Meta.RetryAfter != retryAfter {
+		t.Errorf("code %q retry_after %d, want %s and %d", reply.Code, reply.Meta.RetryAfter, CodeRateLimited, retryAfter)
+	}
+}
+
+func TestRateLimitReportsThePolicyClosestToItsLimit(t *testing.T) {
+	global := &ratelimit.Policy{Name: "global", Limit: 300, Period: time.Minute}
+	route := &ratelimit.Policy{Name: "route", Limit: 5, Period: time.Minute}
+
+	tests := []struct {
+		name     string
+		policies []*ratelimit.Policy
+	}{
+		{name: "route after global", policies: []*ratelimit.Policy{global, route}},
+		{name: "route before global", policies: []*ratelimit.Policy{route, global}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client := newFakeRedis(t)
+			app := newLimitedApp(client, tt.policies...)
+
+			_, headers, _ := doRequest(t, app)
+			if headers["RateLimit-Limit"] != "5" || headers["RateLimit-Remaining"] != "4" {
+				t.Errorf("limit %q remaining %q, want the route policy 5 and 4", headers["RateLimit-Limit"], headers["RateLimit-Remaining"])
+			}
+		})
+	}
+}
+
+func TestRateLimitLetsThroughWithoutRedis(t *testing.T) {
+	fake, client := newFakeRedis(t)
+	fake.listener.Close()
+	app := newLimitedApp(client, &ratelimit.Policy{Name: "test", Limit: 1, Period: time.Minute})
+
+	for i := 0; i < 2; i++ {
+		status, headers, _ := doRequest(t, app)
+		if status != fiber.StatusOK {
+			t.Fatalf("status %d, want 200 while redis is down", status)
+		}
+		if headers["RateLimit-Limit"] != "" {
+			t.Errorf("limit header %q, want none without a result", headers["RateLimit-Limit"])
+		}
+	}
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	viper.Set("ratelimit.enabled", false)
+	t.Cleanup(func() { viper.Set("ratelimit.enabled", nil) })
+
+	_, client := newFakeRedis(t)
+	app := newLimitedApp(client, &ratelimit.Policy{Name: "test", Limit: 1, Period: time.Minute})
+
+	for i := 0; i < 3; i++ {
+		if status, _, _ := doRequest(t, app); status != fiber.StatusOK {
+			t.Fatalf("status %d, want 200 with the limiter off", status)
+		}
+	}
+}
+
+func TestRateLimitSkipsAnEmptyKey(t *testing.T) {
+	_, client := newFakeRedis(t)
+	app := fiber.New()
+	app.Get("/", RateLimit(client, zap.NewNop(), &ratelimit.Policy{Name: "test", Limit: 1, Period: time.Minute}, func(c *fiber.Ctx) string {
+		return ""
+	}), func(c *fiber.Ctx) error {
+		return c.SendString("OK")
+	})
+
+	for i := 0; i < 3; i++ {
+		if status, _, _ := doRequest(t, app); status != fiber.StatusOK {
+			t.Fatalf("status %d, want 200 without a key", status)
+		}
+	}
+}
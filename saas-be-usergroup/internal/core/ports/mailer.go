@@ -0,0 +1,12 @@
+package ports
+
+import (
+	"context"
+	"github.com/saas-be-usergroup/internal/core/domain/mailer"
+)
+
+type (
+	Mailer interface {
+		Send(ctx context.Context, m mailer.Mailer) error
+	}
+)
@@ -4,17 +4,44 @@ import (
 	"context"
 	"github.com/saas-be-usergroup/internal/core/domain/auth"
 	"github.com/saas-be-usergroup/internal/core/domain/group"
+	"github.com/saas-be-usergroup/internal/core/domain/mfa"
+	"github.com/saas-be-usergroup/internal/core/domain/oauth"
+	"github.com/saas-be-usergroup/internal/core/domain/oidc"
 	"github.com/saas-be-usergroup/internal/core/domain/user"
+	"github.com/saas-be-usergroup/internal/core/domain/webauthn"
+	"time"
 )
 
 type (
 	AuthService interface {
 		RegisterBeforeWithEmail(ctx context.Context, in auth.RegisterBeforeWithEmail) (*auth.RegisterBeforeResponse, error)
+		ResendRegisterOTP(ctx context.Context, in auth.ResendRegisterOTPRequest) (*auth.RegisterBeforeResponse, error)
 		ConfirmationRegister(ctx context.Context, in auth.ConfirmationRegister) (*auth.ConfirmationResponse, error)
-		DoRegister(ctx context.Context, in auth.DoRegisterRequest) (*auth.DoRegisterResponse, error)
-		DoLogin(ctx context.Context, in auth.DoLoginRequest) (*auth.DoLoginResponse, error)
-		DoRefreshToken(ctx context.Context, in auth.DoRefreshTokenRequest) (*auth.DoRefreshTokenResponse, error)
-		DoLogout(ctx context.Context, in auth.DoLogoutRequest) error
+		DoRegister(ctx context.Context, in auth.DoRegisterRequest, meta auth.SessionMeta) (*auth.DoRegisterResponse, error)
+		DoLogin(ctx context.Context, in auth.DoLoginRequest, meta auth.SessionMeta) (*auth.DoLoginResponse, error)
+		DoLoginMFA(ctx context.Context, in auth.DoLoginMFARequest, meta auth.SessionMeta) (*auth.DoLoginResponse, error)
+		RequestMagicLink(ctx context.Context, in auth.MagicLinkRequest) (*auth.MagicLinkResponse, error)
+		VerifyMagicLink(ctx context.Context, in auth.VerifyMagicLinkRequest, meta auth.SessionMeta) (*auth.DoLoginResponse, error)
+		DoRefreshToken(ctx context.Context, in auth.DoRefreshTokenRequest, meta auth.SessionMeta) (*auth.DoRefreshTokenResponse, error)
+		DoLogout(ctx context.Context, in auth.DoLogoutRequest, userAccountUUID string, accessTokenJTI string, accessTokenExpiresAt time.Time) error
+		ForgotPassword(ctx context.Context, in auth.ForgotPasswordRequest) error
+		ResetPassword(ctx context.Context, in auth.ResetPasswordRequest) error
+		GetJWKS(ctx context.Context) (*auth.JWKS, error)
+		GetSessions(ctx context.Context, userAccountUUID string, currentSessionID string) ([]auth.DeviceSessionResponse, error)
+		RevokeSession(ctx context.Context, in auth.RevokeSessionRequest, userAccountUUID string) error
+		RevokeAllSessions(ctx context.Context, userAccountUUID string) error
+		UnlockAccount(ctx context.Context, in auth.UnlockAccountRequest) error
+		SuspendAccount(ctx context.Context, in auth.AccountStatusRequest) error
+		ReactivateAccount(ctx context.Context, in auth.AccountStatusRequest) error
+		DeleteAccount(ctx context.Context, in auth.AccountStatusRequest) error
+		BeginWebAuthnRegistration(ctx context.Context, userAccountUUID string) (*webauthn.CreationOptionsResponse, error)
+		FinishWebAuthnRegistration(ctx context.Context, in webauthn.FinishRegistrationRequest, userAccountUUID string) (*webauthn.CredentialResponse, error)
+		BeginWebAuthnLogin(ctx context.Context, in webauthn.BeginLoginRequest) (*webauthn.RequestOptionsResponse, error)
+		FinishWebAuthnLogin(ctx context.Context, in webauthn.FinishLoginRequest, meta auth.SessionMeta) (*auth.DoLoginResponse, error)
+		BeginOIDCLogin(ctx context.Context, in oidc.BeginRequest) (*oidc.BeginResponse, error)
+		FinishOIDCLogin(ctx context.Context, in oidc.CallbackRequest, meta auth.SessionMeta) (*auth.DoLoginResponse, error)
+		BeginOIDCLink(ctx context.Context, in oidc.BeginRequest, userAccountUUID string) (*oidc.BeginResponse, error)
+		FinishOIDCLink(ctx context.Context, in oidc.CallbackRequest, userAccountUUID string) error
 	}
 
 	GroupService interface {
@@ -23,14 +50,31 @@ type (
 		RemoveGroupMember(ctx context.Context, in group.RemoveGroupMemberRequest, adminUUID string) error
 	}
 
+	MFAService interface {
+		EnrollTOTP(ctx context.Context, userAccountUUID string) (*mfa.EnrollResponse, error)
+		VerifyTOTP(ctx context.Context, in mfa.VerifyTOTPRequest, userAccountUUID string) (*mfa.RecoveryCodesResponse, error)
+		DisableTOTP(ctx context.Context, in mfa.DisableTOTPRequest, userAccountUUID string) error
+	}
+
+	OAuthService interface {
+		RegisterClient(ctx context.Context, in oauth.RegisterClientRequest) (*oauth.RegisterClientResponse, error)
+		Authorize(ctx context.Context, in oauth.AuthorizationRequest, userAccountUUID string) (*oauth.ConsentResponse, error)
+		Decide(ctx context.Context, in oauth.DecisionRequest, userAccountUUID string) (*oauth.DecisionResponse, error)
+		Token(ctx context.Context, in oauth.TokenRequest) (*oauth.TokenResponse, error)
+		Introspect(ctx context.Context, in oauth.TokenIntrospectionRequest) (*oauth.IntrospectionResponse, error)
+		Revoke(ctx context.Context, in oauth.TokenIntrospectionRequest) error
+		UserInfo(ctx context.Context, userAccountUUID string, scope string) (*oauth.UserInfoResponse, error)
+	}
+
 	UserService interface {
 		IsEmailAvailable(ctx context.Context, in user.IsEmailAvailableRequest) (*user.AvailableResponse, error)
 		IsUsernameAvailable(ctx context.Context, in user.IsUsernameAvailableRequest) (*user.AvailableResponse, error)
-		ChangeEmailBefore(ctx context.Context, in user.ChangeEmailBeforeRequest, userAccountUUID string) error
+		ChangeEmailBefore(ctx context.Context, in user.ChangeEmailBeforeRequest, userAccountUUID string) (*user.ChangeEmailBeforeResponse, error)
 		ChangeEmailConfirmation(ctx context.Context, in user.ChangeEmailConfirmationRequest, userAccountUUID string) error
 		ChangePasswordRequest(ctx context.Context, userAccountUUID string) error
-		ChangePasswordConfirmation(ctx context.Context, in user.ChangePasswordConfirmationRequest, userAccountUUID string) error
+		ChangePasswordConfirmation(ctx context.Context, in user.ChangePasswordConfirmationRequest, userAccountUUID string) (*user.ChangePasswordConfirmationResponse, error)
 		DoChangePassword(ctx context.Context, in user.DoChangePasswordRequest, userAccountUUID string) error
+		GetUser(ctx context.Context, userAccountUUID string) (*user.Transformer, error)
 		UpdateUser(ctx context.Context, in user.UpdateRequest, userAccountUUID string) (*user.UpdateResponse, error)
 	}
 )
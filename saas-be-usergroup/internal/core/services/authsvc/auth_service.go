@@ -6,23 +6,60 @@ import (
 	"fmt"
 	"github.com/go-redis/redis/v8"
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"github.com/saas-be-usergroup/internal/core/domain/auth"
 	"github.com/saas-be-usergroup/internal/core/domain/mailer"
+	"github.com/saas-be-usergroup/internal/core/domain/mfa"
+	"github.com/saas-be-usergroup/internal/core/domain/oidc"
 	"github.com/saas-be-usergroup/internal/core/domain/user"
+	"github.com/saas-be-usergroup/internal/core/domain/webauthn"
 	"github.com/saas-be-usergroup/internal/core/ports"
+	"github.com/saas-be-usergroup/internal/core/utils/passwordpolicy"
 	responseErr "github.com/saas-be-usergroup/internal/error"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
+	"net/http"
+	"time"
 )
 
 var (
-	EmailAlreadyTaken    = errors.New("email has been already taken")
-	NoCredentialsFound   = errors.New("no credentials found")
-	OTPNotFound          = errors.New("otp not found")
-	InvalidOTP           = errors.New("invalid otp")
-	UsernameNotAvailable = errors.New("username not available")
-	InvalidSession       = errors.New("invalid session")
-	InvalidPassword      = errors.New("invalid password")
+	EmailAlreadyTaken     = errors.New("email has been already taken")
+	NoCredentialsFound    = errors.New("no credentials found")
+	OTPNotFound           = errors.New("otp not found")
+	InvalidOTP            = errors.New("invalid otp")
+	UsernameNotAvailable  = errors.New("username not available")
+	InvalidSession        = errors.New("invalid session")
+	InvalidPassword       = errors.New("invalid password")
+	RegistrationConfirmed = errors.New("registration has been already confirmed")
+	OTPResendTooSoon      = errors.New("please wait before requesting a new otp")
+	OTPResendLimitReached = errors.New("too many otp requested, please try again tomorrow")
+	SessionNotFound       = errors.New("session not found")
+	LoginThrottled        = errors.New("too many failed login attempts, please try again later")
+	AccountLocked         = errors.New("account is temporarily locked after too many failed login attempts")
+	AccountNotFound       = errors.New("account not found")
+	AccountStatusConflict = errors.New("account status does not allow this change")
+	InvalidMFAChallenge   = errors.New("invalid or expired mfa challenge")
+	InvalidMFACode        = errors.New("invalid code")
+	InvalidPasskey        = errors.New("invalid passkey")
+	PasskeyNotVerified    = errors.New("passkey could not be verified")
+	PasskeyChallenge      = errors.New("invalid or expired passkey challenge")
+	PasskeyRegistered     = errors.New("passkey has been already registered")
+	ProviderNotFound      = errors.New("identity provider not found")
+	ProviderUnavailable   = errors.New("identity provider is unavailable, please try again later")
+	InvalidOIDCState      = errors.New("invalid or expired login state")
+	InvalidOIDCLogin      = errors.New("identity provider login could not be verified")
+	OIDCEmailNotVerified  = errors.New("identity provider did not verify the email of the account")
+	OIDCLinkRequired      = errors.New("an account with this email exists already, login and link the identity provider from the account settings")
+	OIDCIdentityLinked    = errors.New("identity provider account is linked to another account")
+	InvalidMagicLink      = errors.New("invalid or expired login link")
+)
+
+// codes returned in AppError so the frontend can tell a throttled login from a locked account
+// and a social login that has to be linked from the account settings
+const (
+	CodeLoginThrottled   = "LOGIN_THROTTLED"
+	CodeAccountLocked    = "ACCOUNT_LOCKED"
+	CodeOIDCLinkRequired = "OIDC_LINK_REQUIRED"
 )
 
 var (
@@ -32,16 +69,20 @@ var (
 )
 
 type authService struct {
-	db     *gorm.DB
-	redis  *redis.Client
-	logger *zap.Logger
+	db         *gorm.DB
+	redis      *redis.Client
+	mailer     ports.Mailer
+	logger     *zap.Logger
+	httpClient *http.Client
 }
 
-func NewAuthService(db *gorm.DB, redis *redis.Client, logger *zap.Logger) ports.AuthService {
+func NewAuthService(db *gorm.DB, redis *redis.Client, mailer ports.Mailer, logger *zap.Logger) ports.AuthService {
 	return &authService{
-		db:     db,
-		redis:  redis,
-		logger: logger,
+		db:         db,
+		redis:      redis,
+		mailer:     mailer,
+		logger:     logger,
+		httpClient: oidc.NewHTTPClient(),
 	}
 }
 
@@ -53,31 +94,124 @@ func (a authService) RegisterBeforeWithEmail(ctx context.Context, in auth.Regist
 		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
 	}
 
-	// check if user is not empty and status is verified
-	if !user.IsEmpty() && user.IsVerified() {
+	// only a pending sign-up can register the email again, it keeps its row and uuid
+	if !user.IsEmpty() && !user.IsPending() {
 		return nil, responseErr.New(fiber.StatusUnprocessableEntity, responseErr.WithMessage(EmailAlreadyTaken.Error()))
 	}
 
-	// create or update user
-	user, err = in.ToUser().Create(a.db)
+	// generate otp
+	newOTP, err := auth.GenerateNewOTP()
 	if err != nil {
-		a.logger.Error("failed to create user : ", zap.Error(err))
+		a.logger.Error("failed to generate otp : ", zap.Error(err))
 		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
 	}
 
-	// create otp
-	otp, err := auth.NewOTP(user.GetUUID(), auth.GenerateNewOTP()).Create(ctx, a.redis, 180)
+	// the user row and its otp mail are committed together so a rollback never leaves a mail behind
+	var otp *auth.OTP
+	if err = a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// create or restart user
+		if user.IsEmpty() {
+			user, err = in.ToUser().Create(tx)
+			if err != nil {
+				a.logger.Error("failed to create user : ", zap.Error(err))
+				return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+			}
+		} else if !user.IsNew() {
+			if err = user.Restart(); err != nil {
+				return responseErr.New(fiber.StatusUnprocessableEntity, responseErr.WithMessage(EmailAlreadyTaken.Error()))
+			}
+			if _, err = user.Update(tx); err != nil {
+				a.logger.Error("failed to update user : ", zap.Error(err))
+				return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+			}
+		}
+
+		// create otp
+		otp, err = auth.NewOTP(user.GetUUID(), newOTP).Create(ctx, a.redis, auth.GetOTPExpiredInSecond())
+		if err != nil {
+			a.logger.Error("failed to set otp on redis : ", zap.Error(err))
+			return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+		}
+
+		// send otp to email
+		if err = a.mailer.Send(mailer.WithTx(ctx, tx), *mailer.NewRegisterOTPMailer(user.GetEmail(), otp.ToRegisterMail())); err != nil {
+			a.logger.Error("failed to queue email : ", zap.Error(err))
+			return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	// start resend cooldown
+	if _, _, err = auth.AcquireOTPResendCooldown(ctx, a.redis, auth.OTPRegister, user.GetUUID()); err != nil {
+		a.logger.Error("failed to set otp resend cooldown on redis : ", zap.Error(err))
+	}
+
+	return otp.ToRegisterResponse(), nil
+}
+
+func (a authService) ResendRegisterOTP(ctx context.Context, in auth.ResendRegisterOTPRequest) (*auth.RegisterBeforeResponse, error) {
+	// check user by uuid
+	userAccount, err := user.NewUser().GetOneByUUID(a.db, in.GetUUID())
+	if err != nil {
+		a.logger.Error("failed to get user by uuid : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if userAccount.IsEmpty() {
+		return nil, responseErr.New(fiber.StatusNotFound, responseErr.WithMessage(NoCredentialsFound.Error()))
+	}
+
+	// otp is only needed before the registration is confirmed
+	if !userAccount.IsNew() {
+		return nil, responseErr.New(fiber.StatusUnprocessableEntity, responseErr.WithMessage(RegistrationConfirmed.Error()))
+	}
+
+	// check cooldown
+	acquired, remaining, err := auth.AcquireOTPResendCooldown(ctx, a.redis, auth.OTPRegister, userAccount.GetUUID())
+	if err != nil {
+		a.logger.Error("failed to set otp resend cooldown on redis : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if !acquired {
+		return nil, responseErr.New(fiber.StatusTooManyRequests, responseErr.WithMessage(OTPResendTooSoon.Error()), responseErr.WithMeta(map[string]interface{}{
+			"retry_after": int64(remaining.Seconds()),
+		}))
+	}
+
+	// check daily cap
+	total, err := auth.IncrementOTPResendDaily(ctx, a.redis, auth.OTPRegister, userAccount.GetUUID(), userAccount.GetEmail())
+	if err != nil {
+		a.logger.Error("failed to increment otp resend counter on redis : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if total > auth.GetOTPResendDailyCap() {
+		return nil, responseErr.New(fiber.StatusTooManyRequests, responseErr.WithMessage(OTPResendLimitReached.Error()))
+	}
+
+	// generate otp
+	newOTP, err := auth.GenerateNewOTP()
+	if err != nil {
+		a.logger.Error("failed to generate otp : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	// replace otp, the previous one is no longer valid
+	otp, err := auth.NewOTP(userAccount.GetUUID(), newOTP).Create(ctx, a.redis, auth.GetOTPExpiredInSecond())
 	if err != nil {
 		a.logger.Error("failed to set otp on redis : ", zap.Error(err))
 		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
 	}
 
 	// send otp to email
-	go func() {
-		if err := mailer.Send(ctx, *mailer.NewRegisterOTPMailer(user.GetEmail(), otp.ToRegisterMail())); err != nil {
-			a.logger.Error("failed to send email : ", zap.Error(err))
-		}
-	}()
+	if err = a.mailer.Send(ctx, *mailer.NewRegisterOTPMailer(userAccount.GetEmail(), otp.ToRegisterMail())); err != nil {
+		a.logger.Error("failed to queue email : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
 
 	return otp.ToRegisterResponse(), nil
 }
@@ -95,6 +229,11 @@ func (a authService) ConfirmationRegister(ctx context.Context, in auth.Confirmat
 		return nil, responseErr.New(fiber.StatusNotFound, responseErr.WithMessage(NoCredentialsFound.Error()))
 	}
 
+	// the otp step is only passed once
+	if !user.IsNew() {
+		return nil, responseErr.New(fiber.StatusUnprocessableEntity, responseErr.WithMessage(RegistrationConfirmed.Error()))
+	}
+
 	// check otp
 	otp, err := auth.GetOTPByUUID(ctx, a.redis, in.GetUUID())
 	if err != nil {
@@ -107,29 +246,37 @@ func (a authService) ConfirmationRegister(ctx context.Context, in auth.Confirmat
 		return nil, responseErr.New(fiber.StatusNotFound, responseErr.WithMessage(OTPNotFound.Error()))
 	}
 
-	// check invalid otp
-	if !otp.IsValid(in.GetOTP()) {
-		return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(InvalidOTP.Error()))
-	}
-
-	// generate session token
-	sessionToken, err := auth.GenerateSessionToken(ctx, a.redis, user.GetUUID(), 1800)
+	// check invalid otp, a valid otp is consumed
+	isValid, err := otp.Verify(ctx, a.redis, in.GetOTP())
 	if err != nil {
-		a.logger.Error("failed to create session token : ", zap.Error(err))
+		a.logger.Error("failed to verify otp : ", zap.Error(err))
 		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
 	}
 
+	if !isValid {
+		return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(InvalidOTP.Error()))
+	}
+
 	// update user status
-	user.SetStatusConfirmed()
+	if err = user.Confirm(); err != nil {
+		return nil, responseErr.New(fiber.StatusUnprocessableEntity, responseErr.WithMessage(RegistrationConfirmed.Error()))
+	}
 	if _, err = user.Update(a.db); err != nil {
 		a.logger.Error("failed to update user : ", zap.Error(err))
 		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
 	}
 
+	// generate session token
+	sessionToken, err := auth.GenerateSessionToken(ctx, a.redis, user.GetUUID(), 1800)
+	if err != nil {
+		a.logger.Error("failed to create session token : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
 	return sessionToken.ToConfirmationResponse(), nil
 }
 
-func (a authService) DoRegister(ctx context.Context, in auth.DoRegisterRequest) (*auth.DoRegisterResponse, error) {
+func (a authService) DoRegister(ctx context.Context, in auth.DoRegisterRequest, meta auth.SessionMeta) (*auth.DoRegisterResponse, error) {
 	// check username
 	isAvailable, err := user.NewUser().IsUsernameAvailable(a.db, in.GetUsername())
 	if err != nil {
@@ -142,14 +289,14 @@ func (a authService) DoRegister(ctx context.Context, in auth.DoRegisterRequest)
 	}
 
 	// check session token
-	session, err := auth.ValidateSession(ctx, a.redis, in.GetSessionToken())
+	session, err := auth.ValidateRegisterSession(ctx, a.redis, in.GetSessionToken())
 	if err != nil {
 		a.logger.Error("failed to validate session token : ", zap.Error(err))
 		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
 	}
 
 	if session.IsEmpty() || session.IsUUIDEmpty() {
-		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(InvalidSession.Error()))
+		return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(InvalidSession.Error()))
 	}
 
 	// get user account by uuid
@@ -163,6 +310,11 @@ func (a authService) DoRegister(ctx context.Context, in auth.DoRegisterRequest)
 		return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(NoCredentialsFound.Error()))
 	}
 
+	// check password policy
+	if err = passwordpolicy.Check(a.logger, in.GetPassword(), userAccount.GetEmail(), in.GetUsername()); err != nil {
+		return nil, err
+	}
+
 	// generate hash password
 	hashedPassword, err := auth.GeneratePassword(in.GetPassword())
 	if err != nil {
@@ -170,22 +322,44 @@ func (a authService) DoRegister(ctx context.Context, in auth.DoRegisterRequest)
 		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
 	}
 
-	// generate JWT
-	JWT, err := auth.NewJWT().Generate(ctx, a.redis, userAccount.GetUUID())
+	// consume session token, it is only used once the request is known to be valid so a taken
+	// username does not cost the user the session
+	isConsumed, err := auth.ConsumeRegisterSession(ctx, a.redis, in.GetSessionToken())
 	if err != nil {
-		a.logger.Error("failed to generate jwt : ", zap.Error(err))
+		a.logger.Error("failed to delete session token : ", zap.Error(err))
 		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
 	}
 
-	// update user
-	if _, err = in.ToUpdateUser(userAccount, hashedPassword).Update(a.db); err != nil {
+	if !isConsumed {
+		return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(InvalidSession.Error()))
+	}
+
+	// update user, registration is complete so the user can login
+	userAccount = in.ToUpdateUser(userAccount, hashedPassword)
+	if err = userAccount.Verify(); err != nil {
+		return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(NoCredentialsFound.Error()))
+	}
+	if _, err = userAccount.Update(a.db); err != nil {
 		a.logger.Error("failed to update user : ", zap.Error(err))
 		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
 	}
+
+	// generate JWT
+	JWT, err := auth.NewJWT().Generate(ctx, a.redis, userAccount.GetUUID(), meta)
+	if err != nil {
+		a.logger.Error("failed to generate jwt : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
 	return JWT.ToDoRegisterResponse(), nil
 }
 
-func (a authService) DoLogin(ctx context.Context, in auth.DoLoginRequest) (*auth.DoLoginResponse, error) {
+func (a authService) DoLogin(ctx context.Context, in auth.DoLoginRequest, meta auth.SessionMeta) (*auth.DoLoginResponse, error) {
+	// throttle brute force by ip and by email
+	if err := a.checkLoginThrottle(ctx, in.GetEmail(), meta.IP); err != nil {
+		return nil, err
+	}
+
 	// get user by email
 	userAccount, err := user.NewUser().GetOneByEmail(a.db, in.GetEmail())
 	if err != nil {
@@ -194,16 +368,63 @@ func (a authService) DoLogin(ctx context.Context, in auth.DoLoginRequest) (*auth
 	}
 
 	if userAccount.IsEmpty() || !userAccount.IsVerified() {
+		// unknown emails are counted as well so they are throttled the same as known ones
+		if _, err = auth.RecordLoginFailure(ctx, a.redis, in.GetEmail(), meta.IP); err != nil {
+			a.logger.Error("failed to record login failure on redis : ", zap.Error(err))
+		}
 		return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(NoCredentialsFound.Error()))
 	}
 
+	// locked account is rejected even with the right password
+	lockRemaining, err := auth.GetAccountLock(ctx, a.redis, userAccount.GetUUID())
+	if err != nil {
+		a.logger.Error("failed to get account lock on redis : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if lockRemaining > 0 {
+		return nil, accountLockedError(lockRemaining)
+	}
+
 	// compare password
 	if err = auth.ComparePassword(userAccount.GetPassword(), in.GetPassword()); err != nil {
-		return nil, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(InvalidSession.Error()))
+		return nil, a.loginFailed(ctx, userAccount, meta, InvalidSession)
+	}
+
+	return a.completeLogin(ctx, userAccount, meta)
+}
+
+// completeLogin ends a login whose first factor has been checked, an account with 2fa gets a challenge
+// instead of the tokens and keeps its failures until the second factor succeeds
+func (a authService) completeLogin(ctx context.Context, userAccount *user.User, meta auth.SessionMeta) (*auth.DoLoginResponse, error) {
+	totp, err := mfa.NewUserTOTP().GetOneByUserAccountID(a.db, userAccount.GetID())
+	if err != nil {
+		a.logger.Error("failed to get user totp : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if !totp.IsEmpty() && totp.IsEnabled() {
+		challenge, err := mfa.GenerateChallenge(userAccount.GetUUID())
+		if err != nil {
+			a.logger.Error("failed to generate mfa challenge : ", zap.Error(err))
+			return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+		}
+
+		if challenge, err = challenge.Create(ctx, a.redis); err != nil {
+			a.logger.Error("failed to create mfa challenge on redis : ", zap.Error(err))
+			return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+		}
+
+		return challenge.ToDoLoginResponse(), nil
+	}
+
+	// a successful login starts the email window over, the ip window is kept
+	if err = auth.ResetLoginFailures(ctx, a.redis, userAccount.GetEmail()); err != nil {
+		a.logger.Error("failed to reset login failures on redis : ", zap.Error(err))
 	}
 
 	// generate jwt
-	JWT, err := auth.NewJWT().Generate(ctx, a.redis, userAccount.GetUUID())
+	JWT, err := auth.NewJWT().Generate(ctx, a.redis, userAccount.GetUUID(), meta)
 	if err != nil {
 		a.logger.Error("failed to generate jwt : ", zap.Error(err))
 		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
@@ -212,10 +433,260 @@ func (a authService) DoLogin(ctx context.Context, in auth.DoLoginRequest) (*auth
 	return JWT.ToDoLoginResponse(), nil
 }
 
-func (a authService) DoRefreshToken(ctx context.Context, in auth.DoRefreshTokenRequest) (*auth.DoRefreshTokenResponse, error) {
-	dataClaims, err := auth.ValidateRefreshToken(ctx, a.redis, in.GetRefreshToken())
+func (a authService) DoLoginMFA(ctx context.Context, in auth.DoLoginMFARequest, meta auth.SessionMeta) (*auth.DoLoginResponse, error) {
+	// get challenge
+	challenge, err := mfa.GetChallengeByToken(ctx, a.redis, in.GetMFAToken())
 	if err != nil {
-		a.logger.Error("failed to validate refresh token : ", zap.Error(err))
+		a.logger.Error("failed to get mfa challenge on redis : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if challenge.IsEmpty() {
+		return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(InvalidMFAChallenge.Error()))
+	}
+
+	// get user account by uuid
+	userAccount, err := user.NewUser().GetOneByUUID(a.db, challenge.GetUUID())
+	if err != nil {
+		a.logger.Error("failed to get user account by uuid : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if userAccount.IsEmpty() || !userAccount.IsVerified() {
+		return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(NoCredentialsFound.Error()))
+	}
+
+	// get totp
+	totp, err := mfa.NewUserTOTP().GetOneByUserAccountID(a.db, userAccount.GetID())
+	if err != nil {
+		a.logger.Error("failed to get user totp : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if totp.IsEmpty() || !totp.IsEnabled() {
+		return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(InvalidMFAChallenge.Error()))
+	}
+
+	// the second factor is throttled by ip and by email like the password
+	if err = a.checkLoginThrottle(ctx, userAccount.GetEmail(), meta.IP); err != nil {
+		return nil, err
+	}
+
+	// a lock placed after the password step also stops the second factor
+	lockRemaining, err := auth.GetAccountLock(ctx, a.redis, userAccount.GetUUID())
+	if err != nil {
+		a.logger.Error("failed to get account lock on redis : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if lockRemaining > 0 {
+		return nil, accountLockedError(lockRemaining)
+	}
+
+	// check totp or recovery code
+	isValid, err := totp.VerifyCode(ctx, a.db, a.redis, userAccount.GetUUID(), in.GetCode())
+	if err != nil {
+		a.logger.Error("failed to verify totp code : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if !isValid {
+		if err = challenge.Fail(ctx, a.redis); err != nil {
+			a.logger.Error("failed to count mfa challenge attempt on redis : ", zap.Error(err))
+		}
+		// a wrong second factor counts toward the lockout like a wrong password
+		return nil, a.loginFailed(ctx, userAccount, meta, InvalidMFACode)
+	}
+
+	// challenge can only be exchanged once
+	consumed, err := challenge.Consume(ctx, a.redis)
+	if err != nil {
+		a.logger.Error("failed to consume mfa challenge on redis : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if !consumed {
+		return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(InvalidMFAChallenge.Error()))
+	}
+
+	// the login is complete only now, the email window starts over
+	if err = auth.ResetLoginFailures(ctx, a.redis, userAccount.GetEmail()); err != nil {
+		a.logger.Error("failed to reset login failures on redis : ", zap.Error(err))
+	}
+
+	// generate jwt
+	JWT, err := auth.NewJWT().Generate(ctx, a.redis, userAccount.GetUUID(), meta)
+	if err != nil {
+		a.logger.Error("failed to generate jwt : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	return JWT.ToDoLoginResponse(), nil
+}
+
+// checkLoginThrottle rejects the attempt while the ip is over login.ip_max_failures
+// or while the email has to wait out the delay of its previous failures
+func (a authService) checkLoginThrottle(ctx context.Context, email string, ip string) error {
+	ipFailures, err := auth.GetLoginFailuresByIP(ctx, a.redis, ip)
+	if err != nil {
+		a.logger.Error("failed to get login failures by ip on redis : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if ipFailures.IsIPBlocked() {
+		return loginThrottledError(auth.GetLoginWindow())
+	}
+
+	emailFailures, err := auth.GetLoginFailuresByEmail(ctx, a.redis, email)
+	if err != nil {
+		a.logger.Error("failed to get login failures by email on redis : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if retryAfter := emailFailures.GetRetryAfter(); retryAfter > 0 {
+		return loginThrottledError(retryAfter)
+	}
+
+	return nil
+}
+
+// loginFailed counts the wrong password and locks the account once login.lock_after is reached
+func (a authService) loginFailed(ctx context.Context, userAccount *user.User, meta auth.SessionMeta, failure error) error {
+	failures, err := auth.RecordLoginFailure(ctx, a.redis, userAccount.GetEmail(), meta.IP)
+	if err != nil {
+		a.logger.Error("failed to record login failure on redis : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if !failures.ShouldLock() {
+		return responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(failure.Error()))
+	}
+
+	lockDuration := auth.GetLoginLockDuration()
+	locked, err := auth.LockAccount(ctx, a.redis, userAccount.GetUUID(), lockDuration)
+	if err != nil {
+		a.logger.Error("failed to lock account on redis : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if locked {
+		a.logger.Warn("account locked after too many failed logins : ",
+			zap.String("uuid", userAccount.GetUUID()),
+			zap.String("ip", meta.IP),
+			zap.Int64("failures", failures.GetCount()),
+		)
+
+		// the lock is already in place, a mail failure only loses the notification
+		if err = a.mailer.Send(ctx, *mailer.NewAccountLockedMailer(userAccount.GetEmail(), auth.ToAccountLockedMail(lockDuration))); err != nil {
+			a.logger.Error("failed to queue email : ", zap.Error(err))
+		}
+	}
+
+	return accountLockedError(lockDuration)
+}
+
+func loginThrottledError(retryAfter time.Duration) error {
+	return responseErr.New(fiber.StatusTooManyRequests, responseErr.WithCode(CodeLoginThrottled), responseErr.WithMessage(LoginThrottled.Error()), responseErr.WithMeta(map[string]interface{}{
+		"retry_after": int64(retryAfter.Seconds()),
+	}))
+}
+
+func accountLockedError(retryAfter time.Duration) error {
+	return responseErr.New(fiber.StatusLocked, responseErr.WithCode(CodeAccountLocked), responseErr.WithMessage(AccountLocked.Error()), responseErr.WithMeta(map[string]interface{}{
+		"retry_after": int64(retryAfter.Seconds()),
+	}))
+}
+
+func (a authService) RequestMagicLink(ctx context.Context, in auth.MagicLinkRequest) (*auth.MagicLinkResponse, error) {
+	// get user by email
+	userAccount, err := user.NewUser().GetOneByEmail(a.db, in.GetEmail())
+	if err != nil {
+		a.logger.Error("failed to get user account by email : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	// generate link, an unknown email gets a nonce as well so the response does not reveal it
+	link, err := auth.GenerateMagicLink()
+	if err != nil {
+		a.logger.Error("failed to generate magic link : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if userAccount.IsEmpty() || !userAccount.IsVerified() {
+		return link.ToMagicLinkResponse(), nil
+	}
+
+	// create link
+	link.SetUUID(userAccount.GetUUID())
+	if link, err = link.Create(ctx, a.redis); err != nil {
+		a.logger.Error("failed to set magic link on redis : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	// send link to email
+	if err = a.mailer.Send(ctx, *mailer.NewMagicLinkMailer(userAccount.GetEmail(), link.ToMagicLinkMail())); err != nil {
+		a.logger.Error("failed to queue email : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	return link.ToMagicLinkResponse(), nil
+}
+
+func (a authService) VerifyMagicLink(ctx context.Context, in auth.VerifyMagicLinkRequest, meta auth.SessionMeta) (*auth.DoLoginResponse, error) {
+	// consume link, it is single-use whatever the outcome
+	link, err := auth.ConsumeMagicLink(ctx, a.redis, in.GetToken())
+	if err != nil {
+		a.logger.Error("failed to get magic link on redis : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	// a link opened in another browser than the one that asked for it is rejected
+	if link.IsEmpty() || !link.IsBoundTo(in.GetNonce()) {
+		return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(InvalidMagicLink.Error()))
+	}
+
+	// get user by uuid
+	userAccount, err := user.NewUser().GetOneByUUID(a.db, link.GetUUID())
+	if err != nil {
+		a.logger.Error("failed to get user account by uuid : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if userAccount.IsEmpty() || !userAccount.IsVerified() {
+		return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(InvalidMagicLink.Error()))
+	}
+
+	// locked account is rejected like the password login
+	lockRemaining, err := auth.GetAccountLock(ctx, a.redis, userAccount.GetUUID())
+	if err != nil {
+		a.logger.Error("failed to get account lock on redis : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if lockRemaining > 0 {
+		return nil, accountLockedError(lockRemaining)
+	}
+
+	// the link replaces the password only, 2fa is still asked
+	return a.completeLogin(ctx, userAccount, meta)
+}
+
+func (a authService) DoRefreshToken(ctx context.Context, in auth.DoRefreshTokenRequest, meta auth.SessionMeta) (*auth.DoRefreshTokenResponse, error) {
+	// consume refresh token
+	dataClaims, err := auth.RotateRefreshToken(ctx, a.redis, in.GetRefreshToken())
+	if err != nil {
+		if errors.Is(err, auth.ErrRefreshTokenReused) {
+			a.logger.Warn("refresh token reuse detected, token family revoked : ",
+				zap.String("uuid", dataClaims.GetUUID()),
+				zap.String("family", dataClaims.GetFamily()),
+				zap.String("jti", dataClaims.GetJTI()),
+			)
+			return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(err.Error()))
+		}
+		if errors.Is(err, auth.ErrInvalidRefreshToken) {
+			return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(err.Error()))
+		}
+		a.logger.Error("failed to rotate refresh token : ", zap.Error(err))
 		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
 	}
 
@@ -230,7 +701,8 @@ func (a authService) DoRefreshToken(ctx context.Context, in auth.DoRefreshTokenR
 		return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(NoCredentialsFound.Error()))
 	}
 
-	JWT, err := auth.NewJWT().Generate(ctx, a.redis, userAccount.GetUUID())
+	// keep the new refresh token in the same family
+	JWT, err := auth.NewJWT().GenerateInFamily(ctx, a.redis, userAccount.GetUUID(), dataClaims.GetFamily(), meta)
 	if err != nil {
 		a.logger.Error("failed to generate jwt : ", zap.Error(err))
 		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
@@ -238,9 +710,13 @@ func (a authService) DoRefreshToken(ctx context.Context, in auth.DoRefreshTokenR
 
 	return JWT.ToDoRefreshTokenResponse(), nil
 }
-func (a authService) DoLogout(ctx context.Context, in auth.DoLogoutRequest) error {
+
+func (a authService) DoLogout(ctx context.Context, in auth.DoLogoutRequest, userAccountUUID string, accessTokenJTI string, accessTokenExpiresAt time.Time) error {
 	dataClaims, err := auth.ValidateRefreshToken(ctx, a.redis, in.GetRefreshToken())
 	if err != nil {
+		if errors.Is(err, auth.ErrInvalidRefreshToken) {
+			return responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(err.Error()))
+		}
 		a.logger.Error("failed to validate refresh token : ", zap.Error(err))
 		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
 	}
@@ -252,15 +728,726 @@ func (a authService) DoLogout(ctx context.Context, in auth.DoLogoutRequest) erro
 		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
 	}
 
-	if userAccount.IsEmpty() || !userAccount.IsVerified() {
+	// refresh token should belong to the same user as the access token
+	if userAccount.IsEmpty() || !userAccount.IsVerified() || userAccount.GetUUID() != userAccountUUID {
 		return responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(NoCredentialsFound.Error()))
 	}
 
-	// delete refresh token on redis
-	if err = auth.DeleteRefreshToken(ctx, a.redis, dataClaims.GetJTI()); err != nil {
+	// deny the access token used to logout for the rest of its lifetime
+	if err = auth.RevokeAccessToken(ctx, a.redis, accessTokenJTI, accessTokenExpiresAt); err != nil {
+		a.logger.Error("failed to revoke access token on redis : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	// revoke refresh token with every token rotated from the same login
+	if err = auth.RevokeRefreshTokenFamily(ctx, a.redis, dataClaims.GetFamily()); err != nil {
 		a.logger.Error("failed to delete refresh token on redis : ", zap.Error(err))
 		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
 	}
 
 	return nil
 }
+
+func (a authService) ForgotPassword(ctx context.Context, in auth.ForgotPasswordRequest) error {
+	// get user by email
+	userAccount, err := user.NewUser().GetOneByEmail(a.db, in.GetEmail())
+	if err != nil {
+		a.logger.Error("failed to get user account by email : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	// never reveal whether the email is registered
+	if userAccount.IsEmpty() || !userAccount.IsVerified() {
+		return nil
+	}
+
+	// check cooldown, a throttled request answers like any other so the email can not be enumerated
+	acquired, _, err := auth.AcquireOTPResendCooldown(ctx, a.redis, auth.OTPResetPassword, userAccount.GetUUID())
+	if err != nil {
+		a.logger.Error("failed to set otp resend cooldown on redis : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if !acquired {
+		return nil
+	}
+
+	// check daily cap
+	total, err := auth.IncrementOTPResendDaily(ctx, a.redis, auth.OTPResetPassword, userAccount.GetUUID(), userAccount.GetEmail())
+	if err != nil {
+		a.logger.Error("failed to increment otp resend counter on redis : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if total > auth.GetOTPResendDailyCap() {
+		return nil
+	}
+
+	// generate otp
+	newOTP, err := auth.GenerateNewOTP()
+	if err != nil {
+		a.logger.Error("failed to generate otp : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	// create otp
+	otp, err := auth.NewOTPWithPurpose(auth.OTPResetPassword, userAccount.GetUUID(), newOTP).Create(ctx, a.redis, auth.GetOTPExpiredInSecond())
+	if err != nil {
+		a.logger.Error("failed to set otp on redis : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	// send otp to email
+	if err = a.mailer.Send(ctx, *mailer.NewResetPasswordOTPMailer(userAccount.GetEmail(), otp.ToResetPasswordMail())); err != nil {
+		a.logger.Error("failed to queue email : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	return nil
+}
+
+func (a authService) ResetPassword(ctx context.Context, in auth.ResetPasswordRequest) error {
+	// get user by email
+	userAccount, err := user.NewUser().GetOneByEmail(a.db, in.GetEmail())
+	if err != nil {
+		a.logger.Error("failed to get user account by email : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	// unknown email answers like a wrong otp so the email can not be enumerated
+	if userAccount.IsEmpty() || !userAccount.IsVerified() {
+		return responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(InvalidOTP.Error()))
+	}
+
+	// check otp
+	otp, err := auth.GetOTPByPurposeAndUUID(ctx, a.redis, auth.OTPResetPassword, userAccount.GetUUID())
+	if err != nil {
+		a.logger.Error("failed to get otp by uuid : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if otp.IsNotFound() {
+		return responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(InvalidOTP.Error()))
+	}
+
+	// check invalid otp, the wrong guess is counted by Verify
+	if !otp.IsValid(in.GetOTP()) {
+		if _, err = otp.Verify(ctx, a.redis, in.GetOTP()); err != nil {
+			a.logger.Error("failed to verify otp : ", zap.Error(err))
+			return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+		}
+		return responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(InvalidOTP.Error()))
+	}
+
+	// check password policy, only the holder of the otp gets this far so the answer does not reveal the email
+	// and the otp is kept for another password
+	if err = passwordpolicy.Check(a.logger, in.GetPassword(), userAccount.GetEmail(), userAccount.GetUsername()); err != nil {
+		return err
+	}
+
+	// consume otp, false means another request has used it
+	isValid, err := otp.Verify(ctx, a.redis, in.GetOTP())
+	if err != nil {
+		a.logger.Error("failed to verify otp : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if !isValid {
+		return responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(InvalidOTP.Error()))
+	}
+
+	// generate hash password
+	hashedPassword, err := auth.GeneratePassword(in.GetPassword())
+	if err != nil {
+		a.logger.Error("failed to generate password : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	// update user password
+	userAccount.SetPassword(hashedPassword)
+	if _, err = userAccount.Update(a.db); err != nil {
+		a.logger.Error("failed to update user : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	// revoke every refresh and access token, user should login again with the new password
+	if err = auth.DeleteAllRefreshTokens(ctx, a.redis, userAccount.GetUUID()); err != nil {
+		a.logger.Error("failed to delete refresh tokens on redis : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+	if err = auth.RevokeAllAccessTokens(ctx, a.redis, userAccount.GetUUID()); err != nil {
+		a.logger.Error("failed to revoke access tokens on redis : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	return nil
+}
+
+func (a authService) GetJWKS(ctx context.Context) (*auth.JWKS, error) {
+	return auth.GetJWKS(), nil
+}
+
+func (a authService) GetSessions(ctx context.Context, userAccountUUID string, currentSessionID string) ([]auth.DeviceSessionResponse, error) {
+	// get active sessions
+	sessions, err := auth.GetDeviceSessions(ctx, a.redis, userAccountUUID)
+	if err != nil {
+		a.logger.Error("failed to get device sessions on redis : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	res := make([]auth.DeviceSessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		res = append(res, session.ToDeviceSessionResponse(currentSessionID))
+	}
+
+	return res, nil
+}
+
+func (a authService) RevokeSession(ctx context.Context, in auth.RevokeSessionRequest, userAccountUUID string) error {
+	// get session
+	session, err := auth.GetDeviceSession(ctx, a.redis, in.GetSessionID())
+	if err != nil {
+		a.logger.Error("failed to get device session on redis : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	// a session of another user answers the same as an unknown one
+	if session.IsEmpty() || !session.IsOwnedBy(userAccountUUID) {
+		return responseErr.New(fiber.StatusNotFound, responseErr.WithMessage(SessionNotFound.Error()))
+	}
+
+	// revoke session
+	if err = auth.RevokeDeviceSession(ctx, a.redis, *session); err != nil {
+		a.logger.Error("failed to revoke device session on redis : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	return nil
+}
+
+func (a authService) RevokeAllSessions(ctx context.Context, userAccountUUID string) error {
+	// revoke every refresh token and session
+	if err := auth.DeleteAllRefreshTokens(ctx, a.redis, userAccountUUID); err != nil {
+		a.logger.Error("failed to delete refresh tokens on redis : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	// revoke every access token including the one used for this request
+	if err := auth.RevokeAllAccessTokens(ctx, a.redis, userAccountUUID); err != nil {
+		a.logger.Error("failed to revoke access tokens on redis : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	return nil
+}
+
+func (a authService) UnlockAccount(ctx context.Context, in auth.UnlockAccountRequest) error {
+	// get user by email
+	userAccount, err := user.NewUser().GetOneByEmail(a.db, in.GetEmail())
+	if err != nil {
+		a.logger.Error("failed to get user account by email : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if userAccount.IsEmpty() {
+		return responseErr.New(fiber.StatusNotFound, responseErr.WithMessage(AccountNotFound.Error()))
+	}
+
+	// remove lock and the failures that led to it
+	if err = auth.UnlockAccount(ctx, a.redis, userAccount.GetUUID(), userAccount.GetEmail()); err != nil {
+		a.logger.Error("failed to unlock account on redis : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	a.logger.Info("account unlocked by admin : ", zap.String("uuid", userAccount.GetUUID()))
+
+	return nil
+}
+
+func (a authService) SuspendAccount(ctx context.Context, in auth.AccountStatusRequest) error {
+	return a.changeAccountStatus(ctx, in, (*user.User).Suspend, true)
+}
+
+func (a authService) ReactivateAccount(ctx context.Context, in auth.AccountStatusRequest) error {
+	return a.changeAccountStatus(ctx, in, (*user.User).Reactivate, false)
+}
+
+func (a authService) DeleteAccount(ctx context.Context, in auth.AccountStatusRequest) error {
+	return a.changeAccountStatus(ctx, in, (*user.User).Delete, true)
+}
+
+// changeAccountStatus moves the account along the lifecycle for an admin, an account that can no longer login
+// loses its tokens at once
+func (a authService) changeAccountStatus(ctx context.Context, in auth.AccountStatusRequest, transition func(*user.User) error, revoke bool) error {
+	// get user by email
+	userAccount, err := user.NewUser().GetOneByEmail(a.db, in.GetEmail())
+	if err != nil {
+		a.logger.Error("failed to get user account by email : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if userAccount.IsEmpty() {
+		return responseErr.New(fiber.StatusNotFound, responseErr.WithMessage(AccountNotFound.Error()))
+	}
+
+	// the lifecycle refuses a change the current status does not allow
+	from := userAccount.GetStatus()
+	if err = transition(userAccount); err != nil {
+		return responseErr.New(fiber.StatusConflict, responseErr.WithMessage(AccountStatusConflict.Error()))
+	}
+
+	if _, err = userAccount.Update(a.db); err != nil {
+		a.logger.Error("failed to update user : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	// revoke every refresh and access token
+	if revoke {
+		if err = auth.DeleteAllRefreshTokens(ctx, a.redis, userAccount.GetUUID()); err != nil {
+			a.logger.Error("failed to delete refresh tokens on redis : ", zap.Error(err))
+			return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+		}
+		if err = auth.RevokeAllAccessTokens(ctx, a.redis, userAccount.GetUUID()); err != nil {
+			a.logger.Error("failed to revoke access tokens on redis : ", zap.Error(err))
+			return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+		}
+	}
+
+	a.logger.Info("account status changed by admin : ", zap.String("uuid", userAccount.GetUUID()),
+		zap.String("from", string(from)), zap.String("to", string(userAccount.GetStatus())))
+
+	return nil
+}
+
+func (a authService) BeginWebAuthnRegistration(ctx context.Context, userAccountUUID string) (*webauthn.CreationOptionsResponse, error) {
+	// get user account by uuid
+	userAccount, err := user.NewUser().GetOneByUUID(a.db, userAccountUUID)
+	if err != nil {
+		a.logger.Error("failed to get user account by uuid : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if userAccount.IsEmpty() || !userAccount.IsVerified() {
+		return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(NoCredentialsFound.Error()))
+	}
+
+	// get registered passkeys
+	credentials, err := webauthn.GetCredentialsByUserAccountID(a.db, userAccount.GetID())
+	if err != nil {
+		a.logger.Error("failed to get webauthn credentials : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	// generate challenge
+	challenge, err := webauthn.GenerateChallenge(userAccount.GetUUID())
+	if err != nil {
+		a.logger.Error("failed to generate webauthn challenge : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if challenge, err = challenge.CreateRegistration(ctx, a.redis); err != nil {
+		a.logger.Error("failed to create webauthn registration on redis : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	return challenge.ToCreationOptions(userAccount.GetEmail(), userAccount.GetName(), credentials), nil
+}
+
+func (a authService) FinishWebAuthnRegistration(ctx context.Context, in webauthn.FinishRegistrationRequest, userAccountUUID string) (*webauthn.CredentialResponse, error) {
+	// get user account by uuid
+	userAccount, err := user.NewUser().GetOneByUUID(a.db, userAccountUUID)
+	if err != nil {
+		a.logger.Error("failed to get user account by uuid : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if userAccount.IsEmpty() || !userAccount.IsVerified() {
+		return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(NoCredentialsFound.Error()))
+	}
+
+	// consume registration challenge
+	challenge, err := webauthn.ConsumeRegistration(ctx, a.redis, userAccount.GetUUID())
+	if err != nil {
+		a.logger.Error("failed to get webauthn registration on redis : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if challenge.IsEmpty() {
+		return nil, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(PasskeyChallenge.Error()))
+	}
+
+	// verify attestation
+	credential, err := webauthn.VerifyRegistration(in.GetCredential(), challenge.GetChallenge(), userAccount.GetID(), in.GetName())
+	if err != nil {
+		return nil, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(PasskeyNotVerified.Error()), responseErr.WithMeta(map[string]interface{}{
+			"reason": err.Error(),
+		}))
+	}
+
+	// a credential id belongs to a single account
+	existing, err := webauthn.NewUserWebauthnCredential().GetOneByCredentialID(a.db, credential.GetCredentialID())
+	if err != nil {
+		a.logger.Error("failed to get webauthn credential : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if !existing.IsEmpty() {
+		return nil, responseErr.New(fiber.StatusUnprocessableEntity, responseErr.WithMessage(PasskeyRegistered.Error()))
+	}
+
+	// store credential
+	if credential, err = credential.Create(a.db); err != nil {
+		a.logger.Error("failed to create webauthn credential : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	return credential.ToCredentialResponse(), nil
+}
+
+func (a authService) BeginWebAuthnLogin(ctx context.Context, in webauthn.BeginLoginRequest) (*webauthn.RequestOptionsResponse, error) {
+	var (
+		userAccountUUID string
+		credentials     []webauthn.UserWebauthnCredential
+	)
+
+	// without an email the browser offers the discoverable passkeys it holds for the rp
+	if in.GetEmail() != "" {
+		userAccount, err := user.NewUser().GetOneByEmail(a.db, in.GetEmail())
+		if err != nil {
+			a.logger.Error("failed to get user account by email : ", zap.Error(err))
+			return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+		}
+
+		// an unknown email gets options as well so it can not be told apart, the challenge is bound to
+		// a random uuid no account owns, otherwise the empty allow list would accept any discoverable passkey
+		userAccountUUID = uuid.New().String()
+		if !userAccount.IsEmpty() && userAccount.IsVerified() {
+			userAccountUUID = userAccount.GetUUID()
+			if credentials, err = webauthn.GetCredentialsByUserAccountID(a.db, userAccount.GetID()); err != nil {
+				a.logger.Error("failed to get webauthn credentials : ", zap.Error(err))
+				return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+			}
+		}
+	}
+
+	// generate challenge
+	challenge, err := webauthn.GenerateChallenge(userAccountUUID)
+	if err != nil {
+		a.logger.Error("failed to generate webauthn challenge : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if challenge, err = challenge.CreateLogin(ctx, a.redis); err != nil {
+		a.logger.Error("failed to create webauthn login on redis : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	return challenge.ToRequestOptions(credentials), nil
+}
+
+func (a authService) FinishWebAuthnLogin(ctx context.Context, in webauthn.FinishLoginRequest, meta auth.SessionMeta) (*auth.DoLoginResponse, error) {
+	assertion := in.GetCredential()
+
+	// consume login challenge, the client data carries it back
+	challengeValue, err := webauthn.ParseClientDataChallenge(assertion.Response.ClientDataJSON)
+	if err != nil {
+		return nil, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(InvalidPasskey.Error()))
+	}
+
+	challenge, err := webauthn.ConsumeLogin(ctx, a.redis, challengeValue)
+	if err != nil {
+		a.logger.Error("failed to get webauthn login on redis : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if challenge.IsEmpty() {
+		return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(PasskeyChallenge.Error()))
+	}
+
+	// get credential
+	credential, err := webauthn.NewUserWebauthnCredential().GetOneByCredentialID(a.db, assertion.ID)
+	if err != nil {
+		a.logger.Error("failed to get webauthn credential : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if credential.IsEmpty() {
+		return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(InvalidPasskey.Error()))
+	}
+
+	// get user account of the credential
+	userAccount, err := user.NewUser().GetOneByID(a.db, credential.GetUserAccountID())
+	if err != nil {
+		a.logger.Error("failed to get user account by id : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if userAccount.IsEmpty() || !userAccount.IsVerified() {
+		return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(NoCredentialsFound.Error()))
+	}
+
+	// a challenge started for an email only accepts the passkeys of that account
+	if (challenge.GetUUID() != "" && challenge.GetUUID() != userAccount.GetUUID()) ||
+		!webauthn.IsUserHandleOf(assertion.Response.UserHandle, userAccount.GetUUID()) {
+		return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(InvalidPasskey.Error()))
+	}
+
+	// locked account is rejected with a passkey as well
+	lockRemaining, err := auth.GetAccountLock(ctx, a.redis, userAccount.GetUUID())
+	if err != nil {
+		a.logger.Error("failed to get account lock on redis : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if lockRemaining > 0 {
+		return nil, accountLockedError(lockRemaining)
+	}
+
+	// verify signature
+	signCount, err := credential.VerifyAssertion(assertion, challenge.GetChallenge())
+	if err != nil {
+		if errors.Is(err, webauthn.ErrSignCountRegressed) {
+			a.logger.Warn("passkey signature counter regressed : ",
+				zap.String("uuid", userAccount.GetUUID()),
+				zap.String("credential_id", credential.GetCredentialID()),
+			)
+		}
+		return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(PasskeyNotVerified.Error()))
+	}
+
+	// store signature counter
+	credential.SetUsed(signCount)
+	if _, err = credential.Update(a.db); err != nil {
+		a.logger.Error("failed to update webauthn credential : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	// a passkey is possession and user verification on its own, there is no totp challenge after it
+	JWT, err := auth.NewJWT().Generate(ctx, a.redis, userAccount.GetUUID(), meta)
+	if err != nil {
+		a.logger.Error("failed to generate jwt : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	return JWT.ToDoLoginResponse(), nil
+}
+
+func (a authService) BeginOIDCLogin(ctx context.Context, in oidc.BeginRequest) (*oidc.BeginResponse, error) {
+	return a.beginOIDC(ctx, in.GetProvider(), "")
+}
+
+// BeginOIDCLink starts a login at the provider whose identity is linked to the account of the logged in user
+func (a authService) BeginOIDCLink(ctx context.Context, in oidc.BeginRequest, userAccountUUID string) (*oidc.BeginResponse, error) {
+	return a.beginOIDC(ctx, in.GetProvider(), userAccountUUID)
+}
+
+// beginOIDC keeps the state of a login, a link is started with the uuid of the user
+func (a authService) beginOIDC(ctx context.Context, providerName string, userAccountUUID string) (*oidc.BeginResponse, error) {
+	// get provider
+	provider, err := a.getOIDCProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = provider.Prepare(ctx); err != nil {
+		return nil, a.oidcProviderError(err)
+	}
+
+	// generate state, pkce verifier and nonce
+	state, err := oidc.GenerateLinkState(provider.GetName(), userAccountUUID)
+	if err != nil {
+		a.logger.Error("failed to generate oidc state : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if state, err = state.Create(ctx, a.redis); err != nil {
+		a.logger.Error("failed to create oidc state on redis : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	return state.ToBeginResponse(*provider), nil
+}
+
+func (a authService) FinishOIDCLogin(ctx context.Context, in oidc.CallbackRequest, meta auth.SessionMeta) (*auth.DoLoginResponse, error) {
+	// get provider
+	provider, err := a.getOIDCProvider(in.GetProvider())
+	if err != nil {
+		return nil, err
+	}
+
+	// consume state, it has to be started for the same provider
+	state, err := oidc.ConsumeLoginState(ctx, a.redis, in.GetState())
+	if err != nil {
+		a.logger.Error("failed to get oidc state on redis : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	// a link state only finishes at the link callback of the same user
+	if state.IsEmpty() || state.GetProvider() != provider.GetName() || state.IsLink() {
+		return nil, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(InvalidOIDCState.Error()))
+	}
+
+	// exchange code and verify the identity
+	identity, err := provider.Exchange(ctx, in.GetCode(), *state)
+	if err != nil {
+		return nil, a.oidcProviderError(err)
+	}
+
+	// get linked identity
+	userIdentity, err := oidc.NewUserIdentity().GetOneByProviderSubject(a.db, identity.Provider, identity.Subject)
+	if err != nil {
+		a.logger.Error("failed to get user identity : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	var userAccount *user.User
+	if !userIdentity.IsEmpty() {
+		if userAccount, err = user.NewUser().GetOneByID(a.db, userIdentity.GetUserAccountID()); err != nil {
+			a.logger.Error("failed to get user account by id : ", zap.Error(err))
+			return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+		}
+
+		if userAccount.IsEmpty() || !userAccount.IsVerified() {
+			return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(NoCredentialsFound.Error()))
+		}
+
+		userIdentity.SetLoggedIn(identity.GetEmail())
+		if _, err = userIdentity.Update(a.db); err != nil {
+			a.logger.Error("failed to update user identity : ", zap.Error(err))
+			return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+		}
+	} else {
+		// a new identity is only linked by an email the provider vouches for
+		if !identity.IsEmailVerified() {
+			return nil, responseErr.New(fiber.StatusForbidden, responseErr.WithMessage(OIDCEmailNotVerified.Error()))
+		}
+
+		if userAccount, err = user.NewUser().GetOneByEmail(a.db, identity.GetEmail()); err != nil {
+			a.logger.Error("failed to get user by email : ", zap.Error(err))
+			return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+		}
+
+		switch {
+		case userAccount.IsEmpty():
+			// no account has the email, the provider signs the user up
+			userAccount, err = identity.ToUser()
+		case userAccount.IsPending():
+			// a sign-up that was never finished is taken over by the owner of the email, its password is cleared
+			userAccount, err = identity.ToVerifiedUser(userAccount)
+		case userAccount.IsVerified():
+			// an account in use is only linked by its owner once logged in
+			return nil, responseErr.New(fiber.StatusConflict, responseErr.WithCode(CodeOIDCLinkRequired), responseErr.WithMessage(OIDCLinkRequired.Error()))
+		default:
+			// a suspended account can not be taken back through a provider
+			return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(NoCredentialsFound.Error()))
+		}
+
+		if err != nil {
+			a.logger.Error("failed to verify user account : ", zap.Error(err))
+			return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+		}
+
+		if _, err = identity.ToUserIdentity().Link(a.db, userAccount); err != nil {
+			a.logger.Error("failed to link user identity : ", zap.Error(err))
+			return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+		}
+	}
+
+	// locked account is rejected with a social login as well
+	lockRemaining, err := auth.GetAccountLock(ctx, a.redis, userAccount.GetUUID())
+	if err != nil {
+		a.logger.Error("failed to get account lock on redis : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if lockRemaining > 0 {
+		return nil, accountLockedError(lockRemaining)
+	}
+
+	return a.completeLogin(ctx, userAccount, meta)
+}
+
+// FinishOIDCLink links the identity of the provider to the logged in user, the identity of another account is refused
+func (a authService) FinishOIDCLink(ctx context.Context, in oidc.CallbackRequest, userAccountUUID string) error {
+	// get provider
+	provider, err := a.getOIDCProvider(in.GetProvider())
+	if err != nil {
+		return err
+	}
+
+	// consume state, it has to be a link started by the same user
+	state, err := oidc.ConsumeLoginState(ctx, a.redis, in.GetState())
+	if err != nil {
+		a.logger.Error("failed to get oidc state on redis : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if state.IsEmpty() || state.GetProvider() != provider.GetName() || !state.IsLinkOf(userAccountUUID) {
+		return responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(InvalidOIDCState.Error()))
+	}
+
+	// get user account
+	userAccount, err := user.NewUser().GetOneByUUID(a.db, userAccountUUID)
+	if err != nil {
+		a.logger.Error("failed to get user account by uuid : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if userAccount.IsEmpty() || !userAccount.IsVerified() {
+		return responseErr.New(fiber.StatusNotFound, responseErr.WithMessage(AccountNotFound.Error()))
+	}
+
+	// exchange code and verify the identity
+	identity, err := provider.Exchange(ctx, in.GetCode(), *state)
+	if err != nil {
+		return a.oidcProviderError(err)
+	}
+
+	// get linked identity, linking it again to the same account is a no-op
+	userIdentity, err := oidc.NewUserIdentity().GetOneByProviderSubject(a.db, identity.Provider, identity.Subject)
+	if err != nil {
+		a.logger.Error("failed to get user identity : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if !userIdentity.IsEmpty() {
+		if userIdentity.GetUserAccountID() != userAccount.GetID() {
+			return responseErr.New(fiber.StatusConflict, responseErr.WithMessage(OIDCIdentityLinked.Error()))
+		}
+		return nil
+	}
+
+	if _, err = identity.ToUserIdentity().Link(a.db, userAccount); err != nil {
+		a.logger.Error("failed to link user identity : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	return nil
+}
+
+func (a authService) getOIDCProvider(name string) (*oidc.Provider, error) {
+	provider, err := oidc.GetProvider(name, a.httpClient)
+	if err != nil {
+		if errors.Is(err, oidc.ErrUnknownProvider) {
+			return nil, responseErr.New(fiber.StatusNotFound, responseErr.WithMessage(ProviderNotFound.Error()))
+		}
+		a.logger.Error("failed to get oidc provider : ", zap.String("provider", name), zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	return provider, nil
+}
+
+// oidcProviderError tells a login the provider rejected from a provider that could not be reached
+func (a authService) oidcProviderError(err error) error {
+	switch {
+	case errors.Is(err, oidc.ErrInvalidAuthorizationCode), errors.Is(err, oidc.ErrInvalidIDToken), errors.Is(err, oidc.ErrSubjectMismatch):
+		return responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(InvalidOIDCLogin.Error()))
+	case errors.Is(err, oidc.ErrProviderUnavailable):
+		a.logger.Error("failed to reach oidc provider : ", zap.Error(err))
+		return responseErr.New(fiber.StatusBadGateway, responseErr.WithMessage(ProviderUnavailable.Error()))
+	}
+
+	a.logger.Error("failed to use oidc provider : ", zap.Error(err))
+	return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+}
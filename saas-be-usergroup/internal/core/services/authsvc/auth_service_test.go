@@ -0,0 +1,405 @@
+package authsvc
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/saas-be-usergroup/internal/adapter/mailer/maildrv"
+	"github.com/saas-be-usergroup/internal/core/domain/auth"
+	"github.com/saas-be-usergroup/internal/core/domain/mailer"
+	"github.com/saas-be-usergroup/internal/core/domain/mfa"
+	"github.com/saas-be-usergroup/internal/core/domain/user"
+	"github.com/saas-be-usergroup/internal/core/ports"
+	responseErr "github.com/saas-be-usergroup/internal/error"
+	"github.com/spf13/viper"
+	"github.com/xlzd/gotp"
+	"go.uber.org/zap"
+)
+
+const testUserUUID = "8b0f6c3e-5c1a-4d5e-9a7b-0c2d3e4f5a6b"
+
+// usersByEmail answers the users lookup with the accounts keyed by email
+func usersByEmail(accounts map[string]user.UserStatus) fakeQuery {
+	return func(query string, args []driver.NamedValue) ([]string, [][]driver.Value) {
+		columns := []string{"id", "uuid", "user_name", "password", "email", "status", "insert_ts"}
+		if !strings.Contains(query, `"users"`) || len(args) == 0 {
+			return columns, nil
+		}
+
+		email, _ := args[0].Value.(string)
+		status, ok := accounts[email]
+		if !ok {
+			return columns, nil
+		}
+		return columns, [][]driver.Value{{int64(1), testUserUUID, "alice", "hashed", email, string(status), time.Now()}}
+	}
+}
+
+func TestForgotPasswordSendsResetOTP(t *testing.T) {
+	store, client := newFakeRedis(t)
+	db := newFakeDB(t, usersByEmail(map[string]user.UserStatus{"alice@example.com": user.UserVerified}))
+	mail := maildrv.NewMemoryMailer()
+	service := NewAuthService(db, client, mail, zap.NewNop())
+
+	if err := service.ForgotPassword(context.Background(), auth.ForgotPasswordRequest{Email: "alice@example.com"}); err != nil {
+		t.Fatalf("ForgotPassword() error = %v", err)
+	}
+
+	sent := mail.SentTo("alice@example.com")
+	if len(sent) != 1 {
+		t.Fatalf("sent %d mails, want 1", len(sent))
+	}
+	if sent[0].Template != "reset-password-otp.html" || sent[0].Subject != "Reset Password OTP" {
+		t.Errorf("sent %s %q, want the reset password otp mail", sent[0].Template, sent[0].Subject)
+	}
+
+	prop, ok := sent[0].Prop.(*mailer.ResetPasswordMail)
+	if !ok {
+		t.Fatalf("prop is %T, want *mailer.ResetPasswordMail", sent[0].Prop)
+	}
+	stored, ok := store.get("reset-password-otp-" + testUserUUID)
+	if !ok || prop.OTP != stored {
+		t.Errorf("mailed otp %q, stored otp %q, want the same otp", prop.OTP, stored)
+	}
+	if prop.Duration != auth.GetOTPExpiredInSecond() {
+		t.Errorf("mailed duration %d, want %d", prop.Duration, auth.GetOTPExpiredInSecond())
+	}
+}
+
+func TestForgotPasswordDoesNotMailUnknownOrPendingAccounts(t *testing.T) {
+	_, client := newFakeRedis(t)
+	db := newFakeDB(t, usersByEmail(map[string]user.UserStatus{"pending@example.com": user.UserConfirmed}))
+	mail := maildrv.NewMemoryMailer()
+	service := NewAuthService(db, client, mail, zap.NewNop())
+
+	for _, email := range []string{"nobody@example.com", "pending@example.com"} {
+		if err := service.ForgotPassword(context.Background(), auth.ForgotPasswordRequest{Email: email}); err != nil {
+			t.Fatalf("ForgotPassword(%s) error = %v, want nil so the email can not be enumerated", email, err)
+		}
+	}
+
+	if outbox := mail.Outbox(); len(outbox) != 0 {
+		t.Errorf("sent %d mails, want none", len(outbox))
+	}
+}
+
+func TestForgotPasswordHonoursResendCooldown(t *testing.T) {
+	_, client := newFakeRedis(t)
+	db := newFakeDB(t, usersByEmail(map[string]user.UserStatus{"alice@example.com": user.UserVerified}))
+	mail := maildrv.NewMemoryMailer()
+	service := NewAuthService(db, client, mail, zap.NewNop())
+
+	for i := 0; i < 3; i++ {
+		if err := service.ForgotPassword(context.Background(), auth.ForgotPasswordRequest{Email: "alice@example.com"}); err != nil {
+			t.Fatalf("ForgotPassword() error = %v, want nil while throttled", err)
+		}
+	}
+
+	if sent := mail.SentTo("alice@example.com"); len(sent) != 1 {
+		t.Errorf("sent %d mails within the cooldown, want 1", len(sent))
+	}
+}
+
+// statusOf returns the http status of an application error, zero for anything else
+func statusOf(err error) int {
+	var appErr *responseErr.AppError
+	if errors.As(err, &appErr) {
+		return appErr.Status
+	}
+	return 0
+}
+
+func TestResetPasswordChecksThePolicyAfterTheOTP(t *testing.T) {
+	const otpKey = "reset-password-otp-" + testUserUUID
+
+	tests := []struct {
+		name       string
+		email      string
+		otp        string
+		wantStatus int
+	}{
+		{name: "unknown email", email: "nobody@example.com", otp: "123456", wantStatus: fiber.StatusUnauthorized},
+		{name: "wrong otp", email: "alice@example.com", otp: "654321", wantStatus: fiber.StatusUnauthorized},
+		{name: "valid otp", email: "alice@example.com", otp: "123456", wantStatus: fiber.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, client := newFakeRedis(t)
+			db := newFakeDB(t, usersByEmail(map[string]user.UserStatus{"alice@example.com": user.UserVerified}))
+			service := NewAuthService(db, client, maildrv.NewMemoryMailer(), zap.NewNop())
+			if err := client.Set(context.Background(), otpKey, "123456", time.Minute).Err(); err != nil {
+				t.Fatalf("seed otp: %v", err)
+			}
+
+			err := service.ResetPassword(context.Background(), auth.ResetPasswordRequest{
+				Email:           tt.email,
+				OTP:             tt.otp,
+				Password:        "weak",
+				ConfirmPassword: "weak",
+			})
+			if got := statusOf(err); got != tt.wantStatus {
+				t.Fatalf("ResetPassword() status = %d (%v), want %d", got, err, tt.wantStatus)
+			}
+			// a weak password never consumes the otp, the holder can try another one
+			if _, ok := store.get(otpKey); !ok {
+				t.Error("otp was consumed, want it kept")
+			}
+		})
+	}
+}
+
+func TestResetPasswordCountsTheWrongOTPBeforeThePolicy(t *testing.T) {
+	store, client := newFakeRedis(t)
+	db := newFakeDB(t, usersByEmail(map[string]user.UserStatus{"alice@example.com": user.UserVerified}))
+	service := NewAuthService(db, client, maildrv.NewMemoryMailer(), zap.NewNop())
+	if err := client.Set(context.Background(), "reset-password-otp-"+testUserUUID, "123456", time.Minute).Err(); err != nil {
+		t.Fatalf("seed otp: %v", err)
+	}
+
+	err := service.ResetPassword(context.Background(), auth.ResetPasswordRequest{Email: "alice@example.com", OTP: "000000", Password: "weak"})
+	if got := statusOf(err); got != fiber.StatusUnauthorized {
+		t.Fatalf("ResetPassword() status = %d (%v), want %d", got, err, fiber.StatusUnauthorized)
+	}
+	if attempts, _ := store.get("reset-password-otp-" + testUserUUID + "-attempts"); attempts != "1" {
+		t.Errorf("attempts = %q, want 1", attempts)
+	}
+}
+
+func TestChangeAccountStatus(t *testing.T) {
+	accounts := map[string]user.UserStatus{
+		"verified@example.com":  user.UserVerified,
+		"suspended@example.com": user.UserSuspended,
+		"pending@example.com":   user.UserConfirmed,
+	}
+	const versionKey = "token-version-" + testUserUUID
+
+	tests := []struct {
+		name        string
+		change      func(ports.AuthService, context.Context, auth.AccountStatusRequest) error
+		email       string
+		wantStatus  int
+		wantRevoked bool
+	}{
+		{name: "suspend verified", change: ports.AuthService.SuspendAccount, email: "verified@example.com", wantRevoked: true},
+		{name: "suspend suspended", change: ports.AuthService.SuspendAccount, email: "suspended@example.com", wantStatus: fiber.StatusConflict},
+		{name: "suspend pending", change: ports.AuthService.SuspendAccount, email: "pending@example.com", wantStatus: fiber.StatusConflict},
+		{name: "reactivate suspended", change: ports.AuthService.ReactivateAccount, email: "suspended@example.com"},
+		{name: "reactivate verified", change: ports.AuthService.ReactivateAccount, email: "verified@example.com", wantStatus: fiber.StatusConflict},
+		{name: "reactivate pending", change: ports.AuthService.ReactivateAccount, email: "pending@example.com", wantStatus: fiber.StatusConflict},
+		{name: "delete verified", change: ports.AuthService.DeleteAccount, email: "verified@example.com", wantRevoked: true},
+		{name: "delete suspended", change: ports.AuthService.DeleteAccount, email: "suspended@example.com", wantRevoked: true},
+		{name: "unknown email", change: ports.AuthService.SuspendAccount, email: "nobody@example.com", wantStatus: fiber.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, client := newFakeRedis(t)
+			db := newFakeDB(t, usersByEmail(accounts))
+			service := NewAuthService(db, client, maildrv.NewMemoryMailer(), zap.NewNop())
+
+			err := tt.change(service, context.Background(), auth.AccountStatusRequest{Email: tt.email})
+			if tt.wantStatus == 0 && err != nil {
+				t.Fatalf("error = %v, want nil", err)
+			}
+			if got := statusOf(err); tt.wantStatus != 0 && got != tt.wantStatus {
+				t.Fatalf("status = %d (%v), want %d", got, err, tt.wantStatus)
+			}
+			if _, revoked := store.get(versionKey); revoked != tt.wantRevoked {
+				t.Errorf("tokens revoked = %v, want %v", revoked, tt.wantRevoked)
+			}
+		})
+	}
+}
+
+const (
+	testPassword   = "Tr0ub4dor&3"
+	testTOTPSecret = "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"
+)
+
+// mfaAccount is a verified account with totp enabled, the recovery codes are used up by the update of their hash
+type mfaAccount struct {
+	password      string
+	recoveryCodes map[string]bool
+}
+
+func newMFAAccount(t *testing.T, recoveryCodes ...mfa.UserRecoveryCode) *mfaAccount {
+	t.Helper()
+
+	viper.Set("jwt.access_secret", "access-secret")
+	viper.Set("jwt.refresh_secret", "refresh-secret")
+	t.Cleanup(func() {
+		viper.Set("jwt.access_secret", nil)
+		viper.Set("jwt.refresh_secret", nil)
+	})
+
+	password, err := auth.GeneratePassword(testPassword)
+	if err != nil {
+		t.Fatalf("GeneratePassword() error = %v", err)
+	}
+
+	account := &mfaAccount{password: password, recoveryCodes: map[string]bool{}}
+	for _, code := range recoveryCodes {
+		account.recoveryCodes[code.CodeHash] = false
+	}
+	return account
+}
+
+func (m *mfaAccount) query(query string, args []driver.NamedValue) ([]string, [][]driver.Value) {
+	if strings.Contains(query, "user_totp") {
+		columns := []string{"id", "user_account_id", "secret", "enabled", "enabled_ts", "insert_ts"}
+		return columns, [][]driver.Value{{int64(1), int64(1), testTOTPSecret, true, time.Now(), time.Now()}}
+	}
+
+	columns := []string{"id", "uuid", "user_name", "password", "email", "status", "insert_ts"}
+	return columns, [][]driver.Value{{int64(1), testUserUUID, "alice", m.password, "alice@example.com", string(user.UserVerified), time.Now()}}
+}
+
+func (m *mfaAccount) exec(query string, args []driver.NamedValue) int64 {
+	if !strings.Contains(query, "user_recovery_codes") {
+		return 1
+	}
+	for _, arg := range args {
+		hash, _ := arg.Value.(string)
+		if used, ok := m.recoveryCodes[hash]; ok && !used {
+			m.recoveryCodes[hash] = true
+			return 1
+		}
+	}
+	return 0
+}
+
+func (m *mfaAccount) service(t *testing.T) (*fakeRedis, ports.AuthService) {
+	t.Helper()
+	store, client := newFakeRedis(t)
+	db := newFakeDB(t, m.query, m.exec)
+	return store, NewAuthService(db, client, maildrv.NewMemoryMailer(), zap.NewNop())
+}
+
+// loginFirstFactor runs the password step and returns the mfa token of the challenge
+func loginFirstFactor(t *testing.T, service ports.AuthService) string {
+	t.Helper()
+
+	res, err := service.DoLogin(context.Background(), auth.DoLoginRequest{Email: "alice@example.com", Password: testPassword}, auth.SessionMeta{IP: "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("DoLogin() error = %v", err)
+	}
+	if !res.MFARequired || res.MFAToken == "" {
+		t.Fatalf("DoLogin() = %+v, want an mfa challenge", res)
+	}
+	return res.MFAToken
+}
+
+func TestDoLoginAsksForTheSecondFactor(t *testing.T) {
+	account := newMFAAccount(t)
+	store, service := account.service(t)
+
+	res, err := service.DoLogin(context.Background(), auth.DoLoginRequest{Email: "alice@example.com", Password: testPassword}, auth.SessionMeta{IP: "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("DoLogin() error = %v", err)
+	}
+	if !res.MFARequired || res.MFAToken == "" {
+		t.Fatalf("DoLogin() = %+v, want mfa_required with a token", res)
+	}
+	if res.AccessToken != "" || res.RefreshToken != "" {
+		t.Error("DoLogin() issued tokens before the second factor")
+	}
+	if uuid, ok := store.get("mfa-challenge-" + res.MFAToken); !ok || uuid != testUserUUID {
+		t.Errorf("challenge holds %q, want the uuid of the account", uuid)
+	}
+}
+
+func TestDoLoginMFAExchangesTheChallengeOnce(t *testing.T) {
+	account := newMFAAccount(t)
+	store, service := account.service(t)
+	token := loginFirstFactor(t, service)
+
+	code := gotp.NewDefaultTOTP(testTOTPSecret).Now()
+	res, err := service.DoLoginMFA(context.Background(), auth.DoLoginMFARequest{MFAToken: token, Code: code}, auth.SessionMeta{IP: "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("DoLoginMFA() error = %v", err)
+	}
+	if res.AccessToken == "" || res.RefreshToken == "" || res.MFARequired {
+		t.Fatalf("DoLoginMFA() = %+v, want the tokens", res)
+	}
+	if _, ok := store.get("mfa-challenge-" + token); ok {
+		t.Error("challenge is kept after the login")
+	}
+
+	_, err = service.DoLoginMFA(context.Background(), auth.DoLoginMFARequest{MFAToken: token, Code: code}, auth.SessionMeta{IP: "127.0.0.1"})
+	if got := statusOf(err); got != fiber.StatusUnauthorized {
+		t.Errorf("second DoLoginMFA() status = %d (%v), want %d", got, err, fiber.StatusUnauthorized)
+	}
+}
+
+func TestDoLoginMFARefusesATOTPCodeTwice(t *testing.T) {
+	account := newMFAAccount(t)
+	_, service := account.service(t)
+
+	code := gotp.NewDefaultTOTP(testTOTPSecret).Now()
+	if _, err := service.DoLoginMFA(context.Background(), auth.DoLoginMFARequest{MFAToken: loginFirstFactor(t, service), Code: code}, auth.SessionMeta{IP: "127.0.0.1"}); err != nil {
+		t.Fatalf("DoLoginMFA() error = %v", err)
+	}
+
+	_, err := service.DoLoginMFA(context.Background(), auth.DoLoginMFARequest{MFAToken: loginFirstFactor(t, service), Code: code}, auth.SessionMeta{IP: "127.0.0.1"})
+	if got := statusOf(err); got != fiber.StatusBadRequest {
+		t.Errorf("DoLoginMFA() with a used code status = %d (%v), want %d", got, err, fiber.StatusBadRequest)
+	}
+}
+
+func TestDoLoginMFADropsTheChallengeAfterTheMaxAttempts(t *testing.T) {
+	account := newMFAAccount(t)
+	store, service := account.service(t)
+	token := loginFirstFactor(t, service)
+
+	for i := int64(1); i <= mfa.GetChallengeMaxAttempts(); i++ {
+		_, err := service.DoLoginMFA(context.Background(), auth.DoLoginMFARequest{MFAToken: token, Code: "000000"}, auth.SessionMeta{IP: "127.0.0.1"})
+		if got := statusOf(err); got != fiber.StatusBadRequest {
+			t.Fatalf("attempt %d status = %d (%v), want %d", i, got, err, fiber.StatusBadRequest)
+		}
+	}
+
+	if _, ok := store.get("mfa-challenge-" + token); ok {
+		t.Fatal("challenge is kept after the max attempts")
+	}
+
+	code := gotp.NewDefaultTOTP(testTOTPSecret).Now()
+	_, err := service.DoLoginMFA(context.Background(), auth.DoLoginMFARequest{MFAToken: token, Code: code}, auth.SessionMeta{IP: "127.0.0.1"})
+	if got := statusOf(err); got != fiber.StatusUnauthorized {
+		t.Errorf("DoLoginMFA() on a dropped challenge status = %d (%v), want %d", got, err, fiber.StatusUnauthorized)
+	}
+}
+
+func TestDoLoginMFARecoveryCodeIsSingleUse(t *testing.T) {
+	codes, rows, err := mfa.GenerateRecoveryCodes(1)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes() error = %v", err)
+	}
+	account := newMFAAccount(t, rows...)
+	_, service := account.service(t)
+
+	// the code is accepted as shown and once more in upper case without the dash
+	res, err := service.DoLoginMFA(context.Background(), auth.DoLoginMFARequest{MFAToken: loginFirstFactor(t, service), Code: codes[0]}, auth.SessionMeta{IP: "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("DoLoginMFA() with a recovery code error = %v", err)
+	}
+	if res.AccessToken == "" {
+		t.Fatalf("DoLoginMFA() = %+v, want the tokens", res)
+	}
+
+	reused := strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))
+	_, err = service.DoLoginMFA(context.Background(), auth.DoLoginMFARequest{MFAToken: loginFirstFactor(t, service), Code: reused}, auth.SessionMeta{IP: "127.0.0.1"})
+	if got := statusOf(err); got != fiber.StatusBadRequest {
+		t.Fatalf("DoLoginMFA() with a used recovery code status = %d (%v), want %d", got, err, fiber.StatusBadRequest)
+	}
+
+	// the other codes are still good
+	if _, err = service.DoLoginMFA(context.Background(), auth.DoLoginMFARequest{MFAToken: loginFirstFactor(t, service), Code: codes[1]}, auth.SessionMeta{IP: "127.0.0.1"}); err != nil {
+		t.Errorf("DoLoginMFA() with another recovery code error = %v", err)
+	}
+}
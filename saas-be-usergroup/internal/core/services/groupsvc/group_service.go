@@ -82,10 +82,10 @@ func (g groupService) AddGroupMember(ctx context.Context, in group.AddGroupMembe
 		return responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(AdminStatusNotVerified.Error()))
 	}
 
-	// get user who want to add by uuid
-	member, err := user.NewUser().GetOneByUUID(g.db, adminUUID)
+	// get user who want to add by username
+	member, err := user.NewUser().GetOneByUsername(g.db, in.GetUsername())
 	if err != nil {
-		g.logger.Error("failed to get user member by uuid : ", zap.Error(err))
+		g.logger.Error("failed to get user member by username : ", zap.Error(err))
 		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
 	}
 
@@ -161,7 +161,19 @@ func (g groupService) RemoveGroupMember(ctx context.Context, in group.RemoveGrou
 		return responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(NotAdmin.Error()))
 	}
 
-	memberInGroup, err := group.NewInGroup().GetOneByUserGroupIDAndUserAccountID(g.db, in.UserGroupID, admin.ID)
+	// get user who want to remove by username
+	member, err := user.NewUser().GetOneByUsername(g.db, in.GetUsername())
+	if err != nil {
+		g.logger.Error("failed to get user member by username : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	// check empty user
+	if member.IsEmpty() {
+		return responseErr.New(fiber.StatusUnprocessableEntity, responseErr.WithMessage(MemberNotFound.Error()))
+	}
+
+	memberInGroup, err := group.NewInGroup().GetOneByUserGroupIDAndUserAccountID(g.db, in.UserGroupID, member.ID)
 	if err != nil {
 		g.logger.Error("failed to get member in_group by group id and user id : ", zap.Error(err))
 		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
@@ -0,0 +1,178 @@
+package mfasvc
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/saas-be-usergroup/internal/core/domain/auth"
+	"github.com/saas-be-usergroup/internal/core/domain/mfa"
+	"github.com/saas-be-usergroup/internal/core/domain/user"
+	"github.com/saas-be-usergroup/internal/core/ports"
+	responseErr "github.com/saas-be-usergroup/internal/error"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	NoCredentialsFound = errors.New("no credentials found")
+	TOTPAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	TOTPNotEnrolled    = errors.New("two-factor authentication has not been enrolled")
+	TOTPNotEnabled     = errors.New("two-factor authentication is not enabled")
+	InvalidCode        = errors.New("invalid code")
+	InvalidPassword    = errors.New("invalid password")
+)
+
+type mfaService struct {
+	db     *gorm.DB
+	redis  *redis.Client
+	logger *zap.Logger
+}
+
+func NewMFAService(db *gorm.DB, redis *redis.Client, logger *zap.Logger) ports.MFAService {
+	return &mfaService{db: db, redis: redis, logger: logger}
+}
+
+func (m mfaService) EnrollTOTP(ctx context.Context, userAccountUUID string) (*mfa.EnrollResponse, error) {
+	// get user account by uuid
+	userAccount, err := user.NewUser().GetOneByUUID(m.db, userAccountUUID)
+	if err != nil {
+		m.logger.Error("failed to get user account by uuid : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if userAccount.IsEmpty() || !userAccount.IsVerified() {
+		return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(NoCredentialsFound.Error()))
+	}
+
+	// get current totp
+	totp, err := mfa.NewUserTOTP().GetOneByUserAccountID(m.db, userAccount.GetID())
+	if err != nil {
+		m.logger.Error("failed to get user totp : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if !totp.IsEmpty() && totp.IsEnabled() {
+		return nil, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(TOTPAlreadyEnabled.Error()))
+	}
+
+	// enrolling again replaces the secret that was never verified
+	pending := mfa.NewPendingUserTOTP(userAccount.GetID())
+	if !totp.IsEmpty() {
+		totp.SetSecret(pending.Secret)
+		pending = totp
+	}
+
+	if _, err = pending.Save(m.db); err != nil {
+		m.logger.Error("failed to save user totp : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	return pending.ToEnrollResponse(userAccount.GetEmail()), nil
+}
+
+func (m mfaService) VerifyTOTP(ctx context.Context, in mfa.VerifyTOTPRequest, userAccountUUID string) (*mfa.RecoveryCodesResponse, error) {
+	// get user account by uuid
+	userAccount, err := user.NewUser().GetOneByUUID(m.db, userAccountUUID)
+	if err != nil {
+		m.logger.Error("failed to get user account by uuid : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if userAccount.IsEmpty() || !userAccount.IsVerified() {
+		return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(NoCredentialsFound.Error()))
+	}
+
+	// get pending totp
+	totp, err := mfa.NewUserTOTP().GetOneByUserAccountID(m.db, userAccount.GetID())
+	if err != nil {
+		m.logger.Error("failed to get user totp : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if totp.IsEmpty() {
+		return nil, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(TOTPNotEnrolled.Error()))
+	}
+
+	if totp.IsEnabled() {
+		return nil, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(TOTPAlreadyEnabled.Error()))
+	}
+
+	// only a totp code proves the authenticator app has the secret
+	if mfa.IsRecoveryCode(in.GetCode()) {
+		return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(InvalidCode.Error()))
+	}
+
+	isValid, err := totp.VerifyCode(ctx, m.db, m.redis, userAccount.GetUUID(), in.GetCode())
+	if err != nil {
+		m.logger.Error("failed to verify totp code : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if !isValid {
+		return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(InvalidCode.Error()))
+	}
+
+	// generate recovery codes, they are only shown once
+	codes, recoveryCodes, err := mfa.GenerateRecoveryCodes(userAccount.GetID())
+	if err != nil {
+		m.logger.Error("failed to generate recovery codes : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	// enable totp
+	if _, err = totp.Enable(m.db, recoveryCodes); err != nil {
+		m.logger.Error("failed to enable user totp : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	return mfa.NewRecoveryCodesResponse(codes), nil
+}
+
+func (m mfaService) DisableTOTP(ctx context.Context, in mfa.DisableTOTPRequest, userAccountUUID string) error {
+	// get user account by uuid
+	userAccount, err := user.NewUser().GetOneByUUID(m.db, userAccountUUID)
+	if err != nil {
+		m.logger.Error("failed to get user account by uuid : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if userAccount.IsEmpty() || !userAccount.IsVerified() {
+		return responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(NoCredentialsFound.Error()))
+	}
+
+	// compare password, a stolen access token alone can not turn 2fa off
+	if err = auth.ComparePassword(userAccount.GetPassword(), in.GetPassword()); err != nil {
+		return responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(InvalidPassword.Error()))
+	}
+
+	// get totp
+	totp, err := mfa.NewUserTOTP().GetOneByUserAccountID(m.db, userAccount.GetID())
+	if err != nil {
+		m.logger.Error("failed to get user totp : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if totp.IsEmpty() || !totp.IsEnabled() {
+		return responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(TOTPNotEnabled.Error()))
+	}
+
+	// check totp or recovery code
+	isValid, err := totp.VerifyCode(ctx, m.db, m.redis, userAccount.GetUUID(), in.GetCode())
+	if err != nil {
+		m.logger.Error("failed to verify totp code : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if !isValid {
+		return responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(InvalidCode.Error()))
+	}
+
+	// delete totp with its recovery codes
+	if err = totp.Delete(m.db); err != nil {
+		m.logger.Error("failed to delete user totp : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	return nil
+}
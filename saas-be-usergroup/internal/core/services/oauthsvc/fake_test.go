@@ -0,0 +1,314 @@
+package oauthsvc
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeRedis is an in-process RESP server backed by maps, it knows the strings and hashes the oauth domain uses
+type fakeRedis struct {
+	listener net.Listener
+	mu       sync.Mutex
+	values   map[string]string
+	hashes   map[string]map[string]string
+}
+
+func newFakeRedis(t *testing.T) (*fakeRedis, *redis.Client) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	f := &fakeRedis{listener: listener, values: map[string]string{}, hashes: map[string]map[string]string{}}
+	go f.serve()
+
+	client := redis.NewClient(&redis.Options{Addr: listener.Addr().String()})
+	t.Cleanup(func() {
+		client.Close()
+		listener.Close()
+	})
+	return f, client
+}
+
+func (f *fakeRedis) exists(key string) bool {
+	_, isValue := f.values[key]
+	_, isHash := f.hashes[key]
+	return isValue || isHash
+}
+
+func (f *fakeRedis) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	var queued [][]string
+	inMulti := false
+
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+
+		name := strings.ToUpper(args[0])
+		switch {
+		case name == "MULTI":
+			inMulti = true
+			queued = nil
+			conn.Write([]byte("+OK\r\n"))
+		case name == "EXEC":
+			inMulti = false
+			reply := fmt.Sprintf("*%d\r\n", len(queued))
+			for _, cmd := range queued {
+				reply += f.exec(cmd)
+			}
+			conn.Write([]byte(reply))
+		case inMulti:
+			queued = append(queued, args)
+			conn.Write([]byte("+QUEUED\r\n"))
+		default:
+			conn.Write([]byte(f.exec(args)))
+		}
+	}
+}
+
+func (f *fakeRedis) exec(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "GET":
+		if value, ok := f.values[args[1]]; ok {
+			return bulk(value)
+		}
+		return "$-1\r\n"
+	case "MGET":
+		reply := fmt.Sprintf("*%d\r\n", len(args)-1)
+		for _, key := range args[1:] {
+			if value, ok := f.values[key]; ok {
+				reply += bulk(value)
+			} else {
+				reply += "$-1\r\n"
+			}
+		}
+		return reply
+	case "SET":
+		for _, opt := range args[3:] {
+			if strings.ToUpper(opt) == "NX" && f.exists(args[1]) {
+				return "$-1\r\n"
+			}
+		}
+		f.values[args[1]] = args[2]
+		return "+OK\r\n"
+	case "DEL":
+		deleted := 0
+		for _, key := range args[1:] {
+			if f.exists(key) {
+				delete(f.values, key)
+				delete(f.hashes, key)
+				deleted++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", deleted)
+	case "EXISTS":
+		exists := 0
+		for _, key := range args[1:] {
+			if f.exists(key) {
+				exists++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", exists)
+	case "INCR":
+		n, _ := strconv.ParseInt(f.values[args[1]], 10, 64)
+		n++
+		f.values[args[1]] = strconv.FormatInt(n, 10)
+		return fmt.Sprintf(":%d\r\n", n)
+	case "HSET":
+		hash, ok := f.hashes[args[1]]
+		if !ok {
+			hash = map[string]string{}
+			f.hashes[args[1]] = hash
+		}
+		for i := 2; i+1 < len(args); i += 2 {
+			hash[args[i]] = args[i+1]
+		}
+		return fmt.Sprintf(":%d\r\n", (len(args)-2)/2)
+	case "HGETALL":
+		hash := f.hashes[args[1]]
+		reply := fmt.Sprintf("*%d\r\n", len(hash)*2)
+		for field, value := range hash {
+			reply += bulk(field) + bulk(value)
+		}
+		return reply
+	case "EXPIRE", "PEXPIRE":
+		if f.exists(args[1]) {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	case "TTL", "PTTL":
+		if f.exists(args[1]) {
+			return ":60\r\n"
+		}
+		return ":-2\r\n"
+	default:
+		return "-ERR unknown command '" + args[0] + "'\r\n"
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, errors.New("expected a resp array")
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(header[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+func bulk(value string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+}
+
+// fakeQuery answers a statement with the columns and rows to return, nil rows answers an empty result
+type fakeQuery func(query string, args []driver.NamedValue) ([]string, [][]driver.Value)
+
+// fakeExec answers a write with the rows it affected
+type fakeExec func(query string, args []driver.NamedValue) int64
+
+var (
+	fakeQueriesMu sync.Mutex
+	fakeQueries   = map[string]fakeQuery{}
+	fakeExecs     = map[string]fakeExec{}
+)
+
+func init() {
+	sql.Register("oauthsvc-fake", fakeDriver{})
+}
+
+// newFakeDB opens gorm on a database/sql driver whose reads are answered by query, a write affects
+// one row unless exec is given
+func newFakeDB(t *testing.T, query fakeQuery, exec ...fakeExec) *gorm.DB {
+	t.Helper()
+
+	fakeQueriesMu.Lock()
+	fakeQueries[t.Name()] = query
+	if len(exec) > 0 {
+		fakeExecs[t.Name()] = exec[0]
+	}
+	fakeQueriesMu.Unlock()
+
+	db, err := gorm.Open(postgres.New(postgres.Config{DriverName: "oauthsvc-fake", DSN: t.Name()}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open fake db: %v", err)
+	}
+	return db
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(dsn string) (driver.Conn, error) {
+	fakeQueriesMu.Lock()
+	defer fakeQueriesMu.Unlock()
+	return &fakeConn{query: fakeQueries[dsn], exec: fakeExecs[dsn]}, nil
+}
+
+type fakeConn struct {
+	query fakeQuery
+	exec  fakeExec
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare is not supported")
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	columns, rows := c.query(query, args)
+	return &fakeRows{columns: columns, rows: rows}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if c.exec != nil {
+		return driver.RowsAffected(c.exec(query, args)), nil
+	}
+	return driver.RowsAffected(1), nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
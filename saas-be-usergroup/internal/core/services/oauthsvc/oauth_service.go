@@ -0,0 +1,486 @@
+package oauthsvc
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/saas-be-usergroup/internal/core/domain/auth"
+	"github.com/saas-be-usergroup/internal/core/domain/oauth"
+	"github.com/saas-be-usergroup/internal/core/domain/user"
+	"github.com/saas-be-usergroup/internal/core/ports"
+	responseErr "github.com/saas-be-usergroup/internal/error"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"strings"
+)
+
+var (
+	NoCredentialsFound      = errors.New("no credentials found")
+	ClientNotFound          = errors.New("client not found")
+	InvalidRedirectURI      = errors.New("redirect uri is not registered for the client")
+	InvalidConsent          = errors.New("invalid or expired consent")
+	InvalidAuthorization    = errors.New("invalid authorization request")
+	PKCERequired            = errors.New("code_challenge with the S256 method is required")
+	UnsupportedResponseType = errors.New("only the code response type is supported")
+	ScopeNotAllowed         = errors.New("scope is not allowed for the client")
+	GrantNotAllowed         = errors.New("grant type is not allowed for the client")
+	InvalidGrant            = errors.New("authorization grant is invalid, expired or revoked")
+)
+
+type oauthService struct {
+	db     *gorm.DB
+	redis  *redis.Client
+	logger *zap.Logger
+}
+
+func NewOAuthService(db *gorm.DB, redis *redis.Client, logger *zap.Logger) ports.OAuthService {
+	return &oauthService{db: db, redis: redis, logger: logger}
+}
+
+func (o oauthService) RegisterClient(ctx context.Context, in oauth.RegisterClientRequest) (*oauth.RegisterClientResponse, error) {
+	// generate credentials
+	client, secret, err := in.ToClient()
+	if err != nil {
+		o.logger.Error("failed to generate oauth client credentials : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	// create client
+	if client, err = client.Create(o.db); err != nil {
+		o.logger.Error("failed to create oauth client : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	o.logger.Info("oauth client registered : ", zap.String("client_id", client.GetClientID()), zap.String("name", client.GetName()))
+
+	return client.ToRegisterClientResponse(secret), nil
+}
+
+func (o oauthService) Authorize(ctx context.Context, in oauth.AuthorizationRequest, userAccountUUID string) (*oauth.ConsentResponse, error) {
+	// get client, an unknown client or redirect uri is never redirected to
+	client, err := oauth.NewOAuthClient().GetOneByClientID(o.db, in.GetClientID())
+	if err != nil {
+		o.logger.Error("failed to get oauth client : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if client.IsEmpty() {
+		return nil, responseErr.New(fiber.StatusBadRequest, responseErr.WithCode(oauth.ErrorInvalidClient), responseErr.WithMessage(ClientNotFound.Error()))
+	}
+
+	if !client.HasRedirectURI(in.GetRedirectURI()) {
+		return nil, responseErr.New(fiber.StatusBadRequest, responseErr.WithCode(oauth.ErrorInvalidRequest), responseErr.WithMessage(InvalidRedirectURI.Error()))
+	}
+
+	// from here the error goes back to the client through the redirect uri
+	if in.ResponseType != oauth.ResponseTypeCode {
+		return nil, authorizationError(in, oauth.ErrorUnsupportedResponseType, UnsupportedResponseType)
+	}
+
+	if !client.AllowsGrant(oauth.GrantAuthorizationCode) {
+		return nil, authorizationError(in, oauth.ErrorUnauthorizedClient, GrantNotAllowed)
+	}
+
+	if in.CodeChallenge == "" || in.CodeChallengeMethod != oauth.CodeChallengeMethodS256 {
+		return nil, authorizationError(in, oauth.ErrorInvalidRequest, PKCERequired)
+	}
+
+	scope, ok := client.GrantScope(in.GetScope())
+	if !ok {
+		return nil, authorizationError(in, oauth.ErrorInvalidScope, ScopeNotAllowed)
+	}
+
+	// check user
+	userAccount, err := user.NewUser().GetOneByUUID(o.db, userAccountUUID)
+	if err != nil {
+		o.logger.Error("failed to get user account by uuid : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if userAccount.IsEmpty() || !userAccount.IsVerified() {
+		return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(NoCredentialsFound.Error()))
+	}
+
+	// keep the checked request until the user decides
+	consent, err := in.ToConsent(userAccount.GetUUID(), scope)
+	if err != nil {
+		o.logger.Error("failed to generate oauth consent : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if consent, err = consent.Create(ctx, o.redis); err != nil {
+		o.logger.Error("failed to create oauth consent on redis : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	return consent.ToConsentResponse(*client), nil
+}
+
+func (o oauthService) Decide(ctx context.Context, in oauth.DecisionRequest, userAccountUUID string) (*oauth.DecisionResponse, error) {
+	// consume consent
+	consent, err := oauth.ConsumeConsent(ctx, o.redis, in.GetConsentToken())
+	if err != nil {
+		o.logger.Error("failed to get oauth consent on redis : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	// a consent of another user answers the same as an unknown one
+	if consent.IsEmpty() || !consent.IsOwnedBy(userAccountUUID) {
+		return nil, responseErr.New(fiber.StatusBadRequest, responseErr.WithMessage(InvalidConsent.Error()))
+	}
+
+	if !in.IsApproved() {
+		return consent.ToDeniedResponse(), nil
+	}
+
+	// issue authorization code
+	code, err := consent.ToAuthorizationCode()
+	if err != nil {
+		o.logger.Error("failed to generate authorization code : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if code, err = code.Create(ctx, o.redis); err != nil {
+		o.logger.Error("failed to create authorization code on redis : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	return consent.ToApprovedResponse(*code), nil
+}
+
+func (o oauthService) Token(ctx context.Context, in oauth.TokenRequest) (*oauth.TokenResponse, error) {
+	// authenticate client
+	client, err := o.authenticateClient(in.ClientCredentials)
+	if err != nil {
+		return nil, err
+	}
+
+	if !client.AllowsGrant(in.GetGrantType()) {
+		if in.GetGrantType() == oauth.GrantAuthorizationCode || in.GetGrantType() == oauth.GrantRefreshToken || in.GetGrantType() == oauth.GrantClientCredentials {
+			return nil, oauth.NewError(fiber.StatusBadRequest, oauth.ErrorUnauthorizedClient, GrantNotAllowed.Error())
+		}
+		return nil, oauth.NewError(fiber.StatusBadRequest, oauth.ErrorUnsupportedGrantType, "")
+	}
+
+	switch in.GetGrantType() {
+	case oauth.GrantAuthorizationCode:
+		return o.exchangeAuthorizationCode(ctx, *client, in)
+	case oauth.GrantRefreshToken:
+		return o.exchangeRefreshToken(ctx, *client, in)
+	default:
+		return o.issueClientCredentials(ctx, *client, in)
+	}
+}
+
+func (o oauthService) exchangeAuthorizationCode(ctx context.Context, client oauth.OAuthClient, in oauth.TokenRequest) (*oauth.TokenResponse, error) {
+	if in.GetCode() == "" || in.GetCodeVerifier() == "" {
+		return nil, oauth.NewError(fiber.StatusBadRequest, oauth.ErrorInvalidRequest, "code and code_verifier are required")
+	}
+
+	// consume code
+	code, err := oauth.ConsumeAuthorizationCode(ctx, o.redis, in.GetCode())
+	if err != nil {
+		o.logger.Error("failed to get authorization code on redis : ", zap.Error(err))
+		return nil, oauth.NewServerError()
+	}
+
+	// a code exchanged twice was intercepted, the tokens issued on its first exchange are revoked, rfc 6749 section 4.1.2
+	if code.IsEmpty() {
+		if err = o.revokeGrant(ctx, oauth.GetGrantID(in.GetCode())); err != nil {
+			return nil, err
+		}
+		return nil, oauth.NewError(fiber.StatusBadRequest, oauth.ErrorInvalidGrant, InvalidGrant.Error())
+	}
+
+	if !code.IsIssuedFor(client.GetClientID(), in.GetRedirectURI()) || !code.VerifyCodeVerifier(in.GetCodeVerifier()) {
+		return nil, oauth.NewError(fiber.StatusBadRequest, oauth.ErrorInvalidGrant, InvalidGrant.Error())
+	}
+
+	return o.issueUserTokens(ctx, client, code.GetUUID(), code.GetGrantID(), code.GetScope())
+}
+
+// revokeGrant deletes the refresh token of the grant and denies its access tokens
+func (o oauthService) revokeGrant(ctx context.Context, grantID string) error {
+	revoked, err := oauth.RevokeGrant(ctx, o.redis, grantID)
+	if err != nil {
+		o.logger.Error("failed to revoke oauth grant on redis : ", zap.Error(err))
+		return oauth.NewServerError()
+	}
+
+	if !revoked {
+		return nil
+	}
+
+	if err = auth.RevokeGrantAccessTokens(ctx, o.redis, grantID, oauth.GetAccessTTL()); err != nil {
+		o.logger.Error("failed to revoke oauth grant access tokens on redis : ", zap.Error(err))
+		return oauth.NewServerError()
+	}
+
+	return nil
+}
+
+func (o oauthService) exchangeRefreshToken(ctx context.Context, client oauth.OAuthClient, in oauth.TokenRequest) (*oauth.TokenResponse, error) {
+	if in.GetRefreshToken() == "" {
+		return nil, oauth.NewError(fiber.StatusBadRequest, oauth.ErrorInvalidRequest, "refresh_token is required")
+	}
+
+	// get refresh token, a client presenting the token of another client must not burn it
+	refreshToken, err := oauth.GetRefreshToken(ctx, o.redis, in.GetRefreshToken())
+	if err != nil {
+		o.logger.Error("failed to get oauth refresh token on redis : ", zap.Error(err))
+		return nil, oauth.NewServerError()
+	}
+
+	if refreshToken.IsEmpty() || !refreshToken.IsIssuedTo(client.GetClientID()) {
+		return nil, oauth.NewError(fiber.StatusBadRequest, oauth.ErrorInvalidGrant, InvalidGrant.Error())
+	}
+
+	// the scope may only be narrowed, rfc 6749 section 6
+	scope := refreshToken.GetScope()
+	if in.GetScope() != "" {
+		narrowed, ok := oauth.OAuthClient{Scopes: refreshToken.GetScope()}.GrantScope(in.GetScope())
+		if !ok {
+			return nil, oauth.NewError(fiber.StatusBadRequest, oauth.ErrorInvalidScope, ScopeNotAllowed.Error())
+		}
+		scope = narrowed
+	}
+
+	// consume refresh token, it is rotated
+	if refreshToken, err = oauth.ConsumeRefreshToken(ctx, o.redis, in.GetRefreshToken()); err != nil {
+		o.logger.Error("failed to consume oauth refresh token on redis : ", zap.Error(err))
+		return nil, oauth.NewServerError()
+	}
+
+	// consumed by a concurrent request
+	if refreshToken.IsEmpty() {
+		return nil, oauth.NewError(fiber.StatusBadRequest, oauth.ErrorInvalidGrant, InvalidGrant.Error())
+	}
+
+	active, err := o.isRefreshTokenActive(ctx, *refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if !active {
+		return nil, oauth.NewError(fiber.StatusBadRequest, oauth.ErrorInvalidGrant, InvalidGrant.Error())
+	}
+
+	return o.issueUserTokens(ctx, client, refreshToken.GetUUID(), refreshToken.GetGrantID(), scope)
+}
+
+// isRefreshTokenActive checks the user did not revoke every token since it was issued and its grant was not
+// revoked by a replay of the code
+func (o oauthService) isRefreshTokenActive(ctx context.Context, refreshToken oauth.RefreshToken) (bool, error) {
+	version, err := auth.GetTokenVersion(ctx, o.redis, refreshToken.GetUUID())
+	if err != nil {
+		o.logger.Error("failed to get token version on redis : ", zap.Error(err))
+		return false, oauth.NewServerError()
+	}
+
+	if refreshToken.IsRevokedBy(version) {
+		return false, nil
+	}
+
+	active, err := oauth.IsGrantActive(ctx, o.redis, refreshToken.GetGrantID())
+	if err != nil {
+		o.logger.Error("failed to get oauth grant on redis : ", zap.Error(err))
+		return false, oauth.NewServerError()
+	}
+
+	return active, nil
+}
+
+func (o oauthService) issueClientCredentials(ctx context.Context, client oauth.OAuthClient, in oauth.TokenRequest) (*oauth.TokenResponse, error) {
+	scope, ok := client.GrantScope(in.GetScope())
+	if !ok {
+		return nil, oauth.NewError(fiber.StatusBadRequest, oauth.ErrorInvalidScope, ScopeNotAllowed.Error())
+	}
+
+	// the client acts for itself, there is no user and no refresh token
+	JWT, err := auth.NewJWT().GenerateForClient(ctx, o.redis, client.GetClientID(), "", "", scope, oauth.GetAccessTTL())
+	if err != nil {
+		o.logger.Error("failed to generate jwt : ", zap.Error(err))
+		return nil, oauth.NewServerError()
+	}
+
+	return oauth.ToTokenResponse(*JWT, nil, scope), nil
+}
+
+// issueUserTokens checks the user is still active and issues an access token, with a refresh token when the
+// client may use that grant. Both are bound to the grant and to the token version of the user
+func (o oauthService) issueUserTokens(ctx context.Context, client oauth.OAuthClient, userAccountUUID string, grantID string, scope string) (*oauth.TokenResponse, error) {
+	userAccount, err := user.NewUser().GetOneByUUID(o.db, userAccountUUID)
+	if err != nil {
+		o.logger.Error("failed to get user account by uuid : ", zap.Error(err))
+		return nil, oauth.NewServerError()
+	}
+
+	if userAccount.IsEmpty() || !userAccount.IsVerified() {
+		return nil, oauth.NewError(fiber.StatusBadRequest, oauth.ErrorInvalidGrant, InvalidGrant.Error())
+	}
+
+	version, err := auth.GetTokenVersion(ctx, o.redis, userAccount.GetUUID())
+	if err != nil {
+		o.logger.Error("failed to get token version on redis : ", zap.Error(err))
+		return nil, oauth.NewServerError()
+	}
+
+	JWT, err := auth.NewJWT().GenerateForClient(ctx, o.redis, client.GetClientID(), userAccount.GetUUID(), grantID, scope, oauth.GetAccessTTL())
+	if err != nil {
+		o.logger.Error("failed to generate jwt : ", zap.Error(err))
+		return nil, oauth.NewServerError()
+	}
+
+	if !client.AllowsGrant(oauth.GrantRefreshToken) {
+		if err = oauth.CreateGrant(ctx, o.redis, grantID); err != nil {
+			o.logger.Error("failed to create oauth grant on redis : ", zap.Error(err))
+			return nil, oauth.NewServerError()
+		}
+		return oauth.ToTokenResponse(*JWT, nil, scope), nil
+	}
+
+	refreshToken, err := oauth.GenerateRefreshToken(client.GetClientID(), userAccount.GetUUID(), grantID, scope, version)
+	if err != nil {
+		o.logger.Error("failed to generate oauth refresh token : ", zap.Error(err))
+		return nil, oauth.NewServerError()
+	}
+
+	if refreshToken, err = refreshToken.Create(ctx, o.redis); err != nil {
+		o.logger.Error("failed to create oauth refresh token on redis : ", zap.Error(err))
+		return nil, oauth.NewServerError()
+	}
+
+	return oauth.ToTokenResponse(*JWT, refreshToken, scope), nil
+}
+
+func (o oauthService) Introspect(ctx context.Context, in oauth.TokenIntrospectionRequest) (*oauth.IntrospectionResponse, error) {
+	// only a confidential client, usually a resource server, may introspect
+	client, err := o.authenticateClient(in.ClientCredentials)
+	if err != nil {
+		return nil, err
+	}
+
+	if !client.IsConfidential() {
+		return nil, oauth.NewInvalidClientError()
+	}
+
+	// an opaque token is a refresh token, anything else is tried as an access token
+	if in.IsRefreshTokenHint() || !strings.Contains(in.GetToken(), ".") {
+		refreshToken, err := oauth.GetRefreshToken(ctx, o.redis, in.GetToken())
+		if err != nil {
+			o.logger.Error("failed to get oauth refresh token on redis : ", zap.Error(err))
+			return nil, oauth.NewServerError()
+		}
+
+		if refreshToken.IsEmpty() {
+			return oauth.NewInactiveIntrospectionResponse(), nil
+		}
+
+		active, err := o.isRefreshTokenActive(ctx, *refreshToken)
+		if err != nil {
+			return nil, err
+		}
+
+		if !active {
+			return oauth.NewInactiveIntrospectionResponse(), nil
+		}
+		return refreshToken.ToIntrospectionResponse(), nil
+	}
+
+	claims, err := auth.ParseAccessToken(in.GetToken())
+	if err != nil || !claims.IsClientToken() {
+		return oauth.NewInactiveIntrospectionResponse(), nil
+	}
+
+	revoked, err := auth.IsAccessTokenRevoked(ctx, o.redis, claims)
+	if err != nil {
+		o.logger.Error("failed to check access token revocation on redis : ", zap.Error(err))
+		return nil, oauth.NewServerError()
+	}
+
+	if revoked {
+		return oauth.NewInactiveIntrospectionResponse(), nil
+	}
+
+	return oauth.ToIntrospectionResponse(*claims), nil
+}
+
+func (o oauthService) Revoke(ctx context.Context, in oauth.TokenIntrospectionRequest) error {
+	// authenticate client
+	client, err := o.authenticateClient(in.ClientCredentials)
+	if err != nil {
+		return err
+	}
+
+	// rfc 7009 answers 200 for an unknown token or a token of another client as well
+	if in.IsRefreshTokenHint() || !strings.Contains(in.GetToken(), ".") {
+		refreshToken, err := oauth.GetRefreshToken(ctx, o.redis, in.GetToken())
+		if err != nil {
+			o.logger.Error("failed to get oauth refresh token on redis : ", zap.Error(err))
+			return oauth.NewServerError()
+		}
+
+		if refreshToken.IsEmpty() || !refreshToken.IsIssuedTo(client.GetClientID()) {
+			return nil
+		}
+
+		if err = refreshToken.Delete(ctx, o.redis); err != nil {
+			o.logger.Error("failed to delete oauth refresh token on redis : ", zap.Error(err))
+			return oauth.NewServerError()
+		}
+		return nil
+	}
+
+	claims, err := auth.ParseAccessToken(in.GetToken())
+	if err != nil || claims.GetClientID() != client.GetClientID() {
+		return nil
+	}
+
+	if err = auth.RevokeAccessToken(ctx, o.redis, claims.GetJTI(), claims.GetExpiresAt()); err != nil {
+		o.logger.Error("failed to revoke access token on redis : ", zap.Error(err))
+		return oauth.NewServerError()
+	}
+
+	return nil
+}
+
+func (o oauthService) UserInfo(ctx context.Context, userAccountUUID string, scope string) (*oauth.UserInfoResponse, error) {
+	// get user account by uuid
+	userAccount, err := user.NewUser().GetOneByUUID(o.db, userAccountUUID)
+	if err != nil {
+		o.logger.Error("failed to get user account by uuid : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if userAccount.IsEmpty() || !userAccount.IsVerified() {
+		return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(NoCredentialsFound.Error()))
+	}
+
+	return oauth.ToUserInfoResponse(*userAccount, scope), nil
+}
+
+// authenticateClient answers invalid_client for an unknown client and a wrong or missing secret alike
+func (o oauthService) authenticateClient(credentials oauth.ClientCredentials) (*oauth.OAuthClient, error) {
+	if credentials.GetClientID() == "" {
+		return nil, oauth.NewInvalidClientError()
+	}
+
+	client, err := oauth.NewOAuthClient().GetOneByClientID(o.db, credentials.GetClientID())
+	if err != nil {
+		o.logger.Error("failed to get oauth client : ", zap.Error(err))
+		return nil, oauth.NewServerError()
+	}
+
+	if client.IsEmpty() || !client.Authenticate(credentials.GetClientSecret()) {
+		return nil, oauth.NewInvalidClientError()
+	}
+
+	return client, nil
+}
+
+func authorizationError(in oauth.AuthorizationRequest, code string, err error) error {
+	return responseErr.New(fiber.StatusBadRequest, responseErr.WithCode(code), responseErr.WithMessage(err.Error()), responseErr.WithMeta(map[string]interface{}{
+		"redirect_uri": in.ToErrorRedirect(code),
+	}))
+}
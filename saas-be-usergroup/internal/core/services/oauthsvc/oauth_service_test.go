@@ -0,0 +1,259 @@
+package oauthsvc
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saas-be-usergroup/internal/core/domain/oauth"
+	"github.com/saas-be-usergroup/internal/core/domain/user"
+	"github.com/saas-be-usergroup/internal/core/ports"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+const (
+	testUserUUID     = "8b0f6c3e-5c1a-4d5e-9a7b-0c2d3e4f5a6b"
+	testRedirectURI  = "https://app.example.com/callback"
+	otherRedirectURI = "https://app.example.com/other"
+	testCodeVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+// testClient is a confidential client registered for both redirect uris, it may refresh its tokens
+type testClient struct {
+	client oauth.OAuthClient
+	secret string
+}
+
+func newTestClient(t *testing.T) testClient {
+	t.Helper()
+
+	client, secret, err := oauth.RegisterClientRequest{
+		Name:         "app",
+		RedirectURIs: []string{testRedirectURI, otherRedirectURI},
+		Scopes:       []string{oauth.ScopeProfile, oauth.ScopeEmail},
+		GrantTypes:   []string{oauth.GrantAuthorizationCode, oauth.GrantRefreshToken},
+		Confidential: true,
+	}.ToClient()
+	if err != nil {
+		t.Fatalf("ToClient() error = %v", err)
+	}
+	return testClient{client: *client, secret: secret}
+}
+
+func (c testClient) credentials() oauth.ClientCredentials {
+	return oauth.ClientCredentials{ClientID: c.client.ClientID, ClientSecret: c.secret}
+}
+
+// clientsAndUser answers the oauth clients lookup with the given clients and the users lookup with one verified account
+func clientsAndUser(clients ...testClient) fakeQuery {
+	return func(query string, args []driver.NamedValue) ([]string, [][]driver.Value) {
+		if strings.Contains(query, `"oauth_clients"`) {
+			columns := []string{"id", "client_id", "client_secret_hash", "name", "redirect_uris", "scopes", "grant_types", "confidential", "insert_ts"}
+			for i, c := range clients {
+				if len(args) > 0 && args[0].Value == c.client.ClientID {
+					return columns, [][]driver.Value{{int64(i + 1), c.client.ClientID, c.client.ClientSecretHash, c.client.Name, c.client.RedirectURIs, c.client.Scopes, c.client.GrantTypes, c.client.Confidential, time.Now()}}
+				}
+			}
+			return columns, nil
+		}
+
+		columns := []string{"id", "uuid", "user_name", "password", "email", "status", "insert_ts"}
+		if len(args) == 0 || args[0].Value != testUserUUID {
+			return columns, nil
+		}
+		return columns, [][]driver.Value{{int64(1), testUserUUID, "alice", "hashed", "alice@example.com", string(user.UserVerified), time.Now()}}
+	}
+}
+
+func newTestService(t *testing.T, clients ...testClient) ports.OAuthService {
+	t.Helper()
+
+	viper.Set("jwt.access_secret", "access-secret")
+	viper.Set("jwt.refresh_secret", "refresh-secret")
+	t.Cleanup(func() {
+		viper.Set("jwt.access_secret", nil)
+		viper.Set("jwt.refresh_secret", nil)
+	})
+
+	_, client := newFakeRedis(t)
+	return NewOAuthService(newFakeDB(t, clientsAndUser(clients...)), client, zap.NewNop())
+}
+
+// issueCode goes through the consent of the user and returns the authorization code the client is redirected with
+func issueCode(t *testing.T, service ports.OAuthService, c testClient, redirectURI string) string {
+	t.Helper()
+
+	sum := sha256.Sum256([]byte(testCodeVerifier))
+	consent, err := service.Authorize(context.Background(), oauth.AuthorizationRequest{
+		ResponseType:        oauth.ResponseTypeCode,
+		ClientID:            c.client.ClientID,
+		RedirectURI:         redirectURI,
+		State:               "xyz",
+		CodeChallenge:       base64.RawURLEncoding.EncodeToString(sum[:]),
+		CodeChallengeMethod: oauth.CodeChallengeMethodS256,
+	}, testUserUUID)
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+
+	decision, err := service.Decide(context.Background(), oauth.DecisionRequest{ConsentToken: consent.ConsentToken, Approve: true}, testUserUUID)
+	if err != nil {
+		t.Fatalf("Decide() error = %v", err)
+	}
+
+	redirect, err := url.Parse(decision.RedirectURI)
+	if err != nil {
+		t.Fatalf("parse redirect uri %q: %v", decision.RedirectURI, err)
+	}
+	code := redirect.Query().Get("code")
+	if code == "" {
+		t.Fatalf("redirect uri %q has no code", decision.RedirectURI)
+	}
+	return code
+}
+
+func exchangeCode(service ports.OAuthService, c testClient, code string, redirectURI string) (*oauth.TokenResponse, error) {
+	return service.Token(context.Background(), oauth.TokenRequest{
+		ClientCredentials: c.credentials(),
+		GrantType:         oauth.GrantAuthorizationCode,
+		Code:              code,
+		RedirectURI:       redirectURI,
+		CodeVerifier:      testCodeVerifier,
+	})
+}
+
+func refresh(service ports.OAuthService, c testClient, refreshToken string) (*oauth.TokenResponse, error) {
+	return service.Token(context.Background(), oauth.TokenRequest{
+		ClientCredentials: c.credentials(),
+		GrantType:         oauth.GrantRefreshToken,
+		RefreshToken:      refreshToken,
+	})
+}
+
+func introspect(t *testing.T, service ports.OAuthService, c testClient, token string) bool {
+	t.Helper()
+
+	response, err := service.Introspect(context.Background(), oauth.TokenIntrospectionRequest{ClientCredentials: c.credentials(), Token: token})
+	if err != nil {
+		t.Fatalf("Introspect() error = %v", err)
+	}
+	return response.Active
+}
+
+// oauthCodeOf returns the rfc 6749 error code of an oauth error, empty for anything else
+func oauthCodeOf(err error) string {
+	var oauthErr *oauth.Error
+	if errors.As(err, &oauthErr) {
+		return oauthErr.Code
+	}
+	return ""
+}
+
+func TestTokenCodeReplayRevokesTheGrant(t *testing.T) {
+	c := newTestClient(t)
+	service := newTestService(t, c)
+	code := issueCode(t, service, c, testRedirectURI)
+
+	tokens, err := exchangeCode(service, c, code, testRedirectURI)
+	if err != nil {
+		t.Fatalf("first exchange error = %v", err)
+	}
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" {
+		t.Fatalf("first exchange issued %+v, want an access and a refresh token", tokens)
+	}
+	if !introspect(t, service, c, tokens.RefreshToken) {
+		t.Fatal("refresh token inactive before the replay")
+	}
+
+	if _, err = exchangeCode(service, c, code, testRedirectURI); oauthCodeOf(err) != oauth.ErrorInvalidGrant {
+		t.Fatalf("replayed exchange error = %v, want %s", err, oauth.ErrorInvalidGrant)
+	}
+
+	// the tokens of the first exchange go with the grant
+	if introspect(t, service, c, tokens.AccessToken) {
+		t.Error("access token of the replayed code is still active")
+	}
+	if introspect(t, service, c, tokens.RefreshToken) {
+		t.Error("refresh token of the replayed code is still active")
+	}
+	if _, err = refresh(service, c, tokens.RefreshToken); oauthCodeOf(err) != oauth.ErrorInvalidGrant {
+		t.Errorf("refresh after the replay error = %v, want %s", err, oauth.ErrorInvalidGrant)
+	}
+}
+
+func TestTokenRejectsAnotherRedirectURI(t *testing.T) {
+	c := newTestClient(t)
+	service := newTestService(t, c)
+	code := issueCode(t, service, c, testRedirectURI)
+
+	tokens, err := exchangeCode(service, c, code, otherRedirectURI)
+	if oauthCodeOf(err) != oauth.ErrorInvalidGrant {
+		t.Fatalf("exchange with another redirect uri error = %v, want %s", err, oauth.ErrorInvalidGrant)
+	}
+	if tokens != nil {
+		t.Errorf("exchange with another redirect uri issued %+v, want no tokens", tokens)
+	}
+
+	// the code was consumed by the failed exchange, it can not be retried
+	if _, err = exchangeCode(service, c, code, testRedirectURI); oauthCodeOf(err) != oauth.ErrorInvalidGrant {
+		t.Errorf("retried exchange error = %v, want %s", err, oauth.ErrorInvalidGrant)
+	}
+}
+
+func TestRevokeRefreshToken(t *testing.T) {
+	c := newTestClient(t)
+	other := newTestClient(t)
+	service := newTestService(t, c, other)
+
+	tokens, err := exchangeCode(service, c, issueCode(t, service, c, testRedirectURI), testRedirectURI)
+	if err != nil {
+		t.Fatalf("exchange error = %v", err)
+	}
+
+	// another client revoking the token is answered 200 and changes nothing, rfc 7009 section 2.2
+	if err = service.Revoke(context.Background(), oauth.TokenIntrospectionRequest{ClientCredentials: other.credentials(), Token: tokens.RefreshToken}); err != nil {
+		t.Fatalf("Revoke() by another client error = %v", err)
+	}
+	if !introspect(t, service, c, tokens.RefreshToken) {
+		t.Fatal("refresh token revoked by another client")
+	}
+
+	if err = service.Revoke(context.Background(), oauth.TokenIntrospectionRequest{ClientCredentials: c.credentials(), Token: tokens.RefreshToken}); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+	if introspect(t, service, c, tokens.RefreshToken) {
+		t.Error("revoked refresh token is still active")
+	}
+	if _, err = refresh(service, c, tokens.RefreshToken); oauthCodeOf(err) != oauth.ErrorInvalidGrant {
+		t.Errorf("refresh with the revoked token error = %v, want %s", err, oauth.ErrorInvalidGrant)
+	}
+}
+
+func TestRevokeAccessToken(t *testing.T) {
+	c := newTestClient(t)
+	service := newTestService(t, c)
+
+	tokens, err := exchangeCode(service, c, issueCode(t, service, c, testRedirectURI), testRedirectURI)
+	if err != nil {
+		t.Fatalf("exchange error = %v", err)
+	}
+
+	if err = service.Revoke(context.Background(), oauth.TokenIntrospectionRequest{ClientCredentials: c.credentials(), Token: tokens.AccessToken}); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+	if introspect(t, service, c, tokens.AccessToken) {
+		t.Error("revoked access token is still active")
+	}
+
+	// only the access token was revoked, the refresh token still works
+	if _, err = refresh(service, c, tokens.RefreshToken); err != nil {
+		t.Errorf("refresh after revoking the access token error = %v, want nil", err)
+	}
+}
@@ -0,0 +1,134 @@
+package outboxsvc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/saas-be-usergroup/internal/core/domain/mailer"
+	"github.com/saas-be-usergroup/internal/core/ports"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type outboxWorker struct {
+	db          *gorm.DB
+	transport   ports.Mailer
+	logger      *zap.Logger
+	interval    time.Duration
+	batchSize   int
+	lease       time.Duration
+	maxAttempts int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+	stop        chan struct{}
+	done        chan struct{}
+	once        sync.Once
+}
+
+func NewOutboxWorker(db *gorm.DB, transport ports.Mailer, logger *zap.Logger) *outboxWorker {
+	return &outboxWorker{
+		db:          db,
+		transport:   transport,
+		logger:      logger,
+		interval:    durationOrDefault("mailer.outbox.poll_interval", 5*time.Second),
+		batchSize:   intOrDefault("mailer.outbox.batch_size", 20),
+		lease:       durationOrDefault("mailer.outbox.lease", 5*time.Minute),
+		maxAttempts: intOrDefault("mailer.outbox.max_attempts", 8),
+		baseBackoff: durationOrDefault("mailer.outbox.base_backoff", 30*time.Second),
+		maxBackoff:  durationOrDefault("mailer.outbox.max_backoff", time.Hour),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// Start polls the outbox in the background until Shutdown is called
+func (w *outboxWorker) Start() {
+	go func() {
+		defer close(w.done)
+
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			w.process(context.Background())
+
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Shutdown stops polling, waits for the current batch and drains the mails that are
+// already due until the context is done, the rest stays in the outbox for the next start
+func (w *outboxWorker) Shutdown(ctx context.Context) {
+	w.once.Do(func() { close(w.stop) })
+
+	select {
+	case <-w.done:
+	case <-ctx.Done():
+		return
+	}
+
+	for ctx.Err() == nil {
+		if w.process(ctx) == 0 {
+			return
+		}
+	}
+}
+
+// process delivers one batch and returns the number of claimed mails
+func (w *outboxWorker) process(ctx context.Context) int {
+	outboxes, err := mailer.NewEmailOutbox().ClaimDue(w.db.WithContext(ctx), w.batchSize, w.lease)
+	if err != nil {
+		w.logger.Error("failed to claim email outbox : ", zap.Error(err))
+		return 0
+	}
+
+	for i := range outboxes {
+		w.deliver(ctx, &outboxes[i])
+	}
+
+	return len(outboxes)
+}
+
+func (w *outboxWorker) deliver(ctx context.Context, outbox *mailer.EmailOutbox) {
+	m, err := outbox.ToMailer()
+	if err == nil {
+		err = w.transport.Send(ctx, *m)
+	}
+
+	if err != nil {
+		outbox.SetFailed(err, w.maxAttempts, w.baseBackoff, w.maxBackoff)
+		if outbox.IsDead() {
+			w.logger.Error("email moved to dead letter : ", zap.Uint64("id", outbox.GetID()), zap.Int("attempts", outbox.GetAttempts()), zap.Error(err))
+		} else {
+			w.logger.Warn("failed to send email, retrying later : ", zap.Uint64("id", outbox.GetID()), zap.Int("attempts", outbox.GetAttempts()), zap.Error(err))
+		}
+	} else {
+		outbox.SetSent()
+	}
+
+	// the status is saved even when the delivery context is done
+	if _, err = outbox.Update(w.db); err != nil {
+		w.logger.Error("failed to update email outbox : ", zap.Uint64("id", outbox.GetID()), zap.Error(err))
+	}
+}
+
+func durationOrDefault(key string, def time.Duration) time.Duration {
+	if d := viper.GetDuration(key); d > 0 {
+		return d
+	}
+	return def
+}
+
+func intOrDefault(key string, def int) int {
+	if i := viper.GetInt(key); i > 0 {
+		return i
+	}
+	return def
+}
@@ -0,0 +1,82 @@
+package purgesvc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/saas-be-usergroup/internal/core/domain/user"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const defaultInterval = time.Hour
+
+type purgeWorker struct {
+	db       *gorm.DB
+	logger   *zap.Logger
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+// NewPurgeWorker removes the sign-ups that were never finished, registration.purge_after sets their age
+func NewPurgeWorker(db *gorm.DB, logger *zap.Logger) *purgeWorker {
+	interval := viper.GetDuration("registration.purge_interval")
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+
+	return &purgeWorker{
+		db:       db,
+		logger:   logger,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start purges once and then on every interval until Shutdown is called
+func (w *purgeWorker) Start() {
+	go func() {
+		defer close(w.done)
+
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			w.purge(context.Background())
+
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Shutdown stops the worker and waits for a running purge until the context is done
+func (w *purgeWorker) Shutdown(ctx context.Context) {
+	w.once.Do(func() { close(w.stop) })
+
+	select {
+	case <-w.done:
+	case <-ctx.Done():
+	}
+}
+
+func (w *purgeWorker) purge(ctx context.Context) {
+	before := time.Now().Add(-user.GetPurgeAfter())
+	purged, err := user.NewUser().DeletePendingBefore(w.db.WithContext(ctx), before)
+	if err != nil {
+		w.logger.Error("failed to purge unfinished sign-ups : ", zap.Error(err))
+		return
+	}
+
+	if purged > 0 {
+		w.logger.Info("unfinished sign-ups purged : ", zap.Int64("count", purged), zap.Time("before", before))
+	}
+}
@@ -2,21 +2,41 @@ package usersvc
 
 import (
 	"context"
+	"errors"
+	"github.com/go-redis/redis/v8"
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/saas-be-usergroup/internal/core/domain/auth"
+	"github.com/saas-be-usergroup/internal/core/domain/mailer"
 	"github.com/saas-be-usergroup/internal/core/domain/user"
 	"github.com/saas-be-usergroup/internal/core/ports"
+	"github.com/saas-be-usergroup/internal/core/utils/passwordpolicy"
 	responseErr "github.com/saas-be-usergroup/internal/error"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
+var (
+	NoCredentialsFound    = errors.New("no credentials found")
+	EmailAlreadyTaken     = errors.New("email has been already taken")
+	EmailNotChanged       = errors.New("new email is the same as the current email")
+	OTPNotFound           = errors.New("otp not found")
+	InvalidOTP            = errors.New("invalid otp")
+	InvalidSession        = errors.New("invalid session")
+	UsernameNotAvailable  = errors.New("username not available")
+	OTPResendTooSoon      = errors.New("please wait before requesting a new otp")
+	OTPResendLimitReached = errors.New("too many otp requested, please try again tomorrow")
+)
+
 type userService struct {
 	db     *gorm.DB
+	redis  *redis.Client
+	mailer ports.Mailer
 	logger *zap.Logger
 }
 
-func NewUserService(db *gorm.DB, logger *zap.Logger) ports.UserService {
-	return &userService{db: db, logger: logger}
+func NewUserService(db *gorm.DB, redis *redis.Client, mailer ports.Mailer, logger *zap.Logger) ports.UserService {
+	return &userService{db: db, redis: redis, mailer: mailer, logger: logger}
 }
 
 func (u userService) IsEmailAvailable(ctx context.Context, in user.IsEmailAvailableRequest) (*user.AvailableResponse, error) {
@@ -39,26 +59,352 @@ func (u userService) IsUsernameAvailable(ctx context.Context, in user.IsUsername
 	return user.NewAvailableResponse(isAvailable), nil
 }
 
-func (u userService) ChangeEmailBefore(ctx context.Context, in user.ChangeEmailBeforeRequest, userAccountUUID string) error {
-	return nil
+func (u userService) ChangeEmailBefore(ctx context.Context, in user.ChangeEmailBeforeRequest, userAccountUUID string) (*user.ChangeEmailBeforeResponse, error) {
+	// get user account by uuid
+	userAccount, err := user.NewUser().GetOneByUUID(u.db, userAccountUUID)
+	if err != nil {
+		u.logger.Error("failed to get user account by uuid : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if userAccount.IsEmpty() || !userAccount.IsVerified() {
+		return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(NoCredentialsFound.Error()))
+	}
+
+	if userAccount.GetEmail() == in.GetEmail() {
+		return nil, responseErr.New(fiber.StatusUnprocessableEntity, responseErr.WithMessage(EmailNotChanged.Error()))
+	}
+
+	// check new email
+	isAvailable, err := user.NewUser().IsEmailAvailable(u.db, in.GetEmail())
+	if err != nil {
+		u.logger.Error("failed to check email available : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if !isAvailable {
+		return nil, responseErr.New(fiber.StatusUnprocessableEntity, responseErr.WithMessage(EmailAlreadyTaken.Error()))
+	}
+
+	// check cooldown and daily cap, a new otp restarts the attempts counter
+	if err = u.checkOTPResend(ctx, auth.OTPChangeEmail, userAccount.GetUUID(), in.GetEmail()); err != nil {
+		return nil, err
+	}
+
+	// generate otp
+	newOTP, err := auth.GenerateNewOTP()
+	if err != nil {
+		u.logger.Error("failed to generate otp : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	// store pending email
+	changeEmail, err := user.NewChangeEmail(uuid.New().String(), userAccount.GetUUID(), in.GetEmail()).Create(ctx, u.redis, auth.GetOTPExpiredInSecond())
+	if err != nil {
+		u.logger.Error("failed to set change email on redis : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	// create otp of the pending email
+	otp, err := auth.NewOTPWithPurpose(auth.OTPChangeEmail, changeEmail.GetUUID(), newOTP).Create(ctx, u.redis, auth.GetOTPExpiredInSecond())
+	if err != nil {
+		u.logger.Error("failed to set otp on redis : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	// send otp to new email
+	if err = u.mailer.Send(ctx, *mailer.NewChangeEmailOTPMailer(changeEmail.GetEmail(), otp.ToChangeEmailMail())); err != nil {
+		u.logger.Error("failed to queue email : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	return changeEmail.ToChangeEmailBeforeResponse(), nil
 }
 
 func (u userService) ChangeEmailConfirmation(ctx context.Context, in user.ChangeEmailConfirmationRequest, userAccountUUID string) error {
+	// get pending email by uuid
+	changeEmail, err := user.GetChangeEmailByUUID(ctx, u.redis, in.GetUUID())
+	if err != nil {
+		u.logger.Error("failed to get change email by uuid : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	// pending email of another account is treated as not found
+	if changeEmail.IsNotFound() || !changeEmail.IsOwnedBy(userAccountUUID) {
+		return responseErr.New(fiber.StatusNotFound, responseErr.WithMessage(OTPNotFound.Error()))
+	}
+
+	// check otp of the pending email
+	otp, err := auth.GetOTPByPurposeAndUUID(ctx, u.redis, auth.OTPChangeEmail, changeEmail.GetUUID())
+	if err != nil {
+		u.logger.Error("failed to get otp by uuid : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if otp.IsNotFound() {
+		return responseErr.New(fiber.StatusNotFound, responseErr.WithMessage(OTPNotFound.Error()))
+	}
+
+	// check invalid otp, a valid otp is consumed
+	isValid, err := otp.Verify(ctx, u.redis, in.GetOTP())
+	if err != nil {
+		u.logger.Error("failed to verify otp : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if !isValid {
+		return responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(InvalidOTP.Error()))
+	}
+
+	// consume pending email
+	consumed, err := changeEmail.Consume(ctx, u.redis)
+	if err != nil {
+		u.logger.Error("failed to delete change email on redis : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if !consumed {
+		return responseErr.New(fiber.StatusNotFound, responseErr.WithMessage(OTPNotFound.Error()))
+	}
+
+	// recheck new email, it may be taken while waiting for confirmation
+	isAvailable, err := user.NewUser().IsEmailAvailable(u.db, changeEmail.GetEmail())
+	if err != nil {
+		u.logger.Error("failed to check email available : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if !isAvailable {
+		return responseErr.New(fiber.StatusUnprocessableEntity, responseErr.WithMessage(EmailAlreadyTaken.Error()))
+	}
+
+	// get user account by uuid
+	userAccount, err := user.NewUser().GetOneByUUID(u.db, userAccountUUID)
+	if err != nil {
+		u.logger.Error("failed to get user account by uuid : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if userAccount.IsEmpty() || !userAccount.IsVerified() {
+		return responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(NoCredentialsFound.Error()))
+	}
+
+	// update user email, a pending sign-up of the email is released
+	if _, err = userAccount.ClaimEmail(u.db, changeEmail.GetEmail()); err != nil {
+		if errors.Is(err, user.ErrEmailTaken) {
+			return responseErr.New(fiber.StatusUnprocessableEntity, responseErr.WithMessage(EmailAlreadyTaken.Error()))
+		}
+		u.logger.Error("failed to update user : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
 	return nil
 }
 
 func (u userService) ChangePasswordRequest(ctx context.Context, userAccountUUID string) error {
+	// get user account by uuid
+	userAccount, err := user.NewUser().GetOneByUUID(u.db, userAccountUUID)
+	if err != nil {
+		u.logger.Error("failed to get user account by uuid : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if userAccount.IsEmpty() || !userAccount.IsVerified() {
+		return responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(NoCredentialsFound.Error()))
+	}
+
+	// check cooldown and daily cap, a new otp restarts the attempts counter
+	if err = u.checkOTPResend(ctx, auth.OTPChangePassword, userAccount.GetUUID(), userAccount.GetEmail()); err != nil {
+		return err
+	}
+
+	// generate otp
+	newOTP, err := auth.GenerateNewOTP()
+	if err != nil {
+		u.logger.Error("failed to generate otp : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	// create otp
+	otp, err := auth.NewOTPWithPurpose(auth.OTPChangePassword, userAccount.GetUUID(), newOTP).Create(ctx, u.redis, auth.GetOTPExpiredInSecond())
+	if err != nil {
+		u.logger.Error("failed to set otp on redis : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	// send otp to account email
+	if err = u.mailer.Send(ctx, *mailer.NewChangePasswordOTPMailer(userAccount.GetEmail(), otp.ToChangePasswordMail())); err != nil {
+		u.logger.Error("failed to queue email : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
 	return nil
 }
 
-func (u userService) ChangePasswordConfirmation(ctx context.Context, in user.ChangePasswordConfirmationRequest, userAccountUUID string) error {
-	return nil
+func (u userService) ChangePasswordConfirmation(ctx context.Context, in user.ChangePasswordConfirmationRequest, userAccountUUID string) (*user.ChangePasswordConfirmationResponse, error) {
+	// check otp
+	otp, err := auth.GetOTPByPurposeAndUUID(ctx, u.redis, auth.OTPChangePassword, userAccountUUID)
+	if err != nil {
+		u.logger.Error("failed to get otp by uuid : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	// check otp is not found
+	if otp.IsNotFound() {
+		return nil, responseErr.New(fiber.StatusNotFound, responseErr.WithMessage(OTPNotFound.Error()))
+	}
+
+	// check invalid otp, a valid otp is consumed
+	isValid, err := otp.Verify(ctx, u.redis, in.GetOTP())
+	if err != nil {
+		u.logger.Error("failed to verify otp : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if !isValid {
+		return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(InvalidOTP.Error()))
+	}
+
+	// generate session token
+	sessionToken, err := auth.GenerateChangePasswordSessionToken(ctx, u.redis, userAccountUUID, 600)
+	if err != nil {
+		u.logger.Error("failed to create session token : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	return sessionToken.ToChangePasswordConfirmationResponse(), nil
 }
 
 func (u userService) DoChangePassword(ctx context.Context, in user.DoChangePasswordRequest, userAccountUUID string) error {
+	// consume session token, it can only be used once even by concurrent requests
+	session, err := auth.ConsumeChangePasswordSession(ctx, u.redis, in.GetSessionToken())
+	if err != nil {
+		u.logger.Error("failed to consume session token on redis : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if session.IsEmpty() || session.GetUUID() != userAccountUUID {
+		return responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(InvalidSession.Error()))
+	}
+
+	// get user account by uuid
+	userAccount, err := user.NewUser().GetOneByUUID(u.db, userAccountUUID)
+	if err != nil {
+		u.logger.Error("failed to get user account by uuid : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if userAccount.IsEmpty() || !userAccount.IsVerified() {
+		return responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(NoCredentialsFound.Error()))
+	}
+
+	// check password policy
+	if err = passwordpolicy.Check(u.logger, in.GetPassword(), userAccount.GetEmail(), userAccount.GetUsername()); err != nil {
+		return err
+	}
+
+	// generate hash password
+	hashedPassword, err := auth.GeneratePassword(in.GetPassword())
+	if err != nil {
+		u.logger.Error("failed to generate password : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	// update user password
+	userAccount.SetPassword(hashedPassword)
+	if _, err = userAccount.Update(u.db); err != nil {
+		u.logger.Error("failed to update user : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	// revoke every refresh and access token, user should login again with the new password
+	if err = auth.DeleteAllRefreshTokens(ctx, u.redis, userAccount.GetUUID()); err != nil {
+		u.logger.Error("failed to delete refresh tokens on redis : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+	if err = auth.RevokeAllAccessTokens(ctx, u.redis, userAccount.GetUUID()); err != nil {
+		u.logger.Error("failed to revoke access tokens on redis : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
 	return nil
 }
 
+func (u userService) GetUser(ctx context.Context, userAccountUUID string) (*user.Transformer, error) {
+	// get user account by uuid
+	userAccount, err := user.NewUser().GetOneByUUID(u.db, userAccountUUID)
+	if err != nil {
+		u.logger.Error("failed to get user account by uuid : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if userAccount.IsEmpty() || !userAccount.IsVerified() {
+		return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(NoCredentialsFound.Error()))
+	}
+
+	return userAccount.ToTransformer(), nil
+}
+
 func (u userService) UpdateUser(ctx context.Context, in user.UpdateRequest, userAccountUUID string) (*user.UpdateResponse, error) {
-	return nil, nil
+	// get user account by uuid
+	userAccount, err := user.NewUser().GetOneByUUID(u.db, userAccountUUID)
+	if err != nil {
+		u.logger.Error("failed to get user account by uuid : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if userAccount.IsEmpty() || !userAccount.IsVerified() {
+		return nil, responseErr.New(fiber.StatusUnauthorized, responseErr.WithMessage(NoCredentialsFound.Error()))
+	}
+
+	// check username only when it is changed
+	if in.IsUsernameChanged(userAccount) {
+		isAvailable, err := user.NewUser().IsUsernameAvailable(u.db, *in.UserName)
+		if err != nil {
+			u.logger.Error("failed to check username available : ", zap.Error(err))
+			return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+		}
+
+		if !isAvailable {
+			return nil, responseErr.New(fiber.StatusUnprocessableEntity, responseErr.WithMessage(UsernameNotAvailable.Error()))
+		}
+	}
+
+	// update user
+	userAccount, err = in.ToUpdateUser(userAccount).Update(u.db)
+	if err != nil {
+		u.logger.Error("failed to update user : ", zap.Error(err))
+		return nil, responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	return userAccount.ToUpdateResponse(), nil
+}
+
+// checkOTPResend applies the register resend cooldown and daily cap to another otp purpose
+func (u userService) checkOTPResend(ctx context.Context, purpose auth.OTPPurpose, userAccountUUID string, email string) error {
+	// check cooldown
+	acquired, remaining, err := auth.AcquireOTPResendCooldown(ctx, u.redis, purpose, userAccountUUID)
+	if err != nil {
+		u.logger.Error("failed to set otp resend cooldown on redis : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if !acquired {
+		return responseErr.New(fiber.StatusTooManyRequests, responseErr.WithMessage(OTPResendTooSoon.Error()), responseErr.WithMeta(map[string]interface{}{
+			"retry_after": int64(remaining.Seconds()),
+		}))
+	}
+
+	// check daily cap
+	total, err := auth.IncrementOTPResendDaily(ctx, u.redis, purpose, userAccountUUID, email)
+	if err != nil {
+		u.logger.Error("failed to increment otp resend counter on redis : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if total > auth.GetOTPResendDailyCap() {
+		return responseErr.New(fiber.StatusTooManyRequests, responseErr.WithMessage(OTPResendLimitReached.Error()))
+	}
+
+	return nil
 }
@@ -0,0 +1,26 @@
+package passwordpolicy
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/saas-be-usergroup/internal/core/domain/auth"
+	responseErr "github.com/saas-be-usergroup/internal/error"
+	"go.uber.org/zap"
+)
+
+// Check applies the password policy for the services setting a password, every broken rule is answered at once
+// so the user can fix them together. personal holds the email and the username of the account
+func Check(logger *zap.Logger, password string, personal ...string) error {
+	violations, err := auth.GetPasswordPolicy().Check(password, personal...)
+	if err != nil {
+		logger.Error("failed to check password policy : ", zap.Error(err))
+		return responseErr.New(fiber.StatusInternalServerError, responseErr.WithMessage(responseErr.ErrInternalServer.Error()))
+	}
+
+	if len(violations) > 0 {
+		return responseErr.New(fiber.StatusUnprocessableEntity, responseErr.WithCode(auth.CodePasswordPolicy), responseErr.WithMessage(auth.ErrPasswordPolicy.Error()), responseErr.WithMeta(map[string]interface{}{
+			"violations": violations,
+		}))
+	}
+
+	return nil
+}
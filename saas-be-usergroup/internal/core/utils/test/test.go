@@ -13,18 +13,12 @@ type PrepareContext struct {
 }
 
 func (e *PrepareContext) PrepareHTTPTestContext() *fiber.Ctx {
-	var header fasthttp.RequestHeader
-	header.SetMethod(e.Method)
-	header.SetContentType("application/json")
-	req := fasthttp.Request{
-		Header: header,
-	}
-	req.AppendBody(e.Payload)
-	req.SetRequestURI(e.URL)
+	requestCtx := &fasthttp.RequestCtx{}
+	requestCtx.Request.Header.SetMethod(e.Method)
+	requestCtx.Request.Header.SetContentType("application/json")
+	requestCtx.Request.AppendBody(e.Payload)
+	requestCtx.Request.SetRequestURI(e.URL)
 
-	requestCtx := &fasthttp.RequestCtx{
-		Request: req,
-	}
 	ctx := e.App.AcquireCtx(requestCtx)
 	return ctx
 }
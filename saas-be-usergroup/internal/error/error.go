@@ -47,6 +47,13 @@ func WithMessage(message string) AppErrorOption {
 	}
 }
 
+// WithCode sets a stable code the frontend can branch on instead of the message
+func WithCode(code string) AppErrorOption {
+	return func(h *AppError) {
+		h.Code = code
+	}
+}
+
 func WithMeta(meta interface{}) AppErrorOption {
 	return func(h *AppError) {
 		h.Meta = &meta
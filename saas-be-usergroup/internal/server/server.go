@@ -1,11 +1,16 @@
 package server
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"github.com/gofiber/fiber/v2/middleware/compress"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/etag"
+	"github.com/saas-be-usergroup/internal/adapter/mailer/maildrv"
+	"github.com/saas-be-usergroup/internal/core/domain/auth"
+	"github.com/saas-be-usergroup/internal/core/services/outboxsvc"
+	"github.com/saas-be-usergroup/internal/core/services/purgesvc"
 	"github.com/saas-be-usergroup/pkg/redis"
 	"log"
 	"os"
@@ -36,6 +41,16 @@ func Run() {
 		log.Fatal(err)
 	}
 
+	//load jwt keys, an invalid key configuration should stop the server before it issues tokens
+	if err := auth.LoadKeySet(); err != nil {
+		log.Fatal(err)
+	}
+
+	//check the magic link url, the mailed links point at it
+	if err := auth.CheckMagicLinkURL(); err != nil {
+		log.Fatal(err)
+	}
+
 	// //load connection config
 	pg, err := postgres.Connect()
 	if err != nil {
@@ -61,6 +76,17 @@ func Run() {
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	//load mail transport, services only write into the outbox and the worker delivers it
+	transport, err := maildrv.New(zap)
+	if err != nil {
+		log.Fatal(err)
+	}
+	outboxWorker := outboxsvc.NewOutboxWorker(pg, transport, zap)
+	outboxWorker.Start()
+	//remove sign-ups that were never finished
+	purgeWorker := purgesvc.NewPurgeWorker(pg, zap)
+	purgeWorker.Start()
 	//load fiber
 	app := fiber.New(fiber.Config{
 		IdleTimeout: 5,
@@ -75,6 +101,7 @@ func Run() {
 		R:        app,
 		Logger:   zap,
 		Redis:    red,
+		Mailer:   maildrv.NewOutboxMailer(pg),
 	}
 	rh.SetupRouter()
 
@@ -95,6 +122,10 @@ func Run() {
 	fmt.Println("Running cleanup tasks...")
 
 	// Your cleanup tasks go here
+	drainCtx, cancel := context.WithTimeout(context.Background(), viperPkg.GetDuration("mailer.outbox.drain_timeout"))
+	outboxWorker.Shutdown(drainCtx)
+	purgeWorker.Shutdown(drainCtx)
+	cancel()
 	sqlDB.Close()
 	fmt.Println("services was successful shutdown.")
 }